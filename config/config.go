@@ -20,6 +20,10 @@ type Data struct {
 		SwaggerPath     string
 		SwaggerFilePath string
 		ImageFilePath   string
+		SessionLifetime string
+
+		ActivationTokenLifetime    string
+		PasswordResetTokenLifetime string
 	}
 
 	Connections struct {
@@ -33,16 +37,55 @@ type Data struct {
 
 	Processing struct {
 		DonationCutBudget int64
+		// ReceivingBudget is the budget incoming payments get booked to
+		ReceivingBudget int64
+		// ApprovalThreshold is the amount from which on transfers and
+		// outgoing payments need RequiredApprovals approvals of different
+		// users. It applies per currency, in the smallest unit of the
+		// currency being moved. Zero disables approvals
+		ApprovalThreshold int64
+		// RequiredApprovals defaults to 2
+		RequiredApprovals int
+	}
+
+	// Sepa is the account outgoing payments get paid from
+	Sepa struct {
+		Name string
+		IBAN string
+		BIC  string
+	}
+
+	// Receipts holds the details of the tax-exempt organisation printed on
+	// annual donation receipts
+	Receipts struct {
+		Issuer  string
+		Address []string
+		// TaxOffice & Exemption identify the decision the tax exemption is
+		// based on, e.g. "Finanzamt für Körperschaften I Berlin, StNr. 27/..."
+		// and "Freistellungsbescheid vom 01.02.2025"
+		TaxOffice string
+		Exemption string
+		// Purpose is the tax-privileged purpose the donations are used for
+		Purpose   string
+		Place     string
+		Signatory string
 	}
 
 	PaymentProviders struct {
 		PayPal struct {
 			ClientID string
 			Secret   string
+			// WebhookID is the ID PayPal assigned to our webhook, which
+			// notifications get verified against
+			WebhookID string
+			// URL of the PayPal REST API, defaults to the live API
+			URL string
 		}
 		Stripe struct {
 			Key    string
 			Secret string
+			// WebhookSecret is the signing secret of our webhook endpoint
+			WebhookSecret string
 		}
 		Hbci struct {
 			Name     string
@@ -50,10 +93,32 @@ type Data struct {
 			BankCode string
 			Pin      string
 			URL      string
+			// Account is the number of the account to sync
+			Account string
+			// ProductID is the product registration number issued by the
+			// Deutsche Kreditwirtschaft
+			ProductID string
+			// SyncInterval enables syncing the account while serving
+			SyncInterval string
 		}
 		Bitpay struct {
 			Pem string
 			URL string
+			// Token is the merchant facade token paired with Pem's key
+			Token string
+		}
+	}
+
+	Passwords struct {
+		// Pepper is a secret mixed into all password hashes. Changing it
+		// invalidates all stored passwords
+		Pepper     string
+		Algorithm  string
+		BcryptCost int
+		Argon2     struct {
+			Time    uint32
+			Memory  uint32
+			Threads uint8
 		}
 	}
 
@@ -87,6 +152,8 @@ type EmailTemplate struct {
 // Templates holds all email templates
 type Templates struct {
 	PaymentConfirmation EmailTemplate
+	Activation          EmailTemplate
+	PasswordReset       EmailTemplate
 }
 
 // LoggerConnection contains all of the logger settings
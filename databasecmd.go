@@ -19,6 +19,9 @@ import (
 )
 
 var (
+	migrateTo     int
+	migrateDryRun bool
+
 	databaseCmd = &cobra.Command{
 		Use:   "database",
 		Short: "manage database",
@@ -33,6 +36,14 @@ var (
 			return executeDatabaseInit()
 		},
 	}
+	databaseMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "migrate the database schema",
+		Long:  `The migrate command upgrades or downgrades the database schema`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeDatabaseMigrate()
+		},
+	}
 	databaseMockCmd = &cobra.Command{
 		Use:   "mock",
 		Short: "generate mock-up data",
@@ -52,7 +63,11 @@ var (
 )
 
 func init() {
+	databaseMigrateCmd.Flags().IntVar(&migrateTo, "to", db.SchemaVersion, "schema version to migrate to")
+	databaseMigrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "only print the SQL statements")
+
 	databaseCmd.AddCommand(databaseInitCmd)
+	databaseCmd.AddCommand(databaseMigrateCmd)
 	databaseCmd.AddCommand(databaseMockCmd)
 	databaseCmd.AddCommand(databaseWipeCmd)
 	RootCmd.AddCommand(databaseCmd)
@@ -76,6 +91,7 @@ func executeDatabaseInit() error {
 
 	user := db.User{
 		Nickname: "admin",
+		Role:     db.ROLE_ADMIN,
 		Email:    "admin@techcultivation.org",
 		About:    "admin",
 		Address:  []string{},
@@ -135,6 +151,19 @@ func executeDatabaseInit() error {
 	return nil
 }
 
+func executeDatabaseMigrate() error {
+	log.Println("Migrate database")
+
+	db.GetDatabase()
+	from, err := db.InstalledSchemaVersion()
+	if err != nil {
+		return err
+	}
+	log.Printf("Migrating database schema from version %d to %d\n", from, migrateTo)
+
+	return db.MigrateDatabase(migrateTo, migrateDryRun)
+}
+
 func executeDatabaseWipe() error {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Print("Do you really want to wipe the entire database?\nEnter 'SELFDESTRUCT' to confirm: ")
@@ -164,6 +193,7 @@ func executeDatabaseMock() error {
 	log.Println("Generating mock-up data")
 
 	db.GetDatabase()
+	db.InitDatabase()
 	context := &db.APIContext{
 		Config: *config.Settings,
 	}
@@ -184,7 +214,7 @@ func executeDatabaseMock() error {
 			}
 
 			if i%2 == 0 {
-				t.Pending = false
+				t.State = db.PAYMENT_STATE_APPROVED
 				t.Code = code
 				err = t.Update(ctx)
 				if err != nil {
@@ -212,10 +242,11 @@ func mockPayment(ctx *db.APIContext, budget int64, negative bool) (db.Payment, e
 		RemoteAccount: strconv.FormatInt(int64(gofakeit.CreditCard().Number), 10),
 		RemoteBankID:  strconv.FormatInt(int64(gofakeit.CreditCard().Number), 10),
 		RemoteName:    gofakeit.Name(),
+		RemoteEmail:   gofakeit.Email(),
 		Source:        "hbci",
 	}
 
-	return t, t.Save(ctx)
+	return t, t.Save(ctx, nil)
 }
 
 func mockProject(ctx *db.APIContext) (string, error) {
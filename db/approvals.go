@@ -0,0 +1,377 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Transfers and outgoing payments from Processing.ApprovalThreshold on need
+// to be approved by several users before they get executed
+const (
+	APPROVAL_KIND_TRANSFER = "transfer"
+	APPROVAL_KIND_PAYMENT  = "payment"
+
+	APPROVAL_STATE_PENDING    = "pending"
+	APPROVAL_STATE_APPROVED   = "approved"
+	APPROVAL_STATE_REJECTED   = "rejected"
+	APPROVAL_STATE_SUPERSEDED = "superseded"
+
+	APPROVAL_DECISION_APPROVE = "approve"
+	APPROVAL_DECISION_REJECT  = "reject"
+
+	// DEFAULT_REQUIRED_APPROVALS is the number of approvals needed, unless
+	// configured otherwise
+	DEFAULT_REQUIRED_APPROVALS = 2
+)
+
+var (
+	// ErrApprovalRequired is the error returned for operations that can't be
+	// executed before they have been approved
+	ErrApprovalRequired = errors.New("This operation needs to be approved")
+	// ErrApprovalDecided is the error returned when deciding on a request
+	// that has already been approved or rejected
+	ErrApprovalDecided = errors.New("This request has already been decided")
+	// ErrAlreadyApproved is the error returned when a user decides on a
+	// request more than once
+	ErrAlreadyApproved = errors.New("You have already decided on this request")
+	// ErrInvalidDecision is the error returned for unknown decisions
+	ErrInvalidDecision = errors.New("Invalid decision")
+)
+
+// ApprovalRequest represents the db schema of a request to approve a transfer
+// or an outgoing payment. Payment approvals only apply to the amount & code
+// they were requested for
+type ApprovalRequest struct {
+	ID            int64
+	Kind          string
+	State         string
+	PaymentID     *int64
+	FromBudgetID  *int64
+	ToBudgetID    *int64
+	Amount        int64
+	Code          string
+	Purpose       string
+	UserID        *int64
+	CreatedAt     time.Time
+	DecidedAt     *time.Time
+	TransactionID *int64
+
+	Approvals []Approval
+}
+
+// Approval represents the db schema of a user's decision on an approval
+// request
+type Approval struct {
+	ID        int64
+	RequestID int64
+	UserID    int64
+	Decision  string
+	CreatedAt time.Time
+}
+
+// RequiredApprovals returns how many users need to approve a request
+func (context *APIContext) RequiredApprovals() int {
+	if context.Config.Processing.RequiredApprovals > 0 {
+		return context.Config.Processing.RequiredApprovals
+	}
+	return DEFAULT_REQUIRED_APPROVALS
+}
+
+// NeedsApproval returns true if moving amount needs to be approved. Amounts
+// aren't converted: the threshold applies to the smallest unit of whichever
+// currency a budget or payment uses
+func (context *APIContext) NeedsApproval(amount int64) bool {
+	if amount < 0 {
+		amount = -amount
+	}
+	threshold := context.Config.Processing.ApprovalThreshold
+	return threshold > 0 && amount >= threshold
+}
+
+// RequestTransferApproval asks for a transfer to be approved. Requesting it
+// counts as the requesting user's approval
+func (context *APIContext) RequestTransferApproval(fromBudget, toBudget int64, amount int64, purpose string) (ApprovalRequest, error) {
+	request := ApprovalRequest{
+		Kind:         APPROVAL_KIND_TRANSFER,
+		FromBudgetID: &fromBudget,
+		ToBudgetID:   &toBudget,
+		Amount:       amount,
+		Purpose:      purpose,
+	}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		if err := request.save(tx); err != nil {
+			return err
+		}
+		return request.decide(tx, APPROVAL_DECISION_APPROVE)
+	})
+	return request, err
+}
+
+// RequestPaymentApproval asks for an outgoing payment to be approved, which
+// counts as the requesting user's approval. If its approval has already been
+// requested, the user's approval gets added to the pending request. Once
+// approved, the payment gets queued for processing
+func (context *APIContext) RequestPaymentApproval(payment *Payment) (ApprovalRequest, error) {
+	request := ApprovalRequest{}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		var state string
+		err := tx.QueryRow("SELECT state, amount, code FROM payments WHERE id = $1 FOR UPDATE", payment.ID).
+			Scan(&state, &payment.Amount, &payment.Code)
+		if err != nil {
+			return err
+		}
+		if !CanTransition(state, PAYMENT_STATE_APPROVED) {
+			return ErrInvalidTransition
+		}
+
+		request, err = loadApprovalRequest(tx, "WHERE payment_id = $1 AND state = 'pending' FOR UPDATE", payment.ID)
+		if err == sql.ErrNoRows {
+			request = ApprovalRequest{
+				Kind:      APPROVAL_KIND_PAYMENT,
+				PaymentID: &payment.ID,
+				Amount:    payment.Amount,
+				Code:      payment.Code,
+				Purpose:   payment.Purpose,
+			}
+			err = request.save(tx)
+		}
+		if err != nil {
+			return err
+		}
+
+		return request.decide(tx, APPROVAL_DECISION_APPROVE)
+	})
+	if err != nil {
+		return request, err
+	}
+
+	return request, request.execute(context)
+}
+
+// DecideApprovalRequest approves or rejects a request on behalf of the
+// context's user. A single rejection rejects the request. Transfers get
+// executed as soon as they have enough approvals, payments get queued for
+// processing
+func (context *APIContext) DecideApprovalRequest(id int64, decision string) (ApprovalRequest, error) {
+	request := ApprovalRequest{}
+	if decision != APPROVAL_DECISION_APPROVE && decision != APPROVAL_DECISION_REJECT {
+		return request, ErrInvalidDecision
+	}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		request, err = loadApprovalRequest(tx, "WHERE id = $1 FOR UPDATE", id)
+		if err != nil {
+			return err
+		}
+		return request.decide(tx, decision)
+	})
+	if err != nil {
+		return request, err
+	}
+
+	return request, request.execute(context)
+}
+
+func (request *ApprovalRequest) save(tx *APIContextTx) error {
+	request.State = APPROVAL_STATE_PENDING
+	request.CreatedAt = time.Now().UTC()
+	if auth := tx.Context().Auth; auth != nil && auth.ID > 0 {
+		request.UserID = &auth.ID
+	}
+
+	return tx.QueryRow("INSERT INTO approval_requests (kind, state, payment_id, from_budget_id, to_budget_id, amount, code, purpose, user_id, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id",
+		request.Kind, request.State, request.PaymentID, request.FromBudgetID, request.ToBudgetID, request.Amount, request.Code, request.Purpose,
+		request.UserID, request.CreatedAt).Scan(&request.ID)
+}
+
+// decide records the decision of the transaction's user on a locked request.
+// Transfers get executed within the transaction once the request has enough
+// approvals
+func (request *ApprovalRequest) decide(tx *APIContextTx, decision string) error {
+	context := tx.Context()
+	if request.State != APPROVAL_STATE_PENDING {
+		return ErrApprovalDecided
+	}
+	if context.Auth == nil || context.Auth.ID == 0 {
+		return ErrPermissionDenied
+	}
+
+	res, err := tx.Exec("INSERT INTO approvals (request_id, user_id, decision, created_at) VALUES ($1, $2, $3, $4) "+
+		"ON CONFLICT (request_id, user_id) DO NOTHING",
+		request.ID, context.Auth.ID, decision, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAlreadyApproved
+	}
+
+	if request.Approvals, err = loadApprovals(tx, request.ID); err != nil {
+		return err
+	}
+
+	approvals := 0
+	for _, a := range request.Approvals {
+		if a.Decision == APPROVAL_DECISION_APPROVE {
+			approvals++
+		}
+	}
+
+	switch {
+	case decision == APPROVAL_DECISION_REJECT:
+		request.State = APPROVAL_STATE_REJECTED
+	case approvals >= context.RequiredApprovals():
+		request.State = APPROVAL_STATE_APPROVED
+		if request.Kind == APPROVAL_KIND_TRANSFER {
+			t, err := tx.transferFunds(*request.FromBudgetID, *request.ToBudgetID, request.Amount, request.Purpose, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+			request.TransactionID = &t.ID
+		}
+	default:
+		return nil
+	}
+
+	now := time.Now().UTC()
+	request.DecidedAt = &now
+	_, err = tx.Exec("UPDATE approval_requests SET state = $1, decided_at = $2, transaction_id = $3 WHERE id = $4",
+		request.State, request.DecidedAt, request.TransactionID, request.ID)
+	return err
+}
+
+// execute queues an approved payment for processing, once the approval has
+// been committed
+func (request *ApprovalRequest) execute(context *APIContext) error {
+	if request.Kind != APPROVAL_KIND_PAYMENT || request.State != APPROVAL_STATE_APPROVED {
+		return nil
+	}
+
+	payment, err := context.LoadPaymentByID(*request.PaymentID)
+	if err != nil {
+		return err
+	}
+	payment.State = PAYMENT_STATE_APPROVED
+	return payment.Update(context)
+}
+
+// paymentApproved returns true if an outgoing payment has been approved with
+// its current amount & code
+func paymentApproved(context sqlAdapter, paymentID int64) (bool, error) {
+	var n int64
+	err := context.QueryRow("SELECT COUNT(*) FROM approval_requests "+
+		"JOIN payments ON payments.id = approval_requests.payment_id "+
+		"WHERE approval_requests.payment_id = $1 AND approval_requests.state = 'approved' "+
+		"AND approval_requests.amount = payments.amount AND approval_requests.code = payments.code", paymentID).Scan(&n)
+	return n > 0, err
+}
+
+// supersedePaymentApprovals voids the pending and approved requests of a
+// payment, once it left the approved state or its code changed. Approving it
+// again needs a new request
+func supersedePaymentApprovals(tx *APIContextTx, paymentID int64) error {
+	_, err := tx.Exec("UPDATE approval_requests SET state = $1, decided_at = $2 WHERE payment_id = $3 AND state IN ('pending', 'approved')",
+		APPROVAL_STATE_SUPERSEDED, time.Now().UTC(), paymentID)
+	return err
+}
+
+// LoadApprovalRequestByID loads an approval request by ID from the database
+func (context *APIContext) LoadApprovalRequestByID(id int64) (ApprovalRequest, error) {
+	if id < 1 {
+		return ApprovalRequest{}, ErrInvalidID
+	}
+	return loadApprovalRequest(context, "WHERE id = $1", id)
+}
+
+// LoadApprovalRequests loads all approval requests in a state, oldest first
+func (context *APIContext) LoadApprovalRequests(state string) ([]ApprovalRequest, error) {
+	requests := []ApprovalRequest{}
+
+	rows, err := context.Query("SELECT id FROM approval_requests WHERE state = $1 ORDER BY created_at ASC, id ASC", state)
+	if err != nil {
+		return requests, err
+	}
+
+	var ids []int64
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return requests, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return requests, err
+	}
+
+	for _, id := range ids {
+		request, err := loadApprovalRequest(context, "WHERE id = $1", id)
+		if err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// CanDecide returns true if a user may approve or reject a request: payments
+// need permission to manage payments, transfers permission to transfer funds
+// from and to both budgets
+func (request *ApprovalRequest) CanDecide(context *APIContext, user *User) bool {
+	if request.Kind == APPROVAL_KIND_PAYMENT {
+		return user.Can(PERMISSION_MANAGE_PAYMENTS, nil)
+	}
+
+	for _, bid := range []*int64{request.FromBudgetID, request.ToBudgetID} {
+		if bid == nil {
+			return false
+		}
+		budget, err := context.LoadBudgetByID(*bid)
+		if err != nil || !user.Can(PERMISSION_TRANSFER, budget.ProjectID) {
+			return false
+		}
+	}
+	return true
+}
+
+func loadApprovalRequest(context sqlAdapter, where string, args ...interface{}) (ApprovalRequest, error) {
+	request := ApprovalRequest{}
+
+	err := context.QueryRow("SELECT id, kind, state, payment_id, from_budget_id, to_budget_id, amount, code, purpose, user_id, created_at, decided_at, transaction_id "+
+		"FROM approval_requests "+where, args...).
+		Scan(&request.ID, &request.Kind, &request.State, &request.PaymentID, &request.FromBudgetID, &request.ToBudgetID, &request.Amount,
+			&request.Code, &request.Purpose, &request.UserID, &request.CreatedAt, &request.DecidedAt, &request.TransactionID)
+	if err != nil {
+		return request, err
+	}
+
+	request.Approvals, err = loadApprovals(context, request.ID)
+	return request, err
+}
+
+func loadApprovals(context sqlAdapter, requestID int64) ([]Approval, error) {
+	approvals := []Approval{}
+
+	rows, err := context.Query("SELECT id, request_id, user_id, decision, created_at FROM approvals WHERE request_id = $1 ORDER BY id ASC", requestID)
+	if err != nil {
+		return approvals, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		a := Approval{}
+		if err = rows.Scan(&a.ID, &a.RequestID, &a.UserID, &a.Decision, &a.CreatedAt); err != nil {
+			return approvals, err
+		}
+		approvals = append(approvals, a)
+	}
+
+	return approvals, rows.Err()
+}
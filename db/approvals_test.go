@@ -0,0 +1,57 @@
+package db
+
+import "testing"
+
+func TestNeedsApproval(t *testing.T) {
+	context := &APIContext{}
+	if context.NeedsApproval(1 << 40) {
+		t.Error("approvals should be disabled without a threshold")
+	}
+	if n := context.RequiredApprovals(); n != DEFAULT_REQUIRED_APPROVALS {
+		t.Errorf("expected %d required approvals, got %d", DEFAULT_REQUIRED_APPROVALS, n)
+	}
+
+	context.Config.Processing.ApprovalThreshold = 100000
+	context.Config.Processing.RequiredApprovals = 3
+
+	tests := []struct {
+		amount int64
+		needed bool
+	}{
+		{99999, false},
+		{100000, true},
+		{-100000, true},
+		{-99999, false},
+	}
+	for _, test := range tests {
+		if needed := context.NeedsApproval(test.amount); needed != test.needed {
+			t.Errorf("%d: expected %v, got %v", test.amount, test.needed, needed)
+		}
+	}
+	if n := context.RequiredApprovals(); n != 3 {
+		t.Errorf("expected 3 required approvals, got %d", n)
+	}
+}
+
+func TestCheckExportable(t *testing.T) {
+	context := &APIContext{}
+	context.Config.Processing.ApprovalThreshold = 100000
+
+	tests := []struct {
+		payment Payment
+		err     error
+	}{
+		{Payment{Amount: 500, State: PAYMENT_STATE_PROCESSED}, ErrPaymentNotExportable},
+		{Payment{Amount: -500, State: PAYMENT_STATE_PROCESSED}, nil},
+		{Payment{Amount: -500, State: PAYMENT_STATE_MATCHED}, ErrPaymentNotExportable},
+		{Payment{Amount: -500, State: PAYMENT_STATE_APPROVED}, ErrPaymentNotExportable},
+		{Payment{Amount: -100000, State: PAYMENT_STATE_MATCHED}, ErrPaymentNotExportable},
+		{Payment{Amount: -100000, State: PAYMENT_STATE_REFUNDED}, ErrPaymentNotExportable},
+	}
+	for _, test := range tests {
+		// none of these cases need to look up approvals
+		if err := context.checkExportable(nil, test.payment); err != test.err {
+			t.Errorf("%d %s: expected %v, got %v", test.payment.Amount, test.payment.State, test.err, err)
+		}
+	}
+}
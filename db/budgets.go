@@ -19,14 +19,15 @@ type Budget struct {
 	Description    string
 	Private        bool
 	PrivateBalance bool
+	Currency       string
 }
 
 // LoadBudgetByID loads a budget by UUID from the database
 func (context *APIContext) LoadBudgetByID(id int64) (Budget, error) {
 	budget := Budget{}
 
-	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance FROM budgets WHERE id = $1", id).
-		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance)
+	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance, currency FROM budgets WHERE id = $1", id).
+		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance, &budget.Currency)
 
 	if !budget.HasAccess(context.Auth) {
 		return Budget{}, errors.New("No such budget")
@@ -41,8 +42,8 @@ func (context *APIContext) LoadBudgetByUUID(uuid string) (Budget, error) {
 		return budget, ErrInvalidID
 	}
 
-	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance FROM budgets WHERE uuid = $1", uuid).
-		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance)
+	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance, currency FROM budgets WHERE uuid = $1", uuid).
+		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance, &budget.Currency)
 
 	if !budget.HasAccess(context.Auth) {
 		return Budget{}, errors.New("No such budget")
@@ -57,8 +58,8 @@ func (context *APIContext) LoadRootBudgetForProject(project *Project) (Budget, e
 		return budget, ErrInvalidID
 	}
 
-	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance FROM budgets WHERE project_id = $1 AND parent = 0 ORDER BY id ASC", project.ID).
-		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance)
+	err := context.QueryRow("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance, currency FROM budgets WHERE project_id = $1 AND parent = 0 ORDER BY id ASC", project.ID).
+		Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance, &budget.Currency)
 
 	if !budget.HasAccess(context.Auth) {
 		return Budget{}, errors.New("No such budget")
@@ -70,7 +71,7 @@ func (context *APIContext) LoadRootBudgetForProject(project *Project) (Budget, e
 func (context *APIContext) LoadBudgets(project *Project) ([]Budget, error) {
 	budgets := []Budget{}
 
-	rows, err := context.Query("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance FROM budgets WHERE project_id = $1 AND parent = 0 ORDER BY id ASC", project.ID)
+	rows, err := context.Query("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance, currency FROM budgets WHERE project_id = $1 AND parent = 0 ORDER BY id ASC", project.ID)
 	if err != nil {
 		return budgets, err
 	}
@@ -78,7 +79,7 @@ func (context *APIContext) LoadBudgets(project *Project) ([]Budget, error) {
 	defer rows.Close()
 	for rows.Next() {
 		budget := Budget{}
-		err = rows.Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance)
+		err = rows.Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance, &budget.Currency)
 		if err != nil {
 			return budgets, err
 		}
@@ -112,7 +113,7 @@ func (context *APIContext) GetBudgetByUUID(uuid string) (Budget, error) {
 func (context *APIContext) LoadAllBudgets() ([]Budget, error) {
 	budgets := []Budget{}
 
-	rows, err := context.Query("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance FROM budgets")
+	rows, err := context.Query("SELECT id, uuid, project_id, user_id, parent, name, description, private, private_balance, currency FROM budgets")
 	if err != nil {
 		return budgets, err
 	}
@@ -120,7 +121,7 @@ func (context *APIContext) LoadAllBudgets() ([]Budget, error) {
 	defer rows.Close()
 	for rows.Next() {
 		budget := Budget{}
-		err = rows.Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance)
+		err = rows.Scan(&budget.ID, &budget.UUID, &budget.ProjectID, &budget.UserID, &budget.ParentID, &budget.Name, &budget.Description, &budget.Private, &budget.PrivateBalance, &budget.Currency)
 		if err != nil {
 			return budgets, err
 		}
@@ -136,8 +137,8 @@ func (context *APIContext) LoadAllBudgets() ([]Budget, error) {
 
 // Update a budget in the database
 func (budget *Budget) Update(context *APIContext) error {
-	_, err := context.Exec("UPDATE budgets SET project_id = $1, user_id = $2, parent = $3, name = $4, description = $5, private = $6, private_balance = $7 WHERE id = $8",
-		budget.ProjectID, budget.UserID, budget.ParentID, budget.Name, budget.Description, budget.Private, budget.PrivateBalance, budget.ID)
+	_, err := context.Exec("UPDATE budgets SET project_id = $1, user_id = $2, parent = $3, name = $4, description = $5, private = $6, private_balance = $7, currency = $8 WHERE id = $9",
+		budget.ProjectID, budget.UserID, budget.ParentID, budget.Name, budget.Description, budget.Private, budget.PrivateBalance, budget.Currency, budget.ID)
 	budgetsCache.Delete(budget.UUID)
 	return err
 }
@@ -145,9 +146,12 @@ func (budget *Budget) Update(context *APIContext) error {
 // Save a budget to the database
 func (budget *Budget) Save(context *APIContext) error {
 	budget.UUID, _ = UUID()
+	if budget.Currency == "" {
+		budget.Currency = DefaultCurrency
+	}
 
-	err := context.QueryRow("INSERT INTO budgets (uuid, project_id, user_id, parent, name, description, private, private_balance) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
-		budget.UUID, budget.ProjectID, budget.UserID, budget.ParentID, budget.Name, budget.Description, budget.Private, budget.PrivateBalance).Scan(&budget.ID)
+	err := context.QueryRow("INSERT INTO budgets (uuid, project_id, user_id, parent, name, description, private, private_balance, currency) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		budget.UUID, budget.ProjectID, budget.UserID, budget.ParentID, budget.Name, budget.Description, budget.Private, budget.PrivateBalance, budget.Currency).Scan(&budget.ID)
 	budgetsCache.Delete(budget.UUID)
 	return err
 }
@@ -204,11 +208,11 @@ func (budget *Budget) BalanceStats(context *APIContext) ([]int64, error) {
 }
 
 func (budget *Budget) HasAccess(user *User) bool {
-	return !budget.Private || user.ID == 1 || (budget.UserID != nil && user.ID == *budget.UserID)
+	return !budget.Private || user.Can(PERMISSION_VIEW_PRIVATE, budget.ProjectID) || (user != nil && budget.UserID != nil && user.ID == *budget.UserID)
 }
 
 func (budget *Budget) HasTransactionAccess(user *User) bool {
-	return !budget.PrivateBalance || user.ID == 1 || (budget.UserID != nil && user.ID == *budget.UserID)
+	return !budget.PrivateBalance || user.Can(PERMISSION_VIEW_PRIVATE, budget.ProjectID) || (user != nil && budget.UserID != nil && user.ID == *budget.UserID)
 }
 
 // SearchBudgets searches database for budgets
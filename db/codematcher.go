@@ -0,0 +1,168 @@
+package db
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// AUTO_MATCH_CONFIDENCE is the confidence a match needs for its code to
+	// get assigned to a payment automatically
+	AUTO_MATCH_CONFIDENCE = 0.9
+	// MAX_CODE_MATCHES limits how many codes get proposed for a payment
+	MAX_CODE_MATCHES = 3
+	// MIN_TRUNCATED_CODE_LENGTH is how much of a code needs to be left when a
+	// bank truncated it
+	MIN_TRUNCATED_CODE_LENGTH = 5
+)
+
+// CodeMatch is a code proposed for a payment, with a confidence between 0
+// and 1
+type CodeMatch struct {
+	Code       string
+	Confidence float64
+}
+
+// CodeMatcher finds codes in the purposes of payments. Banks change the case
+// of purposes, break them into lines and truncate them, so matches don't need
+// to be exact
+type CodeMatcher struct {
+	// codes maps the upper case form of codes to how they're stored
+	codes map[string]string
+}
+
+// NewCodeMatcher returns a matcher for a set of codes
+func NewCodeMatcher(codes []string) *CodeMatcher {
+	m := &CodeMatcher{codes: make(map[string]string)}
+	for _, c := range codes {
+		if len(c) > 0 {
+			m.codes[strings.ToUpper(c)] = c
+		}
+	}
+	return m
+}
+
+// NewCodeMatcher returns a matcher for all codes in the database
+func (context *APIContext) NewCodeMatcher() (*CodeMatcher, error) {
+	codes, err := context.LoadAllCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []string
+	for _, c := range codes {
+		cs = append(cs, c.Code)
+	}
+	return NewCodeMatcher(cs), nil
+}
+
+// Match returns the codes a purpose most likely refers to, best match first
+func (m *CodeMatcher) Match(purpose string) []CodeMatch {
+	words := strings.FieldsFunc(strings.ToUpper(purpose), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	// codes broken up by spaces or line breaks show up in the joined words
+	joined := strings.Join(words, "")
+
+	matches := []CodeMatch{}
+	for upper, c := range m.codes {
+		if confidence := matchCode(upper, words, joined); confidence > 0 {
+			matches = append(matches, CodeMatch{Code: c, Confidence: confidence})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Confidence != matches[j].Confidence {
+			return matches[i].Confidence > matches[j].Confidence
+		}
+		return matches[i].Code < matches[j].Code
+	})
+	if len(matches) > MAX_CODE_MATCHES {
+		matches = matches[:MAX_CODE_MATCHES]
+	}
+	return matches
+}
+
+// Assign returns the code to assign to a payment automatically. That's only
+// the case for a single match with at least AUTO_MATCH_CONFIDENCE
+func (m *CodeMatcher) Assign(purpose string) (string, bool) {
+	matches := m.Match(purpose)
+	if len(matches) == 0 || matches[0].Confidence < AUTO_MATCH_CONFIDENCE {
+		return "", false
+	}
+	if len(matches) > 1 && matches[1].Confidence == matches[0].Confidence {
+		return "", false
+	}
+	return matches[0].Code, true
+}
+
+// matchCode rates how well a code matches the words of a purpose
+func matchCode(code string, words []string, joined string) float64 {
+	for _, w := range words {
+		if w == code {
+			return 1
+		}
+	}
+	if strings.Contains(joined, code) {
+		return 0.9
+	}
+
+	// banks truncate purposes that exceed their field length
+	for n := len(code) - 1; n >= MIN_TRUNCATED_CODE_LENGTH; n-- {
+		if strings.HasSuffix(joined, code[:n]) {
+			return math.Round(80*float64(n)/float64(len(code))) / 100
+		}
+	}
+
+	// typos, only close enough to be proposed
+	best := 0.0
+	for _, w := range words {
+		if len(w) < len(code)-1 || len(w) > len(code)+1 {
+			continue
+		}
+		switch levenshtein(w, code) {
+		case 1:
+			best = 0.6
+		case 2:
+			if best < 0.4 {
+				best = 0.4
+			}
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
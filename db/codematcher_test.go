@@ -0,0 +1,58 @@
+package db
+
+import "testing"
+
+func TestCodeMatcher(t *testing.T) {
+	m := NewCodeMatcher([]string{"ACDEFHJK", "LMNPRSTU", "WXY34693", "ACDEFXYZ"})
+
+	tests := []struct {
+		purpose    string
+		code       string
+		confidence float64
+		assigned   bool
+	}{
+		{"Donation ACDEFHJK", "ACDEFHJK", 1, true},
+		{"spende lmnprstu danke", "LMNPRSTU", 1, true},
+		{"Code:WXY34693.", "WXY34693", 1, true},
+		// line breaks & spaces within codes
+		{"SVWZ+Spende WXY34\n693 Danke", "WXY34693", 0.9, true},
+		{"L M N P R S T U", "LMNPRSTU", 0.9, true},
+		// truncated codes only get proposed
+		{"Spende fuer das Projekt LMNPRST", "LMNPRSTU", 0.7, false},
+		// typos only get proposed
+		{"Donation LMNPRSTV", "LMNPRSTU", 0.6, false},
+		{"nothing to see here", "", 0, false},
+	}
+
+	for _, test := range tests {
+		matches := m.Match(test.purpose)
+		if len(test.code) == 0 {
+			if len(matches) > 0 {
+				t.Errorf("%q: expected no match, got %v", test.purpose, matches)
+			}
+			continue
+		}
+
+		if len(matches) == 0 || matches[0].Code != test.code {
+			t.Errorf("%q: expected %s, got %v", test.purpose, test.code, matches)
+			continue
+		}
+		if matches[0].Confidence != test.confidence {
+			t.Errorf("%q: expected confidence %v, got %v", test.purpose, test.confidence, matches[0].Confidence)
+		}
+
+		code, ok := m.Assign(test.purpose)
+		if ok != test.assigned || (ok && code != test.code) {
+			t.Errorf("%q: expected assignment %v, got %s %v", test.purpose, test.assigned, code, ok)
+		}
+	}
+
+	// an ambiguous truncation doesn't pick either code
+	matches := m.Match("Spende ACDEF")
+	if len(matches) != 2 || matches[0].Confidence != matches[1].Confidence {
+		t.Errorf("expected two equally likely matches, got %v", matches)
+	}
+	if _, ok := m.Assign("ACDEFHJK ACDEFXYZ"); ok {
+		t.Error("assigned one of two exact matches")
+	}
+}
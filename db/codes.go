@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"errors"
 	"sort"
 	"strconv"
@@ -50,11 +51,12 @@ func (context *APIContext) LoadCodeByBudgetUUID(budgetID string) (Code, error) {
 	return context.LoadCodeByBudgetsAndRatios([]string{budgetID}, []string{"100"}, "")
 }
 
-// LoadCodeByBudgetsAndRatios loads a code by budgetIDs and their ratios from the database
-func (context *APIContext) LoadCodeByBudgetsAndRatios(budgetIDs, ratios StringSlice, userID string) (Code, error) {
-	code := Code{}
+// normalizeCodeKey validates a set of budgets & ratios and turns it into the
+// sorted form codes are stored with
+func (context *APIContext) normalizeCodeKey(budgetIDs, ratios StringSlice, userID string) (StringSlice, StringSlice, User, error) {
+	var user User
 	if len(budgetIDs) != len(ratios) {
-		return code, ErrInvalidBudgetRatioSet
+		return nil, nil, user, ErrInvalidBudgetRatioSet
 	}
 
 	// make sure proper ratios have been submitted
@@ -62,33 +64,68 @@ func (context *APIContext) LoadCodeByBudgetsAndRatios(budgetIDs, ratios StringSl
 	for _, ratio := range ratios {
 		r, err := strconv.Atoi(ratio)
 		if err != nil {
-			return code, ErrInvalidRatio
+			return nil, nil, user, ErrInvalidRatio
 		}
 
 		totalRatio += r
 	}
 	if totalRatio != 100 {
-		return code, ErrInvalidRatio
+		return nil, nil, user, ErrInvalidRatio
 	}
 
 	var bids StringSlice
 	for _, bid := range budgetIDs {
 		budget, err := context.GetBudgetByUUID(bid)
 		if err != nil {
-			panic(err)
+			return nil, nil, user, err
 		}
 		bids = append(bids, strconv.FormatInt(budget.ID, 10))
 	}
 
 	// user may be empty
-	var user User
 	if userID != "" {
 		user, _ = context.GetUserByUUID(userID)
 	}
 
 	// sort budgets & ratios
+	ratios = append(StringSlice{}, ratios...)
 	sort.Sort(BudgetSorter(BudgetRatioPair{bids, ratios}))
 
+	return bids, ratios, user, nil
+}
+
+// FindCodeByBudgetsAndRatios loads an existing code by budgetIDs and their
+// ratios from the database
+func (context *APIContext) FindCodeByBudgetsAndRatios(budgetIDs, ratios StringSlice, userID string) (Code, error) {
+	code := Code{}
+	bids, ratios, user, err := context.normalizeCodeKey(budgetIDs, ratios, userID)
+	if err != nil {
+		return code, err
+	}
+
+	if user.ID > 0 {
+		err = context.QueryRow("SELECT id, code, budget_ids, ratios, user_id FROM codes WHERE budget_ids = $1 AND ratios = $2 AND user_id = $3", bids, ratios, user.ID).
+			Scan(&code.ID, &code.Code, &code.BudgetIDs, &code.Ratios, &code.UserID)
+	} else {
+		err = context.QueryRow("SELECT id, code, budget_ids, ratios FROM codes WHERE budget_ids = $1 AND ratios = $2 AND user_id IS NULL", bids, ratios).
+			Scan(&code.ID, &code.Code, &code.BudgetIDs, &code.Ratios)
+	}
+
+	return code, err
+}
+
+// LoadCodeByBudgetsAndRatios loads a code by budgetIDs and their ratios from
+// the database. The code gets created if it doesn't exist yet
+func (context *APIContext) LoadCodeByBudgetsAndRatios(budgetIDs, ratios StringSlice, userID string) (Code, error) {
+	code, err := context.FindCodeByBudgetsAndRatios(budgetIDs, ratios, userID)
+	if err != sql.ErrNoRows {
+		return code, err
+	}
+
+	bids, ratios, user, err := context.normalizeCodeKey(budgetIDs, ratios, userID)
+	if err != nil {
+		return code, err
+	}
 	code = Code{
 		BudgetIDs: bids,
 		Ratios:    ratios,
@@ -97,7 +134,7 @@ func (context *APIContext) LoadCodeByBudgetsAndRatios(budgetIDs, ratios StringSl
 
 	codes, err := context.LoadAllCodes()
 	if err != nil {
-		panic(err)
+		return code, err
 	}
 	tokens := []string{}
 	for _, code := range codes {
@@ -113,22 +150,13 @@ func (context *APIContext) LoadCodeByBudgetsAndRatios(budgetIDs, ratios StringSl
 	}
 
 	if user.ID > 0 {
-		err = context.QueryRow("SELECT id, code, budget_ids, ratios, user_id FROM codes WHERE budget_ids = $1 AND ratios = $2 AND user_id = $3", bids, ratios, user.ID).
-			Scan(&code.ID, &code.Code, &code.BudgetIDs, &code.Ratios, &code.UserID)
+		err = context.QueryRow("INSERT INTO codes (code, budget_ids, ratios, user_id) VALUES ($1, $2, $3, $4) RETURNING id",
+			code.Code, code.BudgetIDs, code.Ratios, code.UserID).Scan(&code.ID)
 	} else {
-		err = context.QueryRow("SELECT id, code, budget_ids, ratios FROM codes WHERE budget_ids = $1 AND ratios = $2 AND user_id IS NULL", bids, ratios).
-			Scan(&code.ID, &code.Code, &code.BudgetIDs, &code.Ratios)
-	}
-	if err != nil {
-		if user.ID > 0 {
-			err = context.QueryRow("INSERT INTO codes (code, budget_ids, ratios, user_id) VALUES ($1, $2, $3, $4) RETURNING id",
-				code.Code, code.BudgetIDs, code.Ratios, code.UserID).Scan(&code.ID)
-		} else {
-			err = context.QueryRow("INSERT INTO codes (code, budget_ids, ratios, user_id) VALUES ($1, $2, $3, null) RETURNING id",
-				code.Code, code.BudgetIDs, code.Ratios).Scan(&code.ID)
-		}
-		codesCache.Delete(code.ID)
+		err = context.QueryRow("INSERT INTO codes (code, budget_ids, ratios, user_id) VALUES ($1, $2, $3, null) RETURNING id",
+			code.Code, code.BudgetIDs, code.Ratios).Scan(&code.ID)
 	}
+	codesCache.Delete(code.ID)
 
 	return code, err
 }
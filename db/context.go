@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"net/http"
 	"strings"
 	"time"
 
@@ -23,7 +24,7 @@ type APIContext struct {
 	Auth *User
 }
 
-// APIContextTx is a transactional API conteollyxt
+// APIContextTx is a transactional API context
 type APIContextTx struct {
 	id      int
 	context *APIContext
@@ -54,7 +55,9 @@ func (context *APIContext) NewAPIContext() smolder.APIContext {
 	return ctx
 }
 
-// Authentication parses the request for an access-/authtoken and returns the matching user
+// Authentication parses the request for an access-/authtoken and returns the
+// matching user. Expired or revoked sessions are rejected, as are modifying
+// requests made with a read-only session
 func (context *APIContext) Authentication(request *restful.Request) (interface{}, error) {
 	t := request.QueryParameter("accesstoken")
 	if len(t) == 0 {
@@ -64,7 +67,17 @@ func (context *APIContext) Authentication(request *restful.Request) (interface{}
 		}
 	}
 
-	return context.GetUserByAccessToken(t)
+	auth, err := context.GetUserByAccessToken(t)
+	if err != nil {
+		return auth, err
+	}
+
+	user := auth.(User)
+	if request.Request.Method != http.MethodGet && !user.Session.HasScope(SCOPE_WRITE) {
+		return User{}, ErrInsufficientScope
+	}
+
+	return user, nil
 }
 
 func (context *APIContext) SetAuth(auth interface{}) {
@@ -192,40 +205,18 @@ func (hTx *APIContextTx) Rollback() error {
 	return err
 }
 
-func (hTx *APIContextTx) commitOrRollbackOnError(err *error) {
-	if p := recover(); p != nil {
-		hTx.Rollback()
-		panic(*err)
-	}
-
-	if *err != nil {
-		innerErr := hTx.Rollback()
-		if innerErr != nil {
-			panic(innerErr)
-		}
-		return
-	}
-
-	innerErr := hTx.Commit()
-	if innerErr != nil {
-		panic(innerErr)
-	}
-}
-
-/*
+// Transact runs txFunc in a new transaction. The transaction gets committed
+// when txFunc succeeds and rolled back when it returns an error or panics
 func (context *APIContext) Transact(txFunc func(*APIContextTx) error) (err error) {
 	tx, err := context.Begin()
 	if err != nil {
-		return
+		return err
 	}
+
 	defer func() {
 		if p := recover(); p != nil {
-			switch p := p.(type) {
-			case error:
-				err = p
-			default:
-				err = fmt.Errorf("%s", p)
-			}
+			tx.Rollback()
+			panic(p)
 		}
 		if err != nil {
 			tx.Rollback()
@@ -233,6 +224,11 @@ func (context *APIContext) Transact(txFunc func(*APIContextTx) error) (err error
 		}
 		err = tx.Commit()
 	}()
+
 	return txFunc(tx)
 }
-*/
+
+// Context returns the API context this transaction was started from
+func (hTx *APIContextTx) Context() *APIContext {
+	return hTx.context
+}
@@ -0,0 +1,63 @@
+package db
+
+// Contributor represents the db schema of a project contributor
+type Contributor struct {
+	ID        int64
+	UserID    int64
+	ProjectID int64
+	Role      Role
+}
+
+// LoadContributorByID loads a contributor by ID from the database
+func (context *APIContext) LoadContributorByID(id int64) (Contributor, error) {
+	contributor := Contributor{}
+	if id < 1 {
+		return contributor, ErrInvalidID
+	}
+
+	err := context.QueryRow("SELECT id, user_id, project_id, role FROM contributors WHERE id = $1", id).
+		Scan(&contributor.ID, &contributor.UserID, &contributor.ProjectID, &contributor.Role)
+	return contributor, err
+}
+
+// LoadContributors loads all contributors of a project, including their roles
+func (project *Project) LoadContributors(context *APIContext) ([]Contributor, error) {
+	contributors := []Contributor{}
+
+	rows, err := context.Query("SELECT id, user_id, project_id, role FROM contributors WHERE project_id = $1 ORDER BY id ASC", project.ID)
+	if err != nil {
+		return contributors, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		contributor := Contributor{}
+		err = rows.Scan(&contributor.ID, &contributor.UserID, &contributor.ProjectID, &contributor.Role)
+		if err != nil {
+			return contributors, err
+		}
+
+		contributors = append(contributors, contributor)
+	}
+
+	return contributors, err
+}
+
+// Save adds a contributor to a project or updates their role
+func (contributor *Contributor) Save(context *APIContext) error {
+	if !ValidProjectRole(contributor.Role) {
+		return ErrInvalidRole
+	}
+
+	err := context.QueryRow("INSERT INTO contributors (user_id, project_id, role) VALUES ($1, $2, $3) "+
+		"ON CONFLICT (user_id, project_id) DO UPDATE SET role = EXCLUDED.role "+
+		"RETURNING id",
+		contributor.UserID, contributor.ProjectID, contributor.Role).Scan(&contributor.ID)
+	return err
+}
+
+// Delete removes a contributor from a project
+func (contributor *Contributor) Delete(context *APIContext) error {
+	_, err := context.Exec("DELETE FROM contributors WHERE id = $1", contributor.ID)
+	return err
+}
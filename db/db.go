@@ -15,8 +15,9 @@ import (
 )
 
 var (
-	// SchemaVersion needs to be incremented every time the SQL schema changes
-	SchemaVersion = 1
+	// SchemaVersion is the schema version this build requires, which is the
+	// version of the latest migration
+	SchemaVersion = migrations[len(migrations)-1].Version
 
 	pgDB     *sql.DB
 	pgConfig config.PostgreSQLConnection
@@ -59,192 +60,24 @@ func GetDatabase() *sql.DB {
 
 // InitDatabase sets up the database with all required tables and indexes
 func InitDatabase() {
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS config
-			(
-			  name		text	PRIMARY KEY,
-			  value		text
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS users
-			(
-			  id          	bigserial 	PRIMARY KEY,
-			  uuid			text		NOT NULL,
-			  email       	text		NOT NULL,
-			  nickname    	text      	NOT NULL,
-			  password		text		NOT NULL,
-			  about       	text		DEFAULT '',
-			  avatar		text		DEFAULT '',
-			  address		text[],
-			  zip			text		DEFAULT '',
-			  city			text		DEFAULT '',
-			  country		text		DEFAULT '',
-			  activated   	bool		DEFAULT false,
-			  authtoken   	text[]     	NOT NULL,
-			  CONSTRAINT  	uk_users_uuid 	UNIQUE (uuid),
-			  CONSTRAINT  	uk_users_email 	UNIQUE (email)
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS projects
-			(
-			  id          		bigserial 		PRIMARY KEY,
-			  uuid				text			NOT NULL,
-			  slug				text			NOT NULL,
-			  name       		text      		NOT NULL,
-			  summary			text			NOT NULL,
-			  about				text      		DEFAULT '',
-			  website      		text			DEFAULT '',
-			  license      		text			DEFAULT '',
-			  repository		text			DEFAULT '',
-			  logo				text			DEFAULT '',
-			  created_at		timestamp		NOT NULL,
-			  private			bool			DEFAULT false,
-			  private_balance	bool			DEFAULT true,
-			  processing_cut	int				DEFAULT 10,
-			  activated   		bool			DEFAULT false,
-			  user_id			int,
-			  CONSTRAINT  		uk_projects_uuid 		UNIQUE (uuid),
-			  CONSTRAINT  		uk_projects_slug 		UNIQUE (slug),
-			  CONSTRAINT  		uk_projects_repository	UNIQUE (repository),
-			  CONSTRAINT    	fk_projects_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS budgets
-			(
-			  id          		bigserial 	PRIMARY KEY,
-			  uuid				text		NOT NULL,
-			  project_id    	int,
-			  user_id			int,
-			  parent			bigserial,
-			  name       		text      	NOT NULL,
-			  description		text,
-			  private			bool		DEFAULT false,
-			  private_balance	bool		DEFAULT true,
-			  CONSTRAINT  		uk_budgets_uuid 		UNIQUE (uuid),
-			  CONSTRAINT    	fk_budgets_project_id	FOREIGN KEY (project_id) REFERENCES projects (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
-			  CONSTRAINT    	fk_budgets_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS payments
-			(
-			  id          			bigserial 		PRIMARY KEY,
-			  budget_id				bigserial   	NOT NULL,
-			  created_at			timestamp		NOT NULL,
-			  amount				int				NOT NULL,
-			  currency				text			NOT NULL,
-			  code					text			DEFAULT '',
-			  purpose				text			DEFAULT '',
-			  remote_account		text			NOT NULL,
-			  remote_name			text			NOT NULL,
-			  remote_transaction_id	text			DEFAULT '',
-			  remote_bank_id		text			DEFAULT '',
-			  source				text			NOT NULL,
-			  pending				bool			DEFAULT true,
-			  CONSTRAINT    		fk_payments_budget_id	FOREIGN KEY (budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS transactions
-			(
-			  id          		bigserial 		PRIMARY KEY,
-			  budget_id			bigserial   	NOT NULL,
-			  from_budget_id	int,
-			  to_budget_id		int,
-			  amount			int				NOT NULL,
-			  created_at		timestamp		NOT NULL,
-			  purpose			text,
-			  payment_id		int,
-			  CONSTRAINT    	fk_transactions_budget_id		FOREIGN KEY (budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
-			  CONSTRAINT    	fk_transactions_from_budget_id	FOREIGN KEY (from_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
-			  CONSTRAINT    	fk_transactions_to_budget_id	FOREIGN KEY (to_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
-			  CONSTRAINT    	fk_transactions_payment_id		FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS codes
-			(
-			  id			bigserial 		PRIMARY KEY,
-			  code			text      		NOT NULL,
-			  budget_ids   	int[]			NOT NULL,
-			  ratios		int[]			NOT NULL,
-			  user_id   	int,
-			  CONSTRAINT    uk_codes_code  		UNIQUE (code),
-			  CONSTRAINT    uk_codes_budget_ids	UNIQUE (budget_ids, ratios, user_id),
-			  CONSTRAINT    fk_codes_user_id	FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
-			)`,
-
-		`CREATE TABLE IF NOT EXISTS contributors
-			(
-			  id			bigserial 		PRIMARY KEY,
-			  user_id   	int,
-			  project_id   	int,
-			  CONSTRAINT    uk_contributors_user_project	UNIQUE (user_id, project_id),
-			  CONSTRAINT    fk_contributors_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
-			  CONSTRAINT    fk_contributors_project_id	FOREIGN KEY (project_id) REFERENCES projects (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
-			)`,
-	}
-
-	// FIXME: add IF NOT EXISTS to CREATE INDEX statements (coming in v9.5)
-	// See: http://www.postgresql.org/docs/devel/static/sql-createindex.html
-	indexes := []string{
-		`CREATE INDEX idx_users_uuid ON users(uuid)`,
-		`CREATE INDEX idx_users_email ON users(email)`,
-		`CREATE INDEX idx_users_authtoken ON users(authtoken)`,
-		`CREATE INDEX idx_projects_uuid ON projects(uuid)`,
-		`CREATE INDEX idx_projects_slug ON projects(slug)`,
-		`CREATE INDEX idx_projects_name ON projects(name)`,
-		`CREATE INDEX idx_budgets_uuid ON budgets(uuid)`,
-		`CREATE INDEX idx_budgets_name ON budgets(name)`,
-		`CREATE INDEX idx_budgets_project_id ON budgets(project_id)`,
-		`CREATE INDEX idx_codes_code ON codes(code)`,
-		`CREATE INDEX idx_payments_budget_id ON payments(budget_id)`,
-		`CREATE INDEX idx_payments_created_at ON payments(created_at)`,
-		`CREATE INDEX idx_transactions_budget_id ON transactions(budget_id)`,
-		`CREATE INDEX idx_transactions_from_budget_id ON transactions(from_budget_id)`,
-		`CREATE INDEX idx_transactions_created_at ON transactions(created_at)`,
-		`CREATE INDEX idx_contributors_project_id ON contributors(project_id)`,
-	}
-
-	for _, v := range tables {
-		fmt.Println("Creating table:", v)
-		_, err := pgDB.Exec(v)
-		if err != nil {
-			panic(err)
-		}
-	}
-	for _, v := range indexes {
-		fmt.Println("Creating index:", v)
-		_, err := pgDB.Exec(v)
-		if err != nil && strings.Index(err.Error(), "already exists") < 0 {
-			fmt.Println("Error:", err)
-		}
+	if err := MigrateDatabase(SchemaVersion, false); err != nil {
+		panic(err)
 	}
 }
 
 // WipeDatabase drops all database tables - use carefully!
 func WipeDatabase() {
-	drops := []string{
-		`DROP TABLE codes`,
-		`DROP TABLE contributors`,
-		`DROP TABLE payments`,
-		`DROP TABLE transactions`,
-		`DROP TABLE budgets`,
-		`DROP TABLE projects`,
-		`DROP TABLE users`,
+	if err := MigrateDatabase(0, false); err != nil {
+		panic(err)
 	}
 
-	for _, v := range drops {
-		fmt.Println("Dropping table:", v)
-		_, err := pgDB.Exec(v)
-		if err != nil {
-			panic(err)
-		}
+	fmt.Println("Dropping table: config")
+	_, err := pgDB.Exec(`DROP TABLE IF EXISTS config`)
+	if err != nil {
+		panic(err)
 	}
 }
 
-// FIXME
-func migrateDatabase(from, to int) error {
-	return nil
-}
-
 // UUID returns a new unique identifier
 func UUID() (string, error) {
 	u := uuid.NewV4()
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DONOR_ACCOUNT_TOKEN_PREFIX starts the tokens donors verify their bank
+// accounts with
+const DONOR_ACCOUNT_TOKEN_PREFIX = "DONOR-"
+
+var (
+	// ErrInvalidAccount is the error returned when claiming an empty account
+	ErrInvalidAccount = errors.New("Invalid account")
+	// ErrAccountClaimed is the error returned when claiming an account that
+	// another user has already verified
+	ErrAccountClaimed = errors.New("This account has already been verified by another user")
+)
+
+// DonorAccount represents the db schema of a bank account a user claims to
+// donate from. Payments from it get linked to the user once they transferred
+// a donation with the account's token in its purpose
+type DonorAccount struct {
+	ID         int64
+	UserID     int64
+	Account    string
+	Token      string
+	CreatedAt  time.Time
+	VerifiedAt *time.Time
+}
+
+// DonationTotal is the sum of a donor's donations in a currency
+type DonationTotal struct {
+	Currency string
+	Amount   int64
+}
+
+// ProjectDonation is the part of a donor's donations a project received
+type ProjectDonation struct {
+	ProjectID int64
+	Currency  string
+	Amount    int64
+}
+
+// normalizeAccount removes the spaces account numbers are often printed with
+// and turns them into upper case
+func normalizeAccount(account string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(account), ""))
+}
+
+// ClaimDonorAccount starts verifying that a user donates from a bank account.
+// Claiming the same account again returns the pending claim
+func (user *User) ClaimDonorAccount(context *APIContext, account string) (DonorAccount, error) {
+	da := DonorAccount{}
+	account = normalizeAccount(account)
+	if len(account) == 0 {
+		return da, ErrInvalidAccount
+	}
+
+	var owner int64
+	err := context.QueryRow("SELECT user_id FROM donor_accounts WHERE account = $1 AND verified_at IS NOT NULL", account).Scan(&owner)
+	if err == nil && owner != user.ID {
+		return da, ErrAccountClaimed
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return da, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return da, err
+	}
+
+	_, err = context.Exec("INSERT INTO donor_accounts (user_id, account, token, created_at) VALUES ($1, $2, $3, $4) "+
+		"ON CONFLICT (user_id, account) DO NOTHING",
+		user.ID, account, DONOR_ACCOUNT_TOKEN_PREFIX+strings.ToUpper(token[:10]), time.Now().UTC())
+	if err != nil {
+		return da, err
+	}
+
+	err = context.QueryRow("SELECT id, user_id, account, token, created_at, verified_at FROM donor_accounts WHERE user_id = $1 AND account = $2", user.ID, account).
+		Scan(&da.ID, &da.UserID, &da.Account, &da.Token, &da.CreatedAt, &da.VerifiedAt)
+	return da, err
+}
+
+// LoadDonorAccounts loads all bank accounts a user claimed
+func (user *User) LoadDonorAccounts(context *APIContext) ([]DonorAccount, error) {
+	accounts := []DonorAccount{}
+
+	rows, err := context.Query("SELECT id, user_id, account, token, created_at, verified_at FROM donor_accounts WHERE user_id = $1 ORDER BY created_at ASC", user.ID)
+	if err != nil {
+		return accounts, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		da := DonorAccount{}
+		if err = rows.Scan(&da.ID, &da.UserID, &da.Account, &da.Token, &da.CreatedAt, &da.VerifiedAt); err != nil {
+			return accounts, err
+		}
+		accounts = append(accounts, da)
+	}
+
+	return accounts, rows.Err()
+}
+
+// donorForPayment returns the user who made an incoming payment, known by
+// the email address of their activated account or a verified bank account.
+// Email addresses only count if the payment source verified them: anybody
+// can enter someone else's address when paying
+func donorForPayment(tx *APIContextTx, payment *Payment) (*int64, error) {
+	if payment.Amount <= 0 {
+		return nil, nil
+	}
+
+	var id int64
+	if len(payment.RemoteEmail) > 0 && payment.RemoteEmailVerified {
+		err := tx.QueryRow("SELECT id FROM users WHERE LOWER(email) = LOWER($1) AND activated ORDER BY id LIMIT 1", payment.RemoteEmail).Scan(&id)
+		if err == nil {
+			return &id, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if len(payment.RemoteAccount) > 0 {
+		err := tx.QueryRow("SELECT user_id FROM donor_accounts WHERE account = $1 AND verified_at IS NOT NULL", normalizeAccount(payment.RemoteAccount)).Scan(&id)
+		if err == nil {
+			return &id, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// verifyDonorAccount verifies a pending claim of the account an incoming
+// payment was made from, if its purpose contains the claim's token. All
+// payments from the account that aren't linked to a donor yet get linked to
+// the claiming user
+func verifyDonorAccount(tx *APIContextTx, payment *Payment) error {
+	account := normalizeAccount(payment.RemoteAccount)
+	if payment.Amount <= 0 || len(account) == 0 {
+		return nil
+	}
+
+	// tokens may be broken up by line breaks in the purpose
+	purpose := normalizeAccount(payment.Purpose)
+	if !strings.Contains(purpose, DONOR_ACCOUNT_TOKEN_PREFIX) {
+		return nil
+	}
+
+	var id, userID int64
+	var token string
+	rows, err := tx.Query("SELECT id, user_id, token FROM donor_accounts WHERE account = $1 AND verified_at IS NULL", account)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var cid, cuser int64
+		var ctoken string
+		if err = rows.Scan(&cid, &cuser, &ctoken); err != nil {
+			rows.Close()
+			return err
+		}
+		if strings.Contains(purpose, ctoken) {
+			id, userID, token = cid, cuser, ctoken
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || id == 0 {
+		return err
+	}
+
+	// another user may have verified the account in the meantime
+	res, err := tx.Exec("UPDATE donor_accounts SET verified_at = $1 WHERE id = $2 "+
+		"AND NOT EXISTS (SELECT 1 FROM donor_accounts WHERE account = $3 AND verified_at IS NOT NULL)",
+		time.Now().UTC(), id, account)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		log.WithFields(log.Fields{
+			"Payment": payment.ID,
+			"Token":   token,
+		}).Warn("Donor account has already been verified by another user")
+		return nil
+	}
+
+	_, err = tx.Exec("UPDATE payments SET user_id = $1 WHERE user_id IS NULL AND amount > 0 "+
+		"AND UPPER(REPLACE(remote_account, ' ', '')) = $2", userID, account)
+	if err != nil {
+		return err
+	}
+	payment.UserID = &userID
+	return nil
+}
+
+// LoadDonations loads a page of the incoming payments linked to a user
+func (user *User) LoadDonations(context *APIContext, filter PaymentFilter) (PaymentPage, error) {
+	filter.UserID = &user.ID
+	filter.Direction = TRANSACTION_INCOMING
+	return context.LoadPaymentPage(filter)
+}
+
+// LoadDonationTotals loads the sum of a user's donations that haven't been
+// refunded, per currency
+func (user *User) LoadDonationTotals(context *APIContext) ([]DonationTotal, error) {
+	totals := []DonationTotal{}
+
+	rows, err := context.Query("SELECT currency, SUM(amount) FROM payments "+
+		"WHERE user_id = $1 AND amount > 0 AND state <> 'refunded' "+
+		"GROUP BY currency ORDER BY currency ASC", user.ID)
+	if err != nil {
+		return totals, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		t := DonationTotal{}
+		if err = rows.Scan(&t.Currency, &t.Amount); err != nil {
+			return totals, err
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
+
+// LoadSupportedProjects loads the projects a user's processed donations got
+// distributed to, with the amounts each project's budgets received. Refunds
+// cancel out the amounts and processing cuts aren't included
+func (user *User) LoadSupportedProjects(context *APIContext) ([]ProjectDonation, error) {
+	projects := []ProjectDonation{}
+
+	rows, err := context.Query("SELECT budgets.project_id, transactions.currency, SUM(transactions.amount) "+
+		"FROM transactions "+
+		"JOIN budgets ON budgets.id = transactions.budget_id "+
+		"JOIN payments ON payments.id = transactions.payment_id "+
+		"WHERE payments.user_id = $1 AND payments.amount > 0 AND budgets.project_id IS NOT NULL "+
+		"AND transactions.budget_id <> payments.budget_id AND transactions.budget_id <> $2 "+
+		"GROUP BY budgets.project_id, transactions.currency "+
+		"HAVING SUM(transactions.amount) <> 0 "+
+		"ORDER BY budgets.project_id ASC, transactions.currency ASC", user.ID, context.Config.Processing.DonationCutBudget)
+	if err != nil {
+		return projects, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		p := ProjectDonation{}
+		if err = rows.Scan(&p.ProjectID, &p.Currency, &p.Amount); err != nil {
+			return projects, err
+		}
+		projects = append(projects, p)
+	}
+
+	return projects, rows.Err()
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/mailer"
+)
+
+// EmailDelivery represents the db schema of an outgoing email and its
+// delivery attempts
+type EmailDelivery struct {
+	ID            int64
+	PaymentID     *int64
+	Kind          string
+	Recipient     string
+	Subject       string
+	Text          string
+	HTML          string
+	CreatedAt     time.Time
+	Attempts      int
+	LastAttemptAt *time.Time
+	LastError     string
+	SentAt        *time.Time
+}
+
+// Kinds of emails whose delivery gets recorded
+const (
+	EMAIL_PAYMENT_CONFIRMATION = "payment_confirmation"
+)
+
+// MAX_EMAIL_DELIVERY_ATTEMPTS is the number of attempts after which an email
+// won't be retried anymore
+const MAX_EMAIL_DELIVERY_ATTEMPTS = 5
+
+// queueEmailDelivery stores an email for delivery. It returns false if an
+// email of the same kind has already been queued for the payment
+func (context *APIContext) queueEmailDelivery(delivery *EmailDelivery) (bool, error) {
+	delivery.CreatedAt = time.Now().UTC()
+
+	err := context.QueryRow("INSERT INTO email_deliveries (payment_id, kind, recipient, subject, text_body, html_body, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) "+
+		"ON CONFLICT (payment_id, kind) DO NOTHING "+
+		"RETURNING id",
+		delivery.PaymentID, delivery.Kind, delivery.Recipient, delivery.Subject, delivery.Text, delivery.HTML, delivery.CreatedAt).
+		Scan(&delivery.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// LoadUndeliveredEmails loads all emails that haven't been sent yet and can
+// still be retried
+func (context *APIContext) LoadUndeliveredEmails() ([]EmailDelivery, error) {
+	deliveries := []EmailDelivery{}
+
+	rows, err := context.Query("SELECT id, payment_id, kind, recipient, subject, text_body, html_body, created_at, "+
+		"attempts, last_attempt_at, last_error, sent_at "+
+		"FROM email_deliveries "+
+		"WHERE sent_at IS NULL AND attempts < $1 "+
+		"ORDER BY created_at ASC", MAX_EMAIL_DELIVERY_ATTEMPTS)
+	if err != nil {
+		return deliveries, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		delivery := EmailDelivery{}
+		err = rows.Scan(&delivery.ID, &delivery.PaymentID, &delivery.Kind, &delivery.Recipient, &delivery.Subject,
+			&delivery.Text, &delivery.HTML, &delivery.CreatedAt, &delivery.Attempts, &delivery.LastAttemptAt,
+			&delivery.LastError, &delivery.SentAt)
+		if err != nil {
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, err
+}
+
+// Deliver sends an email and records the attempt. The returned error is the
+// one the mail server responded with
+func (delivery *EmailDelivery) Deliver(context *APIContext) error {
+	serr := mailer.Send(context.Config.Connections.Email, mailer.Mail{
+		To:      delivery.Recipient,
+		Subject: delivery.Subject,
+		Text:    delivery.Text,
+		HTML:    delivery.HTML,
+	})
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastError = ""
+	if serr != nil {
+		delivery.LastError = serr.Error()
+	} else {
+		delivery.SentAt = &now
+	}
+
+	_, err := context.Exec("UPDATE email_deliveries SET attempts = attempts + 1, last_attempt_at = $1, last_error = $2, sent_at = $3 "+
+		"WHERE id = $4", now, delivery.LastError, delivery.SentAt, delivery.ID)
+	if serr != nil {
+		return serr
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	money "github.com/Rhymond/go-money"
+)
+
+// DefaultCurrency is used for budgets and transactions without an explicit currency
+const DefaultCurrency = "EUR"
+
+// ExchangeRate represents the db schema of an exchange rate
+type ExchangeRate struct {
+	ID           int64
+	FromCurrency string
+	ToCurrency   string
+	Rate         string
+	ValidFrom    time.Time
+	CreatedAt    time.Time
+}
+
+var (
+	// ErrNoExchangeRate is the error returned when no exchange rate is known for a pair of currencies
+	ErrNoExchangeRate = errors.New("No exchange rate available for this currency pair")
+	// ErrInvalidExchangeRate is the error returned when encountering an invalid exchange rate
+	ErrInvalidExchangeRate = errors.New("Exchange rate must be a positive decimal number")
+	// ErrInvalidCurrency is the error returned when encountering an unknown currency code
+	ErrInvalidCurrency = errors.New("Invalid currency code")
+	// ErrSameCurrency is the error returned for rates exchanging a currency
+	// with itself
+	ErrSameCurrency = errors.New("Can't exchange a currency with itself")
+	// ErrExchangeRateExists is the error returned when storing a rate that
+	// differs from the one already stored for its currencies & point in time
+	ErrExchangeRateExists = errors.New("A different exchange rate has already been stored for this currency pair and time")
+)
+
+// ValidCurrency returns true if code is a known ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	return money.GetCurrency(code) != nil
+}
+
+// LoadExchangeRateByID loads an exchange rate by ID from the database
+func (context *APIContext) LoadExchangeRateByID(id int64) (ExchangeRate, error) {
+	rate := ExchangeRate{}
+	if id < 1 {
+		return rate, ErrInvalidID
+	}
+
+	err := context.QueryRow("SELECT id, from_currency, to_currency, rate::text, valid_from, created_at "+
+		"FROM exchange_rates "+
+		"WHERE id = $1", id).
+		Scan(&rate.ID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate, &rate.ValidFrom, &rate.CreatedAt)
+
+	return rate, err
+}
+
+// LoadExchangeRate loads the rate for converting between two currencies that
+// was valid at the given time
+func (context *APIContext) LoadExchangeRate(from, to string, at time.Time) (ExchangeRate, error) {
+	return loadExchangeRate(context, from, to, at)
+}
+
+// loadExchangeRate looks up the direct rate first and falls back to the
+// inverse rate, so rates only need to be loaded for one direction
+func loadExchangeRate(db sqlAdapter, from, to string, at time.Time) (ExchangeRate, error) {
+	rate := ExchangeRate{}
+
+	err := db.QueryRow("SELECT id, from_currency, to_currency, rate::text, valid_from, created_at "+
+		"FROM exchange_rates "+
+		"WHERE ((from_currency = $1 AND to_currency = $2) OR (from_currency = $2 AND to_currency = $1)) AND valid_from <= $3 "+
+		"ORDER BY valid_from DESC, from_currency = $1 DESC LIMIT 1", from, to, at).
+		Scan(&rate.ID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate, &rate.ValidFrom, &rate.CreatedAt)
+	if err == sql.ErrNoRows {
+		return rate, ErrNoExchangeRate
+	}
+
+	return rate, err
+}
+
+// LoadExchangeRates loads all exchange rates, latest first
+func (context *APIContext) LoadExchangeRates(from, to string) ([]ExchangeRate, error) {
+	rates := []ExchangeRate{}
+
+	rows, err := context.Query("SELECT id, from_currency, to_currency, rate::text, valid_from, created_at "+
+		"FROM exchange_rates "+
+		"WHERE ($1 = '' OR from_currency = $1) AND ($2 = '' OR to_currency = $2) "+
+		"ORDER BY valid_from DESC, id DESC", from, to)
+	if err != nil {
+		return rates, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		rate := ExchangeRate{}
+		err = rows.Scan(&rate.ID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate, &rate.ValidFrom, &rate.CreatedAt)
+		if err != nil {
+			return rates, err
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, err
+}
+
+// Validate normalizes the currency codes of an exchange rate and checks that
+// the rate can be used for conversions
+func (rate *ExchangeRate) Validate() error {
+	rate.FromCurrency = strings.ToUpper(strings.TrimSpace(rate.FromCurrency))
+	rate.ToCurrency = strings.ToUpper(strings.TrimSpace(rate.ToCurrency))
+	rate.Rate = strings.TrimSpace(rate.Rate)
+
+	if !ValidCurrency(rate.FromCurrency) || !ValidCurrency(rate.ToCurrency) {
+		return ErrInvalidCurrency
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return ErrSameCurrency
+	}
+	_, err := rate.rat()
+	return err
+}
+
+// Save an exchange rate to the database. Stored rates never change, since
+// booked transactions refer to them: saving the same rate for a pair and
+// point in time again returns the stored one, a different rate fails with
+// ErrExchangeRateExists
+func (rate *ExchangeRate) Save(context sqlAdapter) error {
+	if err := rate.Validate(); err != nil {
+		return err
+	}
+	rate.CreatedAt = time.Now().UTC()
+
+	err := context.QueryRow("INSERT INTO exchange_rates (from_currency, to_currency, rate, valid_from, created_at) "+
+		"VALUES ($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (from_currency, to_currency, valid_from) DO NOTHING "+
+		"RETURNING id",
+		rate.FromCurrency, rate.ToCurrency, rate.Rate, rate.ValidFrom, rate.CreatedAt).Scan(&rate.ID)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	stored := ExchangeRate{}
+	err = context.QueryRow("SELECT id, from_currency, to_currency, rate::text, valid_from, created_at "+
+		"FROM exchange_rates "+
+		"WHERE from_currency = $1 AND to_currency = $2 AND valid_from = $3", rate.FromCurrency, rate.ToCurrency, rate.ValidFrom).
+		Scan(&stored.ID, &stored.FromCurrency, &stored.ToCurrency, &stored.Rate, &stored.ValidFrom, &stored.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if !stored.Equal(*rate) {
+		return ErrExchangeRateExists
+	}
+
+	*rate = stored
+	return nil
+}
+
+// Equal returns true if two rates convert at the same value, regardless of
+// how their rates are written
+func (rate *ExchangeRate) Equal(other ExchangeRate) bool {
+	r, err := rate.rat()
+	if err != nil {
+		return false
+	}
+	o, err := other.rat()
+	if err != nil {
+		return false
+	}
+	return r.Cmp(o) == 0
+}
+
+func (rate *ExchangeRate) rat() (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(rate.Rate)
+	if !ok || r.Sign() <= 0 {
+		return nil, ErrInvalidExchangeRate
+	}
+	return r, nil
+}
+
+// Convert converts an amount in minor units from the given currency into the
+// other currency of this rate, rounding half away from zero
+func (rate *ExchangeRate) Convert(amount int64, from string) (int64, error) {
+	r, err := rate.rat()
+	if err != nil {
+		return 0, err
+	}
+
+	to := rate.ToCurrency
+	switch from {
+	case rate.FromCurrency:
+	case rate.ToCurrency:
+		r.Inv(r)
+		to = rate.FromCurrency
+	default:
+		return 0, ErrNoExchangeRate
+	}
+
+	// account for currencies with a different number of minor units
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), r)
+	shift := currencyFraction(to) - currencyFraction(from)
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil)
+	if shift > 0 {
+		v.Mul(v, new(big.Rat).SetInt(exp))
+	} else {
+		v.Quo(v, new(big.Rat).SetInt(exp))
+	}
+
+	num := new(big.Int).Abs(v.Num())
+	q, m := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+	if new(big.Int).Mul(m, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if v.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	return q.Int64(), nil
+}
+
+func currencyFraction(code string) int {
+	if c := money.GetCurrency(code); c != nil {
+		return c.Fraction
+	}
+	return 2
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// budgetCurrency returns the currency of a budget without checking for access
+func budgetCurrency(db sqlAdapter, id int64) (string, error) {
+	var currency string
+	err := db.QueryRow("SELECT currency FROM budgets WHERE id = $1", id).Scan(&currency)
+	return currency, err
+}
@@ -0,0 +1,54 @@
+package db
+
+import "testing"
+
+func TestExchangeRateConvert(t *testing.T) {
+	eurJPY := ExchangeRate{FromCurrency: "EUR", ToCurrency: "JPY", Rate: "130.5"}
+	jpyEUR := ExchangeRate{FromCurrency: "JPY", ToCurrency: "EUR", Rate: "0.0077"}
+	eurUSD := ExchangeRate{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.1"}
+
+	tests := []struct {
+		name   string
+		rate   ExchangeRate
+		amount int64
+		from   string
+		exp    int64
+		err    error
+	}{
+		{"EUR to JPY rounds half up", eurJPY, 100, "EUR", 131, nil},
+		{"EUR to JPY rounds down", eurJPY, 99, "EUR", 129, nil},
+		{"JPY to EUR", jpyEUR, 10000, "JPY", 7700, nil},
+		{"JPY to EUR with inverse rate", eurJPY, 131, "JPY", 100, nil},
+		{"EUR to JPY with inverse rate", jpyEUR, 7700, "EUR", 10000, nil},
+		{"negative amount rounds away from zero", eurJPY, -100, "EUR", -131, nil},
+		{"negative amount rounds towards zero", eurJPY, -99, "EUR", -129, nil},
+		{"same number of minor units", eurUSD, 5, "EUR", 6, nil},
+		{"zero amount", eurUSD, 0, "EUR", 0, nil},
+		{"currency not part of rate", eurUSD, 100, "GBP", 0, ErrNoExchangeRate},
+		{"invalid rate", ExchangeRate{FromCurrency: "EUR", ToCurrency: "USD", Rate: "0"}, 100, "EUR", 0, ErrInvalidExchangeRate},
+	}
+
+	for _, test := range tests {
+		v, err := test.rate.Convert(test.amount, test.from)
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if v != test.exp {
+			t.Errorf("%s: expected %d, got %d", test.name, test.exp, v)
+		}
+	}
+}
+
+func TestExchangeRateEqual(t *testing.T) {
+	rate := ExchangeRate{FromCurrency: "EUR", ToCurrency: "USD", Rate: "1.10"}
+	if !rate.Equal(ExchangeRate{Rate: "1.1"}) {
+		t.Error("expected rates written differently to be equal")
+	}
+	if rate.Equal(ExchangeRate{Rate: "1.11"}) {
+		t.Error("expected different rates not to be equal")
+	}
+	if rate.Equal(ExchangeRate{Rate: "invalid"}) {
+		t.Error("expected invalid rates not to be equal")
+	}
+}
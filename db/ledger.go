@@ -0,0 +1,412 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of ledger inconsistencies
+const (
+	LEDGER_UNBALANCED_PAIR  = "unbalanced pair"
+	LEDGER_ORPHANED_HALF    = "orphaned half"
+	LEDGER_PAYMENT_MISMATCH = "payment mismatch"
+)
+
+// LedgerIssue describes a single inconsistency found in the ledger
+type LedgerIssue struct {
+	Kind          string
+	TransactionID int64
+	PaymentID     *int64
+	Description   string
+}
+
+// transferKey identifies both halves of a transfer
+type transferKey struct {
+	from      int64
+	to        int64
+	paymentID int64
+	createdAt time.Time
+	purpose   string
+}
+
+func newTransferKey(from, to int64, t Transaction) transferKey {
+	k := transferKey{
+		from:      from,
+		to:        to,
+		createdAt: t.CreatedAt,
+		purpose:   t.Purpose,
+	}
+	if t.PaymentID != nil {
+		k.paymentID = *t.PaymentID
+	}
+	return k
+}
+
+// VerifyLedger scans all transactions and payments and reports unbalanced
+// transfer pairs, orphaned transfer halves and payments whose booked total
+// doesn't match the payment's amount or which haven't been distributed
+// according to their code and the cuts they got booked with
+func (context *APIContext) VerifyLedger() ([]LedgerIssue, error) {
+	issues, err := context.verifyTransferPairs()
+	if err != nil {
+		return issues, err
+	}
+
+	pissues, err := context.verifyPaymentTotals()
+	return append(issues, pissues...), err
+}
+
+// verifyTransferPairs matches the negative half of every transfer, which
+// carries the to_budget_id, with its positive peer carrying the from_budget_id
+func (context *APIContext) verifyTransferPairs() ([]LedgerIssue, error) {
+	issues := []LedgerIssue{}
+
+	rows, err := context.Query("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id, currency, exchange_rate_id " +
+		"FROM transactions " +
+		"WHERE from_budget_id IS NOT NULL OR to_budget_id IS NOT NULL " +
+		"ORDER BY id ASC")
+	if err != nil {
+		return issues, err
+	}
+
+	var outgoing []Transaction
+	incoming := make(map[transferKey][]Transaction)
+	rates := ledgerRates{context: context, rates: make(map[int64]ExchangeRate)}
+
+	defer rows.Close()
+	for rows.Next() {
+		t := Transaction{}
+		var purpose *string
+		err = rows.Scan(&t.ID, &t.BudgetID, &t.FromBudgetID, &t.ToBudgetID, &t.Amount,
+			&t.CreatedAt, &purpose, &t.PaymentID, &t.Currency, &t.ExchangeRateID)
+		if err != nil {
+			return issues, err
+		}
+		if purpose != nil {
+			t.Purpose = *purpose
+		}
+
+		switch {
+		case t.FromBudgetID != nil && t.ToBudgetID != nil:
+			issues = append(issues, LedgerIssue{
+				Kind:          LEDGER_ORPHANED_HALF,
+				TransactionID: t.ID,
+				PaymentID:     t.PaymentID,
+				Description:   "transaction has both a source and a destination budget",
+			})
+		case t.ToBudgetID != nil:
+			outgoing = append(outgoing, t)
+		default:
+			k := newTransferKey(*t.FromBudgetID, t.BudgetID, t)
+			incoming[k] = append(incoming[k], t)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return issues, err
+	}
+
+	for _, t := range outgoing {
+		k := newTransferKey(t.BudgetID, *t.ToBudgetID, t)
+
+		// the positive half always gets booked after the negative one
+		peers := incoming[k]
+		idx := -1
+		for i, p := range peers {
+			if p.ID > t.ID {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			issues = append(issues, LedgerIssue{
+				Kind:          LEDGER_ORPHANED_HALF,
+				TransactionID: t.ID,
+				PaymentID:     t.PaymentID,
+				Description:   fmt.Sprintf("no matching transfer into budget %d", *t.ToBudgetID),
+			})
+			continue
+		}
+
+		peer := peers[idx]
+		incoming[k] = append(peers[:idx], peers[idx+1:]...)
+
+		expected, err := rates.convert(-t.Amount, t.Currency, peer.Currency, t.ExchangeRateID)
+		if err != nil {
+			issues = append(issues, LedgerIssue{
+				Kind:          LEDGER_UNBALANCED_PAIR,
+				TransactionID: t.ID,
+				PaymentID:     t.PaymentID,
+				Description: fmt.Sprintf("can't convert %s to %s of transaction %d: %s",
+					t.Currency, peer.Currency, peer.ID, err),
+			})
+			continue
+		}
+		if peer.Amount != expected || t.Amount > 0 {
+			issues = append(issues, LedgerIssue{
+				Kind:          LEDGER_UNBALANCED_PAIR,
+				TransactionID: t.ID,
+				PaymentID:     t.PaymentID,
+				Description: fmt.Sprintf("amount %d does not balance amount %d of transaction %d",
+					t.Amount, peer.Amount, peer.ID),
+			})
+		}
+	}
+
+	var unmatched []Transaction
+	for _, peers := range incoming {
+		unmatched = append(unmatched, peers...)
+	}
+	sort.Slice(unmatched, func(i, j int) bool {
+		return unmatched[i].ID < unmatched[j].ID
+	})
+	for _, t := range unmatched {
+		issues = append(issues, LedgerIssue{
+			Kind:          LEDGER_ORPHANED_HALF,
+			TransactionID: t.ID,
+			PaymentID:     t.PaymentID,
+			Description:   fmt.Sprintf("no matching transfer from budget %d", *t.FromBudgetID),
+		})
+	}
+
+	return issues, nil
+}
+
+// verifyPaymentTotals checks that every processed payment has been booked with
+// its full amount, that refunded payments have been booked back entirely and
+// that payments which haven't been processed yet haven't been booked at all.
+// Transfers are verified separately, so only the initial booking into the
+// receiving budget needs to match the payment's amount
+func (context *APIContext) verifyPaymentTotals() ([]LedgerIssue, error) {
+	issues := []LedgerIssue{}
+	rates := ledgerRates{context: context, rates: make(map[int64]ExchangeRate)}
+
+	rows, err := context.Query("SELECT payments.id, payments.budget_id, payments.code, payments.amount, payments.currency, payments.state, " +
+		"payments.processing_cuts, payments.cut_budget_id, " +
+		"(SELECT COUNT(*) FROM transactions WHERE payment_id = payments.id), " +
+		"transactions.id, transactions.amount, transactions.currency, transactions.exchange_rate_id " +
+		"FROM payments LEFT JOIN transactions ON transactions.payment_id = payments.id AND " +
+		"transactions.from_budget_id IS NULL AND transactions.to_budget_id IS NULL " +
+		"ORDER BY payments.id ASC, transactions.id ASC")
+	if err != nil {
+		return issues, err
+	}
+
+	type paymentTotal struct {
+		id       int64
+		budgetID int64
+		code     string
+		amount   int64
+		currency string
+		state    string
+		count    int64
+
+		// the cuts the payment got booked with, if they were recorded
+		cuts      BigintSlice
+		cutBudget *int64
+
+		bookings        int
+		booked          int64
+		bookingCurrency string
+		rateID          *int64
+	}
+	var totals []*paymentTotal
+
+	defer rows.Close()
+	for rows.Next() {
+		p := paymentTotal{}
+		var tid, tamount *int64
+		var tcurrency *string
+		var rateID *int64
+		err = rows.Scan(&p.id, &p.budgetID, &p.code, &p.amount, &p.currency, &p.state, &p.cuts, &p.cutBudget, &p.count,
+			&tid, &tamount, &tcurrency, &rateID)
+		if err != nil {
+			return issues, err
+		}
+
+		if len(totals) == 0 || totals[len(totals)-1].id != p.id {
+			totals = append(totals, &p)
+		}
+		if tid == nil {
+			continue
+		}
+
+		cur := totals[len(totals)-1]
+		if cur.bookings == 0 {
+			cur.bookingCurrency = *tcurrency
+			cur.rateID = rateID
+		} else if cur.bookingCurrency != *tcurrency {
+			cur.bookingCurrency = "mixed"
+		}
+		cur.booked += *tamount
+		cur.bookings++
+	}
+	if err = rows.Err(); err != nil {
+		return issues, err
+	}
+
+	for _, p := range totals {
+		pid := p.id
+
+		// compare in the currency the payment got booked in
+		var expected int64
+		var cerr error
+		if p.bookings > 0 {
+			expected, cerr = rates.convert(p.amount, p.currency, p.bookingCurrency, p.rateID)
+		}
+
+		processed := p.state == PAYMENT_STATE_PROCESSED
+		switch {
+		case p.state == PAYMENT_STATE_REFUNDED:
+			if p.booked != 0 {
+				issues = append(issues, LedgerIssue{
+					Kind:        LEDGER_PAYMENT_MISMATCH,
+					PaymentID:   &pid,
+					Description: fmt.Sprintf("refunded payment still has %d %s booked", p.booked, p.bookingCurrency),
+				})
+			}
+		case !processed && p.count > 0:
+			issues = append(issues, LedgerIssue{
+				Kind:        LEDGER_PAYMENT_MISMATCH,
+				PaymentID:   &pid,
+				Description: fmt.Sprintf("%s payment has %d booked transactions", p.state, p.count),
+			})
+		case processed && p.bookings > 0 && cerr != nil:
+			issues = append(issues, LedgerIssue{
+				Kind:      LEDGER_PAYMENT_MISMATCH,
+				PaymentID: &pid,
+				Description: fmt.Sprintf("can't convert payment amount from %s to %s: %s",
+					p.currency, p.bookingCurrency, cerr),
+			})
+		case processed && p.bookings > 0 && p.booked != expected:
+			issues = append(issues, LedgerIssue{
+				Kind:      LEDGER_PAYMENT_MISMATCH,
+				PaymentID: &pid,
+				Description: fmt.Sprintf("booked total %d %s does not match payment amount %d %s",
+					p.booked, p.bookingCurrency, p.amount, p.currency),
+			})
+		case processed && p.bookings == 0:
+			issues = append(issues, LedgerIssue{
+				Kind:        LEDGER_PAYMENT_MISMATCH,
+				PaymentID:   &pid,
+				Description: "processed payment has not been booked",
+			})
+		case processed && p.bookings == 1 && p.cuts != nil && p.cutBudget != nil:
+			// the booking is fine, check it has been distributed entirely.
+			// Payments booked before their cuts were recorded can't be
+			// planned again, projects' cuts may have changed since
+			var cuts []int
+			for _, c := range p.cuts {
+				cuts = append(cuts, int(c))
+			}
+			desc, err := context.verifyPaymentDistribution(p.id, p.budgetID, p.code, cuts, *p.cutBudget, p.booked, p.bookingCurrency)
+			if err != nil {
+				return issues, err
+			}
+			if desc != "" {
+				issues = append(issues, LedgerIssue{
+					Kind:        LEDGER_PAYMENT_MISMATCH,
+					PaymentID:   &pid,
+					Description: desc,
+				})
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// verifyPaymentDistribution compares the transfers a payment got distributed
+// with against the transfers its code and the cuts it got booked with
+// require. It returns a description of the first difference found, or an
+// empty string
+func (context *APIContext) verifyPaymentDistribution(paymentID, budgetID int64, c string, cuts []int, cutBudget int64, booked int64, currency string) (string, error) {
+	code, err := context.LoadCodeByCode(c)
+	if err != nil {
+		return fmt.Sprintf("can't load code %q: %s", c, err), nil
+	}
+	planned, err := planDistribution(code, cuts, cutBudget, booked, currency)
+	if err != nil {
+		return fmt.Sprintf("can't plan distribution for code %q: %s", c, err), nil
+	}
+
+	// shares staying in the receiving budget don't get transferred
+	expected := make(map[int64]int64)
+	for _, pt := range planned {
+		if pt.ToBudgetID != budgetID {
+			expected[pt.ToBudgetID] += pt.Amount
+		}
+	}
+
+	// the receiving budget's halves carry the transfers in its own currency
+	rows, err := context.Query("SELECT COALESCE(to_budget_id, from_budget_id), SUM(amount) FROM transactions "+
+		"WHERE payment_id = $1 AND budget_id = $2 AND (to_budget_id IS NOT NULL OR from_budget_id IS NOT NULL) "+
+		"GROUP BY 1", paymentID, budgetID)
+	if err != nil {
+		return "", err
+	}
+
+	actual := make(map[int64]int64)
+	defer rows.Close()
+	for rows.Next() {
+		var peer, sum int64
+		if err = rows.Scan(&peer, &sum); err != nil {
+			return "", err
+		}
+		actual[peer] = -sum
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+
+	var peers []int64
+	for peer := range expected {
+		peers = append(peers, peer)
+	}
+	for peer := range actual {
+		if _, ok := expected[peer]; !ok {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+
+	for _, peer := range peers {
+		if expected[peer] != actual[peer] {
+			return fmt.Sprintf("distributed %d %s to budget %d, code %q requires %d %s",
+				actual[peer], currency, peer, c, expected[peer], currency), nil
+		}
+	}
+
+	return "", nil
+}
+
+// ledgerRates caches the exchange rates used by the verified transactions
+type ledgerRates struct {
+	context *APIContext
+	rates   map[int64]ExchangeRate
+}
+
+// convert an amount with the exchange rate recorded on a transaction
+func (lr ledgerRates) convert(amount int64, from, to string, rateID *int64) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+	if rateID == nil {
+		return 0, ErrNoExchangeRate
+	}
+
+	rate, ok := lr.rates[*rateID]
+	if !ok {
+		var err error
+		rate, err = lr.context.LoadExchangeRateByID(*rateID)
+		if err != nil {
+			return 0, err
+		}
+		lr.rates[*rateID] = rate
+	}
+
+	if !(rate.FromCurrency == from && rate.ToCurrency == to) && !(rate.FromCurrency == to && rate.ToCurrency == from) {
+		return 0, ErrNoExchangeRate
+	}
+	return rate.Convert(amount, from)
+}
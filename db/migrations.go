@@ -0,0 +1,768 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Migration describes a single, numbered change of the database schema
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// ErrSchemaOutdated is the error returned when the database schema is older
+// than the one this version of sangha expects
+type ErrSchemaOutdated struct {
+	Installed int
+	Required  int
+}
+
+func (e ErrSchemaOutdated) Error() string {
+	return fmt.Sprintf("Database schema is at version %d, but version %d is required. Run 'sangha database migrate' first",
+		e.Installed, e.Required)
+}
+
+// migrations contains all schema changes in order. Never edit a migration
+// that has been released, append a new one instead
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "initial schema",
+		Up: []string{
+			`CREATE TABLE users
+				(
+				  id          	bigserial 	PRIMARY KEY,
+				  uuid			text		NOT NULL,
+				  email       	text		NOT NULL,
+				  nickname    	text      	NOT NULL,
+				  password		text		NOT NULL,
+				  about       	text		DEFAULT '',
+				  avatar		text		DEFAULT '',
+				  address		text[],
+				  zip			text		DEFAULT '',
+				  city			text		DEFAULT '',
+				  country		text		DEFAULT '',
+				  activated   	bool		DEFAULT false,
+				  authtoken   	text[]     	NOT NULL,
+				  CONSTRAINT  	uk_users_uuid 	UNIQUE (uuid),
+				  CONSTRAINT  	uk_users_email 	UNIQUE (email)
+				)`,
+
+			`CREATE TABLE projects
+				(
+				  id          		bigserial 		PRIMARY KEY,
+				  uuid				text			NOT NULL,
+				  slug				text			NOT NULL,
+				  name       		text      		NOT NULL,
+				  summary			text			NOT NULL,
+				  about				text      		DEFAULT '',
+				  website      		text			DEFAULT '',
+				  license      		text			DEFAULT '',
+				  repository		text			DEFAULT '',
+				  logo				text			DEFAULT '',
+				  created_at		timestamp		NOT NULL,
+				  private			bool			DEFAULT false,
+				  private_balance	bool			DEFAULT true,
+				  processing_cut	int				DEFAULT 10,
+				  activated   		bool			DEFAULT false,
+				  user_id			int,
+				  CONSTRAINT  		uk_projects_uuid 		UNIQUE (uuid),
+				  CONSTRAINT  		uk_projects_slug 		UNIQUE (slug),
+				  CONSTRAINT  		uk_projects_repository	UNIQUE (repository),
+				  CONSTRAINT    	fk_projects_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+
+			`CREATE TABLE budgets
+				(
+				  id          		bigserial 	PRIMARY KEY,
+				  uuid				text		NOT NULL,
+				  project_id    	int,
+				  user_id			int,
+				  parent			bigserial,
+				  name       		text      	NOT NULL,
+				  description		text,
+				  private			bool		DEFAULT false,
+				  private_balance	bool		DEFAULT true,
+				  CONSTRAINT  		uk_budgets_uuid 		UNIQUE (uuid),
+				  CONSTRAINT    	fk_budgets_project_id	FOREIGN KEY (project_id) REFERENCES projects (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT    	fk_budgets_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+
+			`CREATE TABLE payments
+				(
+				  id          			bigserial 		PRIMARY KEY,
+				  budget_id				bigserial   	NOT NULL,
+				  created_at			timestamp		NOT NULL,
+				  amount				int				NOT NULL,
+				  currency				text			NOT NULL,
+				  code					text			DEFAULT '',
+				  purpose				text			DEFAULT '',
+				  remote_account		text			NOT NULL,
+				  remote_name			text			NOT NULL,
+				  remote_transaction_id	text			DEFAULT '',
+				  remote_bank_id		text			DEFAULT '',
+				  source				text			NOT NULL,
+				  pending				bool			DEFAULT true,
+				  CONSTRAINT    		fk_payments_budget_id	FOREIGN KEY (budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
+				)`,
+
+			`CREATE TABLE transactions
+				(
+				  id          		bigserial 		PRIMARY KEY,
+				  budget_id			bigserial   	NOT NULL,
+				  from_budget_id	int,
+				  to_budget_id		int,
+				  amount			int				NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  purpose			text,
+				  payment_id		int,
+				  CONSTRAINT    	fk_transactions_budget_id		FOREIGN KEY (budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
+				  CONSTRAINT    	fk_transactions_from_budget_id	FOREIGN KEY (from_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
+				  CONSTRAINT    	fk_transactions_to_budget_id	FOREIGN KEY (to_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT,
+				  CONSTRAINT    	fk_transactions_payment_id		FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
+				)`,
+
+			`CREATE TABLE codes
+				(
+				  id			bigserial 		PRIMARY KEY,
+				  code			text      		NOT NULL,
+				  budget_ids   	int[]			NOT NULL,
+				  ratios		int[]			NOT NULL,
+				  user_id   	int,
+				  CONSTRAINT    uk_codes_code  		UNIQUE (code),
+				  CONSTRAINT    uk_codes_budget_ids	UNIQUE (budget_ids, ratios, user_id),
+				  CONSTRAINT    fk_codes_user_id	FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+
+			`CREATE TABLE contributors
+				(
+				  id			bigserial 		PRIMARY KEY,
+				  user_id   	int,
+				  project_id   	int,
+				  CONSTRAINT    uk_contributors_user_project	UNIQUE (user_id, project_id),
+				  CONSTRAINT    fk_contributors_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT    fk_contributors_project_id	FOREIGN KEY (project_id) REFERENCES projects (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+
+			`CREATE INDEX IF NOT EXISTS idx_users_uuid ON users(uuid)`,
+			`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+			`CREATE INDEX IF NOT EXISTS idx_users_authtoken ON users(authtoken)`,
+			`CREATE INDEX IF NOT EXISTS idx_projects_uuid ON projects(uuid)`,
+			`CREATE INDEX IF NOT EXISTS idx_projects_slug ON projects(slug)`,
+			`CREATE INDEX IF NOT EXISTS idx_projects_name ON projects(name)`,
+			`CREATE INDEX IF NOT EXISTS idx_budgets_uuid ON budgets(uuid)`,
+			`CREATE INDEX IF NOT EXISTS idx_budgets_name ON budgets(name)`,
+			`CREATE INDEX IF NOT EXISTS idx_budgets_project_id ON budgets(project_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_codes_code ON codes(code)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_budget_id ON payments(budget_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_budget_id ON transactions(budget_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_from_budget_id ON transactions(from_budget_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_contributors_project_id ON contributors(project_id)`,
+		},
+		Down: []string{
+			`DROP TABLE codes`,
+			`DROP TABLE contributors`,
+			`DROP TABLE transactions`,
+			`DROP TABLE payments`,
+			`DROP TABLE budgets`,
+			`DROP TABLE projects`,
+			`DROP TABLE users`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "currencies for budgets & transactions, exchange rates",
+		Up: []string{
+			`ALTER TABLE budgets ADD COLUMN currency text NOT NULL DEFAULT 'EUR'`,
+
+			`CREATE TABLE exchange_rates
+				(
+				  id				bigserial		PRIMARY KEY,
+				  from_currency		text			NOT NULL,
+				  to_currency		text			NOT NULL,
+				  rate				numeric			NOT NULL,
+				  valid_from		timestamp		NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  CONSTRAINT		uk_exchange_rates_pair	UNIQUE (from_currency, to_currency, valid_from),
+				  CONSTRAINT		ck_exchange_rates_rate	CHECK (rate > 0)
+				)`,
+
+			`ALTER TABLE transactions
+				ADD COLUMN currency			text	NOT NULL DEFAULT 'EUR',
+				ADD COLUMN exchange_rate_id	int,
+				ADD CONSTRAINT fk_transactions_exchange_rate_id FOREIGN KEY (exchange_rate_id) REFERENCES exchange_rates (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT`,
+
+			`CREATE INDEX IF NOT EXISTS idx_exchange_rates_valid_from ON exchange_rates(valid_from)`,
+		},
+		Down: []string{
+			`ALTER TABLE transactions DROP COLUMN exchange_rate_id, DROP COLUMN currency`,
+			`DROP TABLE exchange_rates`,
+			`ALTER TABLE budgets DROP COLUMN currency`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "global & per-project user roles",
+		Up: []string{
+			`ALTER TABLE users
+				ADD COLUMN role		text	NOT NULL DEFAULT '',
+				ADD CONSTRAINT ck_users_role CHECK (role IN ('', 'admin', 'treasurer', 'viewer'))`,
+			`UPDATE users SET role = 'admin' WHERE id = 1`,
+
+			`ALTER TABLE contributors
+				ADD COLUMN role		text	NOT NULL DEFAULT 'maintainer',
+				ADD CONSTRAINT ck_contributors_role CHECK (role IN ('maintainer', 'viewer'))`,
+
+			`CREATE INDEX IF NOT EXISTS idx_contributors_user_id ON contributors(user_id)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_contributors_user_id`,
+			`ALTER TABLE contributors DROP COLUMN role`,
+			`ALTER TABLE users DROP COLUMN role`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "expiring, revocable sessions replace users.authtoken",
+		Up: []string{
+			`CREATE TABLE sessions
+				(
+				  id				bigserial		PRIMARY KEY,
+				  token_hash		text			NOT NULL,
+				  user_id			int				NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  expires_at		timestamp		NOT NULL,
+				  last_used_at		timestamp		NOT NULL,
+				  revoked_at		timestamp,
+				  user_agent		text			DEFAULT '',
+				  scopes			text[]			NOT NULL,
+				  CONSTRAINT		uk_sessions_token_hash	UNIQUE (token_hash),
+				  CONSTRAINT		fk_sessions_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+
+			// existing tokens stay valid until they expire like any other session
+			`INSERT INTO sessions (token_hash, user_id, created_at, expires_at, last_used_at, scopes)
+				SELECT DISTINCT encode(sha256(convert_to(token, 'UTF8')), 'hex'), users.id, now(), now() + interval '30 days', now(), '{read,write}'
+				FROM users, unnest(users.authtoken) AS token
+				WHERE token <> ''`,
+
+			`DROP INDEX IF EXISTS idx_users_authtoken`,
+			`ALTER TABLE users DROP COLUMN authtoken`,
+
+			`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
+		},
+		Down: []string{
+			`ALTER TABLE users ADD COLUMN authtoken text[] NOT NULL DEFAULT '{}'`,
+			`CREATE INDEX IF NOT EXISTS idx_users_authtoken ON users(authtoken)`,
+			`DROP TABLE sessions`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "single-use activation & password reset tokens",
+		Up: []string{
+			`CREATE TABLE user_tokens
+				(
+				  id				bigserial		PRIMARY KEY,
+				  token_hash		text			NOT NULL,
+				  user_id			int				NOT NULL,
+				  purpose			text			NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  expires_at		timestamp		NOT NULL,
+				  used_at			timestamp,
+				  CONSTRAINT		uk_user_tokens_token_hash	UNIQUE (token_hash),
+				  CONSTRAINT		ck_user_tokens_purpose		CHECK (purpose IN ('activation', 'password_reset')),
+				  CONSTRAINT		fk_user_tokens_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			`CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id)`,
+		},
+		Down: []string{
+			`DROP TABLE user_tokens`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "donor emails & email delivery log",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN remote_email text NOT NULL DEFAULT ''`,
+
+			`CREATE TABLE email_deliveries
+				(
+				  id				bigserial		PRIMARY KEY,
+				  payment_id		int,
+				  kind				text			NOT NULL,
+				  recipient			text			NOT NULL,
+				  subject			text			NOT NULL,
+				  text_body			text			NOT NULL,
+				  html_body			text			NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  attempts			int				NOT NULL DEFAULT 0,
+				  last_attempt_at	timestamp,
+				  last_error		text			NOT NULL DEFAULT '',
+				  sent_at			timestamp,
+				  CONSTRAINT		uk_email_deliveries_payment_id_kind	UNIQUE (payment_id, kind),
+				  CONSTRAINT		fk_email_deliveries_payment_id		FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			`CREATE INDEX IF NOT EXISTS idx_email_deliveries_unsent ON email_deliveries(created_at) WHERE sent_at IS NULL`,
+		},
+		Down: []string{
+			`DROP TABLE email_deliveries`,
+			`ALTER TABLE payments DROP COLUMN remote_email`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "idempotent payment ingestion",
+		Up: []string{
+			// fails if a provider transaction has already been stored twice;
+			// such duplicates need to be resolved manually
+			`CREATE UNIQUE INDEX uk_payments_source_remote_transaction_id ON payments(source, remote_transaction_id)
+				WHERE remote_transaction_id <> ''`,
+
+			`ALTER TABLE payments ADD COLUMN idempotency_key text`,
+			`CREATE UNIQUE INDEX uk_payments_idempotency_key ON payments(idempotency_key)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS uk_payments_idempotency_key`,
+			`ALTER TABLE payments DROP COLUMN idempotency_key`,
+			`DROP INDEX IF EXISTS uk_payments_source_remote_transaction_id`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "payment provider webhook events",
+		Up: []string{
+			`CREATE TABLE webhook_events
+				(
+				  id				bigserial		PRIMARY KEY,
+				  source			text			NOT NULL,
+				  event_id			text			NOT NULL,
+				  kind				text			NOT NULL,
+				  payment_id		int,
+				  received_at		timestamp		NOT NULL,
+				  CONSTRAINT		uk_webhook_events_source_event_id	UNIQUE (source, event_id),
+				  CONSTRAINT		fk_webhook_events_payment_id		FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE SET NULL
+				)`,
+			`CREATE INDEX IF NOT EXISTS idx_webhook_events_payment_id ON webhook_events(payment_id)`,
+		},
+		Down: []string{
+			`DROP TABLE webhook_events`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "payment amounts in the source's currency",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN remote_amount text NOT NULL DEFAULT ''`,
+			`ALTER TABLE payments ADD COLUMN remote_currency text NOT NULL DEFAULT ''`,
+		},
+		Down: []string{
+			`ALTER TABLE payments DROP COLUMN remote_currency`,
+			`ALTER TABLE payments DROP COLUMN remote_amount`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "refunded payments",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN refunded_at timestamp`,
+			`ALTER TABLE payments ADD COLUMN refund_kind text NOT NULL DEFAULT ''`,
+		},
+		Down: []string{
+			`ALTER TABLE payments DROP COLUMN refund_kind`,
+			`ALTER TABLE payments DROP COLUMN refunded_at`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "payment states & their transitions",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN state text NOT NULL DEFAULT 'received'`,
+			`UPDATE payments SET state = CASE
+				  WHEN refunded_at IS NOT NULL THEN 'refunded'
+				  WHEN NOT pending THEN 'processed'
+				  WHEN code <> '' THEN 'matched'
+				  ELSE 'received'
+				END`,
+			`ALTER TABLE payments DROP COLUMN pending`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_state ON payments(state)`,
+			`CREATE TABLE payment_transitions
+				(
+				  id				bigserial		PRIMARY KEY,
+				  payment_id		int				NOT NULL,
+				  from_state		text			NOT NULL,
+				  to_state			text			NOT NULL,
+				  user_id			int,
+				  created_at		timestamp		NOT NULL,
+				  CONSTRAINT		fk_payment_transitions_payment_id	FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_payment_transitions_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE SET NULL
+				)`,
+			`CREATE INDEX IF NOT EXISTS idx_payment_transitions_payment_id ON payment_transitions(payment_id)`,
+		},
+		Down: []string{
+			`DROP TABLE payment_transitions`,
+			`DROP INDEX IF EXISTS idx_payments_state`,
+			`ALTER TABLE payments ADD COLUMN pending bool DEFAULT true`,
+			`UPDATE payments SET pending = state IN ('received', 'matched')`,
+			`ALTER TABLE payments DROP COLUMN state`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "SEPA credit transfer exports",
+		Up: []string{
+			`CREATE TABLE sepa_exports
+				(
+				  id				bigserial		PRIMARY KEY,
+				  message_id		text			NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  user_id			int,
+				  document			text			NOT NULL,
+				  CONSTRAINT		uk_sepa_exports_message_id	UNIQUE (message_id),
+				  CONSTRAINT		fk_sepa_exports_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE SET NULL
+				)`,
+			`ALTER TABLE payments ADD COLUMN sepa_export_id bigint REFERENCES sepa_exports (id) ON DELETE RESTRICT`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_sepa_export_id ON payments(sepa_export_id)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_payments_sepa_export_id`,
+			`ALTER TABLE payments DROP COLUMN sepa_export_id`,
+			`DROP TABLE sepa_exports`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "approval requests for large transfers & outgoing payments",
+		Up: []string{
+			`CREATE TABLE approval_requests
+				(
+				  id				bigserial		PRIMARY KEY,
+				  kind				text			NOT NULL,
+				  state				text			NOT NULL DEFAULT 'pending',
+				  payment_id		int,
+				  from_budget_id	int,
+				  to_budget_id		int,
+				  amount			bigint			NOT NULL,
+				  purpose			text			NOT NULL DEFAULT '',
+				  user_id			int,
+				  created_at		timestamp		NOT NULL,
+				  decided_at		timestamp,
+				  transaction_id	int,
+				  CONSTRAINT		fk_approval_requests_payment_id		FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_approval_requests_from_budget_id	FOREIGN KEY (from_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_approval_requests_to_budget_id	FOREIGN KEY (to_budget_id) REFERENCES budgets (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_approval_requests_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE SET NULL,
+				  CONSTRAINT		fk_approval_requests_transaction_id	FOREIGN KEY (transaction_id) REFERENCES transactions (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
+				)`,
+			`CREATE INDEX IF NOT EXISTS idx_approval_requests_state ON approval_requests(state)`,
+			`CREATE UNIQUE INDEX uk_approval_requests_pending_payment_id ON approval_requests(payment_id) WHERE state = 'pending'`,
+			`CREATE TABLE approvals
+				(
+				  id				bigserial		PRIMARY KEY,
+				  request_id		int				NOT NULL,
+				  user_id			int				NOT NULL,
+				  decision			text			NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  CONSTRAINT		uk_approvals_request_id_user_id	UNIQUE (request_id, user_id),
+				  CONSTRAINT		fk_approvals_request_id			FOREIGN KEY (request_id) REFERENCES approval_requests (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_approvals_user_id			FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+		},
+		Down: []string{
+			`DROP TABLE approvals`,
+			`DROP TABLE approval_requests`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "indexes for paging through payments",
+		Up: []string{
+			`CREATE INDEX IF NOT EXISTS idx_payments_created_at_id ON payments(created_at, id)`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_amount_id ON payments(amount, id)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_payments_amount_id`,
+			`DROP INDEX IF EXISTS idx_payments_created_at_id`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "link payments to their donors' accounts",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN user_id int`,
+			`ALTER TABLE payments ADD CONSTRAINT fk_payments_user_id FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE SET NULL`,
+			`CREATE INDEX IF NOT EXISTS idx_payments_user_id ON payments(user_id)`,
+			`UPDATE payments SET user_id = users.id FROM users
+				WHERE payments.remote_email <> '' AND LOWER(payments.remote_email) = LOWER(users.email) AND users.activated`,
+			`CREATE TABLE donor_accounts
+				(
+				  id				bigserial		PRIMARY KEY,
+				  user_id			int				NOT NULL,
+				  account			text			NOT NULL,
+				  token				text			NOT NULL,
+				  created_at		timestamp		NOT NULL,
+				  verified_at		timestamp,
+				  CONSTRAINT		uk_donor_accounts_user_id_account	UNIQUE (user_id, account),
+				  CONSTRAINT		uk_donor_accounts_token				UNIQUE (token),
+				  CONSTRAINT		fk_donor_accounts_user_id			FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE
+				)`,
+			`CREATE UNIQUE INDEX uk_donor_accounts_verified_account ON donor_accounts(account) WHERE verified_at IS NOT NULL`,
+		},
+		Down: []string{
+			`DROP TABLE donor_accounts`,
+			`DROP INDEX IF EXISTS idx_payments_user_id`,
+			`ALTER TABLE payments DROP COLUMN user_id`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "annual donation receipts",
+		Up: []string{
+			`CREATE TABLE donation_receipts
+				(
+				  id				bigserial		PRIMARY KEY,
+				  number			text			NOT NULL,
+				  year				int				NOT NULL,
+				  seq				int				NOT NULL,
+				  user_id			int				NOT NULL,
+				  amount			bigint			NOT NULL,
+				  currency			text			NOT NULL,
+				  issued_at			timestamp		NOT NULL,
+				  content			text			NOT NULL,
+				  document			bytea			NOT NULL,
+				  CONSTRAINT		uk_donation_receipts_number			UNIQUE (number),
+				  CONSTRAINT		uk_donation_receipts_year_seq		UNIQUE (year, seq),
+				  CONSTRAINT		uk_donation_receipts_user_id_year	UNIQUE (user_id, year),
+				  CONSTRAINT		fk_donation_receipts_user_id		FOREIGN KEY (user_id) REFERENCES users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
+				)`,
+			`CREATE TABLE donation_receipt_payments
+				(
+				  receipt_id		int				NOT NULL,
+				  payment_id		int				PRIMARY KEY,
+				  CONSTRAINT		fk_donation_receipt_payments_receipt_id	FOREIGN KEY (receipt_id) REFERENCES donation_receipts (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE,
+				  CONSTRAINT		fk_donation_receipt_payments_payment_id	FOREIGN KEY (payment_id) REFERENCES payments (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE RESTRICT
+				)`,
+		},
+		Down: []string{
+			`DROP TABLE donation_receipt_payments`,
+			`DROP TABLE donation_receipts`,
+		},
+	},
+	{
+		Version:     17,
+		Description: "supplementary donation receipts",
+		Up: []string{
+			`ALTER TABLE donation_receipts DROP CONSTRAINT uk_donation_receipts_user_id_year`,
+			`CREATE INDEX IF NOT EXISTS idx_donation_receipts_user_id_year ON donation_receipts(user_id, year)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_donation_receipts_user_id_year`,
+			`ALTER TABLE donation_receipts ADD CONSTRAINT uk_donation_receipts_user_id_year UNIQUE (user_id, year)`,
+		},
+	},
+	{
+		Version:     18,
+		Description: "users' full names",
+		Up: []string{
+			`ALTER TABLE users ADD COLUMN name text NOT NULL DEFAULT ''`,
+		},
+		Down: []string{
+			`ALTER TABLE users DROP COLUMN name`,
+		},
+	},
+	{
+		Version:     19,
+		Description: "tie payment approvals to the approved code",
+		Up: []string{
+			`ALTER TABLE approval_requests ADD COLUMN code text NOT NULL DEFAULT ''`,
+			`UPDATE approval_requests SET code = payments.code FROM payments WHERE approval_requests.payment_id = payments.id`,
+			// approvals of payments that left the approved state since don't
+			// apply anymore
+			`UPDATE approval_requests SET state = 'superseded' FROM payments
+				WHERE approval_requests.payment_id = payments.id AND approval_requests.state = 'approved'
+				AND payments.state IN ('received', 'matched')`,
+		},
+		Down: []string{
+			`UPDATE approval_requests SET state = 'rejected' WHERE state = 'superseded'`,
+			`ALTER TABLE approval_requests DROP COLUMN code`,
+		},
+	},
+	{
+		Version:     20,
+		Description: "flag donation receipts of refunded donations",
+		Up: []string{
+			`ALTER TABLE donation_receipts ADD COLUMN state text NOT NULL DEFAULT 'valid'`,
+			`UPDATE donation_receipts SET state = 'needs_correction' WHERE id IN
+				(SELECT receipt_id FROM donation_receipt_payments
+				JOIN payments ON payments.id = donation_receipt_payments.payment_id WHERE payments.state = 'refunded')`,
+			`CREATE INDEX IF NOT EXISTS idx_donation_receipts_state ON donation_receipts(state)`,
+		},
+		Down: []string{
+			`DROP INDEX IF EXISTS idx_donation_receipts_state`,
+			`ALTER TABLE donation_receipts DROP COLUMN state`,
+		},
+	},
+	{
+		Version:     21,
+		Description: "record the processing cuts payments got booked with",
+		Up: []string{
+			`ALTER TABLE payments ADD COLUMN processing_cuts int[]`,
+			`ALTER TABLE payments ADD COLUMN cut_budget_id int`,
+		},
+		Down: []string{
+			`ALTER TABLE payments DROP COLUMN cut_budget_id`,
+			`ALTER TABLE payments DROP COLUMN processing_cuts`,
+		},
+	},
+}
+
+func init() {
+	// MigrateDatabase looks migrations up by their position
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			panic(fmt.Sprintf("Migration %q has version %d, expected %d", m.Description, m.Version, i+1))
+		}
+	}
+}
+
+// InstalledSchemaVersion returns the schema version the database is currently at
+func InstalledSchemaVersion() (int, error) {
+	var configTable, usersTable sql.NullString
+	err := pgDB.QueryRow("SELECT to_regclass('config')::text, to_regclass('users')::text").Scan(&configTable, &usersTable)
+	if err != nil {
+		return 0, err
+	}
+
+	if configTable.Valid {
+		var version int
+		err = pgDB.QueryRow("SELECT value::int FROM config WHERE name = 'schema_version'").Scan(&version)
+		if err == nil {
+			return version, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	// databases set up before migrations existed carry the initial schema
+	// without having its version recorded
+	if usersTable.Valid {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// CheckSchemaVersion returns an error if the database schema does not match
+// the version this build of sangha expects
+func CheckSchemaVersion() error {
+	installed, err := InstalledSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if installed < SchemaVersion {
+		return ErrSchemaOutdated{Installed: installed, Required: SchemaVersion}
+	}
+	if installed > SchemaVersion {
+		return fmt.Errorf("Database schema version %d is newer than the supported version %d", installed, SchemaVersion)
+	}
+	return nil
+}
+
+// MigrateDatabase migrates the database schema up or down to the requested
+// version. In dry-run mode the statements are only printed
+func MigrateDatabase(to int, dryRun bool) error {
+	if to < 0 || to > SchemaVersion {
+		return fmt.Errorf("Invalid schema version %d, must be between 0 and %d", to, SchemaVersion)
+	}
+
+	from, err := InstalledSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if from > SchemaVersion {
+		return fmt.Errorf("Database schema version %d is newer than the supported version %d", from, SchemaVersion)
+	}
+
+	if from == to {
+		log.WithField("Version", from).Info("Database schema is up to date")
+		return nil
+	}
+
+	if !dryRun {
+		_, err = pgDB.Exec(`CREATE TABLE IF NOT EXISTS config
+			(
+			  name		text	PRIMARY KEY,
+			  value		text
+			)`)
+		if err != nil {
+			return err
+		}
+	}
+
+	for from != to {
+		var m Migration
+		var stmts []string
+		var next int
+
+		if to > from {
+			m = migrations[from]
+			stmts = m.Up
+			next = m.Version
+		} else {
+			m = migrations[from-1]
+			stmts = m.Down
+			next = m.Version - 1
+		}
+
+		log.WithFields(log.Fields{
+			"From":        from,
+			"To":          next,
+			"Description": m.Description,
+		}).Info("Migrating database schema")
+
+		if dryRun {
+			for _, s := range stmts {
+				fmt.Println(s + ";")
+			}
+		} else if err := applyMigration(stmts, next); err != nil {
+			return fmt.Errorf("Migration to schema version %d failed: %s", next, err)
+		}
+
+		from = next
+	}
+
+	return nil
+}
+
+// applyMigration runs a set of statements and records the new schema version
+// in a single transaction
+func applyMigration(stmts []string, version int) error {
+	tx, err := pgDB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stmts {
+		if _, err = tx.Exec(s); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO config (name, value) VALUES ('schema_version', $1) "+
+		"ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value", fmt.Sprint(version))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
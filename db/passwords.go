@@ -0,0 +1,204 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms passwords can be hashed with
+const (
+	PASSWORD_ARGON2ID = "argon2id"
+	PASSWORD_BCRYPT   = "bcrypt"
+)
+
+// Argon2id parameters used when none have been configured
+const (
+	DefaultArgon2Time    = 1
+	DefaultArgon2Memory  = 64 * 1024
+	DefaultArgon2Threads = 4
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// legacyPepper is the pepper password hashes got created with before it
+// became configurable. Such hashes get upgraded on the next login
+const legacyPepper = "cryptpepper"
+
+var (
+	// ErrInvalidPasswordAlgorithm is the error returned for unknown password hashing algorithms
+	ErrInvalidPasswordAlgorithm = errors.New("Invalid password hashing algorithm")
+	// ErrInvalidPasswordHash is the error returned for malformed password hashes
+	ErrInvalidPasswordHash = errors.New("Invalid password hash")
+	// ErrMissingPepper is the error returned when no password pepper has been configured
+	ErrMissingPepper = errors.New("Passwords.Pepper must be set to a secret")
+)
+
+// argon2Params are the parameters an argon2id hash got created with
+type argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// CheckPasswordSettings verifies that a secret pepper and a known hashing
+// algorithm have been configured
+func CheckPasswordSettings(c config.Data) error {
+	if len(c.Passwords.Pepper) == 0 {
+		return ErrMissingPepper
+	}
+
+	context := APIContext{Config: c}
+	switch context.passwordAlgorithm() {
+	case PASSWORD_ARGON2ID, PASSWORD_BCRYPT:
+		return nil
+	}
+	return ErrInvalidPasswordAlgorithm
+}
+
+// passwordAlgorithm returns the configured password hashing algorithm
+func (context *APIContext) passwordAlgorithm() string {
+	if len(context.Config.Passwords.Algorithm) == 0 {
+		return PASSWORD_ARGON2ID
+	}
+	return context.Config.Passwords.Algorithm
+}
+
+// bcryptCost returns the configured bcrypt cost
+func (context *APIContext) bcryptCost() int {
+	cost := context.Config.Passwords.BcryptCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// argon2Params returns the configured argon2id parameters
+func (context *APIContext) argon2Params() argon2Params {
+	p := argon2Params{
+		Time:    context.Config.Passwords.Argon2.Time,
+		Memory:  context.Config.Passwords.Argon2.Memory,
+		Threads: context.Config.Passwords.Argon2.Threads,
+	}
+	if p.Time == 0 {
+		p.Time = DefaultArgon2Time
+	}
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2Memory
+	}
+	if p.Threads == 0 {
+		p.Threads = DefaultArgon2Threads
+	}
+	return p
+}
+
+// pepper mixes the secret pepper into a password. Using a MAC instead of
+// appending the pepper keeps it effective for passwords exceeding bcrypt's
+// 72 byte input limit
+func (context *APIContext) pepper(password string) []byte {
+	mac := hmac.New(sha256.New, []byte(context.Config.Passwords.Pepper))
+	mac.Write([]byte(password))
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// hashPassword hashes a password with the configured algorithm. The result
+// identifies the algorithm & its parameters
+func (context *APIContext) hashPassword(password string) (string, error) {
+	if len(context.Config.Passwords.Pepper) == 0 {
+		return "", ErrMissingPepper
+	}
+
+	switch context.passwordAlgorithm() {
+	case PASSWORD_BCRYPT:
+		hash, err := bcrypt.GenerateFromPassword(context.pepper(password), context.bcryptCost())
+		return string(hash), err
+
+	case PASSWORD_ARGON2ID:
+		p := context.argon2Params()
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey(context.pepper(password), salt, p.Time, p.Memory, p.Threads, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Time, p.Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	return "", ErrInvalidPasswordAlgorithm
+}
+
+// verifyPassword checks a password against a stored hash. rehash is true
+// when the hash doesn't match the current configuration anymore and should
+// be replaced
+func (context *APIContext) verifyPassword(hash, password string) (ok bool, rehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		p, salt, key, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false, false
+		}
+
+		k := argon2.IDKey(context.pepper(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(k, key) != 1 {
+			return false, false
+		}
+		return true, context.passwordAlgorithm() != PASSWORD_ARGON2ID || p != context.argon2Params()
+
+	case strings.HasPrefix(hash, "$2"):
+		legacy := false
+		if bcrypt.CompareHashAndPassword([]byte(hash), context.pepper(password)) != nil {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password+legacyPepper)) != nil {
+				return false, false
+			}
+			legacy = true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, legacy || err != nil || context.passwordAlgorithm() != PASSWORD_BCRYPT || cost != context.bcryptCost()
+	}
+
+	return false, false
+}
+
+// parseArgon2Hash decodes a hash in the $argon2id$v=19$m=,t=,p=$salt$key format
+func parseArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	p := argon2Params{}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"testing"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashing(t *testing.T) {
+	ctx := &APIContext{}
+	ctx.Config.Passwords.Pepper = "pepper"
+	ctx.Config.Passwords.Argon2.Memory = 1024
+
+	for _, algorithm := range []string{PASSWORD_ARGON2ID, PASSWORD_BCRYPT} {
+		ctx.Config.Passwords.Algorithm = algorithm
+		ctx.Config.Passwords.BcryptCost = bcrypt.MinCost
+
+		hash, err := ctx.hashPassword("secret")
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+
+		if ok, rehash := ctx.verifyPassword(hash, "secret"); !ok || rehash {
+			t.Errorf("%s: expected valid password without rehash, got %v %v", algorithm, ok, rehash)
+		}
+		if ok, _ := ctx.verifyPassword(hash, "wrong"); ok {
+			t.Errorf("%s: accepted wrong password", algorithm)
+		}
+
+		pepper := ctx.Config.Passwords.Pepper
+		ctx.Config.Passwords.Pepper = "other"
+		if ok, _ := ctx.verifyPassword(hash, "secret"); ok {
+			t.Errorf("%s: accepted password with a different pepper", algorithm)
+		}
+		ctx.Config.Passwords.Pepper = pepper
+	}
+
+	// hashes created with other settings need to be upgraded
+	ctx.Config.Passwords.Algorithm = PASSWORD_BCRYPT
+	hash, _ := ctx.hashPassword("secret")
+	ctx.Config.Passwords.Algorithm = PASSWORD_ARGON2ID
+	if ok, rehash := ctx.verifyPassword(hash, "secret"); !ok || !rehash {
+		t.Errorf("expected bcrypt hash to need a rehash, got %v %v", ok, rehash)
+	}
+
+	hash, _ = ctx.hashPassword("secret")
+	ctx.Config.Passwords.Argon2.Time = 2
+	if ok, rehash := ctx.verifyPassword(hash, "secret"); !ok || !rehash {
+		t.Errorf("expected argon2id hash with outdated parameters to need a rehash, got %v %v", ok, rehash)
+	}
+}
+
+func TestLegacyPasswordHash(t *testing.T) {
+	ctx := &APIContext{}
+	ctx.Config.Passwords.Pepper = "pepper"
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret"+legacyPepper), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, rehash := ctx.verifyPassword(string(legacy), "secret"); !ok || !rehash {
+		t.Errorf("expected legacy hash to be valid & need a rehash, got %v %v", ok, rehash)
+	}
+	if ok, _ := ctx.verifyPassword(string(legacy), "wrong"); ok {
+		t.Error("accepted wrong password for legacy hash")
+	}
+	if ok, _ := ctx.verifyPassword("mnop", "mnop"); ok {
+		t.Error("accepted a password that is not a hash")
+	}
+}
+
+func TestPasswordSettings(t *testing.T) {
+	c := config.Data{}
+	if err := CheckPasswordSettings(c); err != ErrMissingPepper {
+		t.Errorf("expected missing pepper to be rejected, got %v", err)
+	}
+
+	ctx := &APIContext{Config: c}
+	if _, err := ctx.hashPassword("secret"); err != ErrMissingPepper {
+		t.Errorf("expected hashing without pepper to fail, got %v", err)
+	}
+
+	c.Passwords.Pepper = "pepper"
+	if err := CheckPasswordSettings(c); err != nil {
+		t.Errorf("expected valid settings, got %v", err)
+	}
+
+	c.Passwords.Algorithm = "md5"
+	if err := CheckPasswordSettings(c); err != ErrInvalidPasswordAlgorithm {
+		t.Errorf("expected unknown algorithm to be rejected, got %v", err)
+	}
+}
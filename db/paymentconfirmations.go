@@ -0,0 +1,111 @@
+package db
+
+import (
+	"strconv"
+	"time"
+
+	money "github.com/Rhymond/go-money"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/mailer"
+)
+
+var defaultPaymentConfirmationTemplate = config.EmailTemplate{
+	Subject: "Thank you for your donation",
+	Text: "Hello {{.Name}},\n\n" +
+		"we received your donation of {{.Amount}} on {{.Date.Format \"2006-01-02\"}}" +
+		"{{if .Projects}} for {{range $i, $p := .Projects}}{{if $i}}, {{end}}{{$p}}{{end}}{{end}}.\n\n" +
+		"Thank you for your support!\n",
+}
+
+// paymentConfirmationMail holds the values available to payment confirmation
+// email templates
+type paymentConfirmationMail struct {
+	Payment *Payment
+	Name    string
+	// Amount is the formatted amount including its currency symbol
+	Amount   string
+	Currency string
+	Projects []string
+	Date     time.Time
+}
+
+// projectNames returns the names of all projects a payment's code distributes to
+func (payment *Payment) projectNames(context *APIContext) ([]string, error) {
+	code, err := context.LoadCodeByCode(payment.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	seen := make(map[int64]bool)
+	for _, b := range code.BudgetIDs {
+		bid, _ := strconv.ParseInt(b, 10, 64)
+		budget, err := context.LoadBudgetByID(bid)
+		if err != nil {
+			return nil, err
+		}
+		if budget.ProjectID == nil || seen[*budget.ProjectID] {
+			continue
+		}
+
+		project, err := context.GetProjectByID(*budget.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		seen[project.ID] = true
+		names = append(names, project.Name)
+	}
+
+	return names, nil
+}
+
+// SendConfirmation emails a donation confirmation to the donor of an
+// incoming payment. A payment gets confirmed at most once; failed deliveries
+// are recorded and can be retried
+func (payment *Payment) SendConfirmation(context *APIContext) error {
+	if len(payment.RemoteEmail) == 0 || payment.Amount <= 0 {
+		return nil
+	}
+
+	projects, err := payment.projectNames(context)
+	if err != nil {
+		return err
+	}
+
+	tmpl := context.Config.EmailTemplates.PaymentConfirmation
+	if len(tmpl.Subject) == 0 {
+		tmpl = defaultPaymentConfirmationTemplate
+	}
+
+	name := payment.RemoteName
+	if len(name) == 0 {
+		name = payment.RemoteEmail
+	}
+	mail, err := mailer.Render(tmpl, paymentConfirmationMail{
+		Payment:  payment,
+		Name:     name,
+		Amount:   money.New(payment.Amount, payment.Currency).Display(),
+		Currency: payment.Currency,
+		Projects: projects,
+		Date:     payment.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	delivery := EmailDelivery{
+		PaymentID: &payment.ID,
+		Kind:      EMAIL_PAYMENT_CONFIRMATION,
+		Recipient: payment.RemoteEmail,
+		Subject:   mail.Subject,
+		Text:      mail.Text,
+		HTML:      mail.HTML,
+	}
+	queued, err := context.queueEmailDelivery(&delivery)
+	if err != nil || !queued {
+		return err
+	}
+
+	return delivery.Deliver(context)
+}
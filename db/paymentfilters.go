@@ -0,0 +1,253 @@
+package db
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payments can be sorted by these keys, prefixed with '-' for descending order
+const (
+	PAYMENT_SORT_CREATED_AT = "created_at"
+	PAYMENT_SORT_AMOUNT     = "amount"
+)
+
+var (
+	// ErrInvalidCursor is the error returned for malformed pagination cursors
+	// or cursors of a different sort order
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	// ErrInvalidSort is the error returned for unknown sort keys
+	ErrInvalidSort = errors.New("Invalid sort order")
+)
+
+// PaymentFilter restricts which payments get loaded. Zero values don't
+// restrict anything, all given restrictions have to match
+type PaymentFilter struct {
+	BudgetID  *int64
+	UserID    *int64
+	Donor     string
+	Direction int
+	// Pending restricts payments to those awaiting their approval, or to
+	// those that don't
+	Pending   *bool
+	State     string
+	Source    string
+	Currency  string
+	Code      string
+	From      *time.Time
+	To        *time.Time
+	MinAmount *int64
+	MaxAmount *int64
+
+	// Sort is one of the PAYMENT_SORT keys, optionally prefixed with '-'.
+	// It defaults to created_at
+	Sort string
+	// Limit restricts the number of payments returned, zero returns all
+	Limit int
+	// Cursor continues after the last payment of a previous page
+	Cursor string
+}
+
+// PaymentPage is a page of payments matching a filter
+type PaymentPage struct {
+	Payments []Payment
+	// Total is the number of payments matching the filter on all pages
+	Total int64
+	// NextCursor continues with the next page, it's empty on the last one
+	NextCursor string
+}
+
+// where turns the filter into an SQL condition & its arguments
+func (filter *PaymentFilter) where() (string, []interface{}) {
+	conds := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.BudgetID != nil {
+		add("budget_id = $%d", *filter.BudgetID)
+	}
+	if filter.UserID != nil {
+		add("user_id = $%d", *filter.UserID)
+	}
+	if len(filter.Donor) > 0 {
+		add("remote_account = $%d", filter.Donor)
+	}
+	switch filter.Direction {
+	case TRANSACTION_INCOMING:
+		conds = append(conds, "amount > 0")
+	case TRANSACTION_OUTGOING:
+		conds = append(conds, "amount < 0")
+	}
+	if filter.Pending != nil {
+		if *filter.Pending {
+			conds = append(conds, "state IN ('received', 'matched')")
+		} else {
+			conds = append(conds, "state NOT IN ('received', 'matched')")
+		}
+	}
+	if len(filter.State) > 0 {
+		add("state = $%d", filter.State)
+	}
+	if len(filter.Source) > 0 {
+		add("source = $%d", filter.Source)
+	}
+	if len(filter.Currency) > 0 {
+		add("currency = $%d", strings.ToUpper(filter.Currency))
+	}
+	if len(filter.Code) > 0 {
+		add("code = $%d", filter.Code)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		add("amount >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		add("amount <= $%d", *filter.MaxAmount)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// sortKey returns the column payments get sorted by and whether the order
+// is descending
+func (filter *PaymentFilter) sortKey() (string, bool, error) {
+	key := filter.Sort
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	switch key {
+	case "":
+		return PAYMENT_SORT_CREATED_AT, desc, nil
+	case PAYMENT_SORT_CREATED_AT, PAYMENT_SORT_AMOUNT:
+		return key, desc, nil
+	}
+	return "", false, ErrInvalidSort
+}
+
+// LoadPaymentPage loads a page of the payments matching a filter, together
+// with the number of matching payments on all pages. Pages are sorted by
+// filter.Sort and the payments' IDs, so each payment shows up exactly once,
+// even when payments get added while paging
+func (context *APIContext) LoadPaymentPage(filter PaymentFilter) (PaymentPage, error) {
+	page := PaymentPage{Payments: []Payment{}}
+
+	key, desc, err := filter.sortKey()
+	if err != nil {
+		return page, err
+	}
+
+	where, args := filter.where()
+	err = context.QueryRow("SELECT COUNT(*) FROM payments WHERE "+where, args...).Scan(&page.Total)
+	if err != nil {
+		return page, err
+	}
+
+	// cursors record the direction as well, so they can't continue the
+	// reverse order
+	order, cmp, sort := "ASC", ">", key
+	if desc {
+		order, cmp, sort = "DESC", "<", "-"+key
+	}
+	if len(filter.Cursor) > 0 {
+		value, id, err := decodePaymentCursor(filter.Cursor, sort)
+		if err != nil {
+			return page, err
+		}
+		args = append(args, value, id)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d)", key, cmp, len(args)-1, len(args))
+	}
+
+	query := "SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, " +
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id " +
+		"FROM payments " +
+		"WHERE " + where + " " +
+		fmt.Sprintf("ORDER BY %s %s, id %s", key, order, order)
+	if filter.Limit > 0 {
+		// one more payment tells whether there's another page
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit+1)
+	}
+
+	rows, err := context.Query(query, args...)
+	if err != nil {
+		return page, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		payment := Payment{}
+		err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
+		if err != nil {
+			return page, err
+		}
+
+		page.Payments = append(page.Payments, payment)
+	}
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+
+	if filter.Limit > 0 && len(page.Payments) > filter.Limit {
+		page.Payments = page.Payments[:filter.Limit]
+		page.NextCursor = encodePaymentCursor(page.Payments[filter.Limit-1], sort)
+	}
+
+	return page, nil
+}
+
+// encodePaymentCursor returns an opaque cursor pointing behind a payment in
+// a sort order, which is a sort key optionally prefixed with '-'
+func encodePaymentCursor(payment Payment, sort string) string {
+	var value string
+	switch strings.TrimPrefix(sort, "-") {
+	case PAYMENT_SORT_AMOUNT:
+		value = strconv.FormatInt(payment.Amount, 10)
+	default:
+		value = payment.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(sort + "|" + value + "|" + strconv.FormatInt(payment.ID, 10)))
+}
+
+// decodePaymentCursor returns the sort value & ID a cursor points behind. The
+// cursor has to have been created for the same sort order
+func decodePaymentCursor(cursor, sort string) (interface{}, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 3 || parts[0] != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch strings.TrimPrefix(sort, "-") {
+	case PAYMENT_SORT_AMOUNT:
+		value, err = strconv.ParseInt(parts[1], 10, 64)
+	default:
+		value, err = time.Parse(time.RFC3339Nano, parts[1])
+	}
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	return value, id, nil
+}
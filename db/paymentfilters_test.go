@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPaymentFilterWhere(t *testing.T) {
+	pending := true
+	min := int64(100)
+	filter := PaymentFilter{
+		Direction: TRANSACTION_INCOMING,
+		Pending:   &pending,
+		Source:    "bitpay",
+		Currency:  "eur",
+		MinAmount: &min,
+	}
+
+	where, args := filter.where()
+	expected := "TRUE AND amount > 0 AND state IN ('received', 'matched') AND source = $1 AND currency = $2 AND amount >= $3"
+	if where != expected {
+		t.Errorf("expected %q, got %q", expected, where)
+	}
+	if len(args) != 3 || args[1] != "EUR" || args[2] != min {
+		t.Errorf("unexpected arguments %v", args)
+	}
+}
+
+func TestPaymentCursor(t *testing.T) {
+	p := Payment{ID: 42, Amount: -1250, CreatedAt: time.Date(2026, 10, 18, 12, 0, 0, 123456000, time.UTC)}
+
+	value, id, err := decodePaymentCursor(encodePaymentCursor(p, PAYMENT_SORT_CREATED_AT), PAYMENT_SORT_CREATED_AT)
+	if err != nil || id != 42 || !value.(time.Time).Equal(p.CreatedAt) {
+		t.Errorf("unexpected cursor %v %d %v", value, id, err)
+	}
+
+	value, id, err = decodePaymentCursor(encodePaymentCursor(p, PAYMENT_SORT_AMOUNT), PAYMENT_SORT_AMOUNT)
+	if err != nil || id != 42 || value.(int64) != -1250 {
+		t.Errorf("unexpected cursor %v %d %v", value, id, err)
+	}
+
+	// cursors only continue the sort order they were created for
+	if _, _, err = decodePaymentCursor(encodePaymentCursor(p, PAYMENT_SORT_AMOUNT), PAYMENT_SORT_CREATED_AT); err != ErrInvalidCursor {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+	if _, _, err = decodePaymentCursor(encodePaymentCursor(p, PAYMENT_SORT_CREATED_AT), "-"+PAYMENT_SORT_CREATED_AT); err != ErrInvalidCursor {
+		t.Errorf("expected invalid cursor for the reverse order, got %v", err)
+	}
+	if _, id, err = decodePaymentCursor(encodePaymentCursor(p, "-"+PAYMENT_SORT_AMOUNT), "-"+PAYMENT_SORT_AMOUNT); err != nil || id != 42 {
+		t.Errorf("unexpected descending cursor %d %v", id, err)
+	}
+	if _, _, err = decodePaymentCursor("garbage!", PAYMENT_SORT_AMOUNT); err != ErrInvalidCursor {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+}
@@ -1,13 +1,15 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	money "github.com/Rhymond/go-money"
-	"github.com/muesli/toktok"
+	log "github.com/sirupsen/logrus"
 	"gitlab.techcultivation.org/sangha/mq"
 )
 
@@ -22,12 +24,46 @@ type Payment struct {
 	Purpose             string
 	RemoteAccount       string
 	RemoteName          string
+	RemoteEmail         string
 	RemoteTransactionID string
 	RemoteBankID        string
 	Source              string
-	Pending             bool
+	// State is one of the PAYMENT_STATE_* states
+	State string
+
+	// RemoteAmount is the amount in the smallest unit of RemoteCurrency, for
+	// payments made in a currency we can't book, e.g. a crypto currency
+	RemoteAmount   string
+	RemoteCurrency string
+
+	// RefundedAt is set once a payment has been refunded or charged back.
+	// RefundKind is one of the PAYMENT_REFUND_* kinds
+	RefundedAt *time.Time
+	RefundKind string
+
+	// SepaExportID is the SEPA credit transfer export an outgoing payment
+	// has been exported with
+	SepaExportID *int64
+
+	// UserID is the account of the donor, if they are known by a verified
+	// email address or bank account
+	UserID *int64
+
+	// RemoteEmailVerified is true if the payment source verified that the
+	// payer owns RemoteEmail. It is only used when saving a payment
+	RemoteEmailVerified bool
+
+	// IdempotencyKey is the client supplied key a payment got stored with.
+	// It is only used when saving a payment
+	IdempotencyKey string
 }
 
+var (
+	// ErrPaymentExists is the error returned when saving a payment that has
+	// already been stored
+	ErrPaymentExists = errors.New("Payment has already been stored")
+)
+
 // LoadPaymentByID loads a payment by ID from the database
 func (context *APIContext) LoadPaymentByID(id int64) (Payment, error) {
 	payment := Payment{}
@@ -36,12 +72,12 @@ func (context *APIContext) LoadPaymentByID(id int64) (Payment, error) {
 	}
 
 	err := context.QueryRow("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source, pending "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
 		"FROM payments "+
 		"WHERE id = $1", id).
 		Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
-			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteTransactionID, &payment.RemoteBankID,
-			&payment.Source, &payment.Pending)
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
 	return payment, err
 }
@@ -51,7 +87,7 @@ func (budget *Budget) LoadPayments(context *APIContext) ([]Payment, error) {
 	payments := []Payment{}
 
 	rows, err := context.Query("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source, pending "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
 		"FROM payments "+
 		"WHERE budget_id = $1 "+
 		"ORDER BY created_at ASC", budget.ID)
@@ -63,8 +99,8 @@ func (budget *Budget) LoadPayments(context *APIContext) ([]Payment, error) {
 	for rows.Next() {
 		payment := Payment{}
 		err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
-			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteTransactionID, &payment.RemoteBankID,
-			&payment.Source, &payment.Pending)
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
 		if err != nil {
 			return payments, err
@@ -81,7 +117,7 @@ func (context *APIContext) LoadPaymentsForDonor(donor string) ([]Payment, error)
 	payments := []Payment{}
 
 	rows, err := context.Query("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source, pending "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
 		"FROM payments "+
 		"WHERE remote_account = $1 "+
 		"ORDER BY created_at ASC", donor)
@@ -93,8 +129,8 @@ func (context *APIContext) LoadPaymentsForDonor(donor string) ([]Payment, error)
 	for rows.Next() {
 		payment := Payment{}
 		err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
-			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteTransactionID, &payment.RemoteBankID,
-			&payment.Source, &payment.Pending)
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
 		if err != nil {
 			return payments, err
@@ -106,7 +142,7 @@ func (context *APIContext) LoadPaymentsForDonor(donor string) ([]Payment, error)
 	return payments, err
 }
 
-// LoadPendingPayment loads all pending payments
+// LoadPendingPayments loads all payments awaiting their approval
 func (context *APIContext) LoadPendingPayments(direction int) ([]Payment, error) {
 	payments := []Payment{}
 
@@ -119,9 +155,9 @@ func (context *APIContext) LoadPendingPayments(direction int) ([]Payment, error)
 	}
 
 	rows, err := context.Query(fmt.Sprintf("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source, pending "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
 		"FROM payments "+
-		"WHERE pending = true %s "+
+		"WHERE state IN ('received', 'matched') %s "+
 		"ORDER BY created_at ASC", filter))
 
 	if err != nil {
@@ -132,8 +168,8 @@ func (context *APIContext) LoadPendingPayments(direction int) ([]Payment, error)
 	for rows.Next() {
 		payment := Payment{}
 		err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
-			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteTransactionID, &payment.RemoteBankID,
-			&payment.Source, &payment.Pending)
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
 		if err != nil {
 			return payments, err
@@ -145,179 +181,586 @@ func (context *APIContext) LoadPendingPayments(direction int) ([]Payment, error)
 	return payments, err
 }
 
-// Process turns a payment into various budget transactions
+// Process turns a payment into various budget transactions. The payment gets
+// booked entirely or not at all
 func (payment *Payment) Process(context *APIContext, cutBudget int64) error {
+	return context.Transact(func(tx *APIContextTx) error {
+		return payment.ProcessTx(tx, cutBudget)
+	})
+}
+
+// ProcessTx turns a payment into various budget transactions within an
+// existing transaction. Only approved payments get booked, which moves them
+// into the processed state, so a payment can't be booked twice. Outgoing
+// payments from Processing.ApprovalThreshold on also need an approved
+// approval request
+func (payment *Payment) ProcessTx(tx *APIContextTx, cutBudget int64) error {
+	context := tx.Context()
+
+	// the payment stays locked until it has been booked
+	err := payment.transition(tx, PAYMENT_STATE_PROCESSED)
+	if err != nil {
+		return err
+	}
+
+	if payment.Amount < 0 && context.NeedsApproval(payment.Amount) {
+		ok, err := paymentApproved(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrApprovalRequired
+		}
+	}
+
+	return payment.book(tx, cutBudget)
+}
+
+// Kinds of entries a payment preview consists of
+const (
+	PREVIEW_PAYMENT   = "payment"
+	PREVIEW_TRANSFER  = "transfer"
+	PREVIEW_CUT       = "cut"
+	PREVIEW_REMAINDER = "remainder"
+)
+
+// PreviewEntry is a single booking processing a payment would make. Amounts
+// are given in the receiving budget's currency; transfers to budgets using
+// another currency get converted when they're booked. Remainder entries
+// don't get booked on their own, they state how much of a budget's share is
+// due to rounding the distribution
+type PreviewEntry struct {
+	Kind         string
+	BudgetID     int64
+	FromBudgetID *int64
+	Amount       int64
+	Currency     string
+}
+
+// Preview returns what processing a matched or approved payment would book,
+// including the remainders of rounding its distribution. Nothing gets
+// written or locked
+func (payment *Payment) Preview(context *APIContext, cutBudget int64) ([]PreviewEntry, error) {
+	entries := []PreviewEntry{}
+	if payment.State != PAYMENT_STATE_MATCHED && payment.State != PAYMENT_STATE_APPROVED {
+		return entries, ErrInvalidTransition
+	}
+
+	plan, err := payment.plan(context, context, cutBudget)
+	if err != nil {
+		return entries, err
+	}
+
+	entries = append(entries, PreviewEntry{
+		Kind:     PREVIEW_PAYMENT,
+		BudgetID: payment.BudgetID,
+		Amount:   plan.Amount,
+		Currency: plan.Currency,
+	})
+	for _, pt := range plan.Transfers {
+		if pt.ToBudgetID != payment.BudgetID {
+			kind := PREVIEW_TRANSFER
+			if pt.Cut {
+				kind = PREVIEW_CUT
+			}
+			entries = append(entries, PreviewEntry{
+				Kind:         kind,
+				BudgetID:     pt.ToBudgetID,
+				FromBudgetID: &payment.BudgetID,
+				Amount:       pt.Amount,
+				Currency:     plan.Currency,
+			})
+		}
+	}
+	for _, pt := range plan.Transfers {
+		if pt.Remainder != 0 {
+			entries = append(entries, PreviewEntry{
+				Kind:     PREVIEW_REMAINDER,
+				BudgetID: pt.ToBudgetID,
+				Amount:   pt.Remainder,
+				Currency: plan.Currency,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// paymentPlan is how a payment gets booked: its amount converted into the
+// receiving budget's currency and the transfers distributing it with the
+// processing cuts in percent
+type paymentPlan struct {
+	Amount         int64
+	Currency       string
+	ExchangeRateID *int64
+	Cuts           []int
+	Transfers      []plannedTransfer
+}
+
+// plan works out how a payment gets booked without writing anything
+func (payment *Payment) plan(context *APIContext, db sqlAdapter, cutBudget int64) (paymentPlan, error) {
+	plan := paymentPlan{}
+
 	code, err := context.LoadCodeByCode(payment.Code)
+	if err != nil {
+		return plan, err
+	}
+
+	// the payment gets booked in the currency of the receiving budget
+	if plan.Currency, err = budgetCurrency(db, payment.BudgetID); err != nil {
+		return plan, err
+	}
+	plan.Amount = payment.Amount
+	if payment.Currency != plan.Currency {
+		rate, err := loadExchangeRate(db, payment.Currency, plan.Currency, payment.CreatedAt)
+		if err != nil {
+			return plan, err
+		}
+		plan.Amount, err = rate.Convert(payment.Amount, payment.Currency)
+		if err != nil {
+			return plan, err
+		}
+		plan.ExchangeRateID = &rate.ID
+	}
+
+	plan.Cuts, err = context.processingCuts(code, payment.Amount > 0)
+	if err != nil {
+		return plan, err
+	}
+	plan.Transfers, err = planDistribution(code, plan.Cuts, cutBudget, plan.Amount, plan.Currency)
+	return plan, err
+}
+
+// book stores the transactions a payment gets distributed with. The cuts it
+// paid are recorded, so the distribution can be verified after projects'
+// cuts changed
+func (payment *Payment) book(tx *APIContextTx, cutBudget int64) error {
+	plan, err := payment.plan(tx.Context(), tx, cutBudget)
 	if err != nil {
 		return err
 	}
 
-	var ratios []int
-	for _, r := range code.Ratios {
-		ratio, _ := strconv.ParseInt(r, 10, 64)
-		ratios = append(ratios, int(ratio))
+	cuts := BigintSlice{}
+	for _, c := range plan.Cuts {
+		cuts = append(cuts, int64(c))
+	}
+	_, err = tx.Exec("UPDATE payments SET processing_cuts = $1, cut_budget_id = $2 WHERE id = $3", cuts, cutBudget, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	// transaction to cct account
+	t := Transaction{
+		BudgetID:       payment.BudgetID,
+		Amount:         plan.Amount,
+		CreatedAt:      payment.CreatedAt, // FIXME: time.Now().UTC(),
+		Purpose:        payment.Purpose,
+		PaymentID:      &payment.ID,
+		Currency:       plan.Currency,
+		ExchangeRateID: plan.ExchangeRateID,
+	}
+	if err = t.Save(tx); err != nil {
+		return err
+	}
+
+	// transfers get converted into each budget's currency. Shares staying in
+	// the receiving budget don't need a transfer
+	for _, pt := range plan.Transfers {
+		if pt.ToBudgetID == payment.BudgetID {
+			continue
+		}
+		_, err = tx.Transfer(payment.BudgetID, pt.ToBudgetID, pt.Amount, payment.Purpose, payment.ID, payment.CreatedAt)
+		if err != nil {
+			return err
+		}
 	}
 
-	var budgets []Budget
-	var cuts []int
+	return nil
+}
+
+// plannedTransfer is a single share a payment gets distributed with.
+// Remainder is the part of Amount that's due to rounding
+type plannedTransfer struct {
+	ToBudgetID int64
+	Amount     int64
+	Cut        bool
+	Remainder  int64
+}
+
+// processingCuts returns the cut in percent the project of each of a code's
+// budgets currently takes. Only incoming payments pay cuts
+func (context *APIContext) processingCuts(code Code, incoming bool) ([]int, error) {
+	cuts := []int{}
 	for _, b := range code.BudgetIDs {
 		bid, _ := strconv.ParseInt(b, 10, 64)
 		budget, err := context.LoadBudgetByID(bid)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		p, err := context.GetProjectByID(*budget.ProjectID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if payment.Amount > 0 {
+		if incoming {
 			cuts = append(cuts, int(p.ProcessingCut))
 		} else {
 			cuts = append(cuts, int(0))
 		}
-		budgets = append(budgets, budget)
 	}
 
-	// transaction to cct account
-	t := Transaction{
-		BudgetID:  payment.BudgetID,
-		Amount:    payment.Amount,
-		CreatedAt: payment.CreatedAt, // FIXME: time.Now().UTC(),
-		Purpose:   payment.Purpose,
-		PaymentID: &payment.ID,
+	return cuts, nil
+}
+
+// planDistribution splits an amount booked in the receiving budget according
+// to a code's ratios, taking a cut in percent from each budget's share for
+// cutBudget. Shares of zero get dropped
+func planDistribution(code Code, cuts []int, cutBudget int64, amount int64, currency string) ([]plannedTransfer, error) {
+	if len(cuts) != len(code.BudgetIDs) {
+		return nil, fmt.Errorf("expected %d processing cuts, got %d", len(code.BudgetIDs), len(cuts))
 	}
-	if err = t.Save(context); err != nil {
-		return err
+
+	var ratios []int
+	for _, r := range code.Ratios {
+		ratio, _ := strconv.ParseInt(r, 10, 64)
+		ratios = append(ratios, int(ratio))
+	}
+
+	shares, err := distribute(amount, currency, ratios, cuts)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := []plannedTransfer{}
+	for idx, b := range code.BudgetIDs {
+		bid, _ := strconv.ParseInt(b, 10, 64)
+		sh := shares[idx]
+		if sh.Amount != 0 {
+			transfers = append(transfers, plannedTransfer{ToBudgetID: bid, Amount: sh.Amount, Remainder: sh.Remainder})
+		}
+		if sh.Cut != 0 {
+			transfers = append(transfers, plannedTransfer{ToBudgetID: cutBudget, Amount: sh.Cut, Cut: true, Remainder: sh.CutRemainder})
+		}
+	}
+
+	return transfers, nil
+}
+
+// distributionShare is a budget's share of a distributed amount and the
+// processing cut taken from it. The remainders are the parts exceeding the
+// truncated exact shares, which money.Allocate hands out to the first shares
+type distributionShare struct {
+	Amount       int64
+	Remainder    int64
+	Cut          int64
+	CutRemainder int64
+}
+
+// distribute splits amount by ratios, then takes a cut in percent from each
+// share
+func distribute(amount int64, currency string, ratios, cuts []int) ([]distributionShare, error) {
+	var sum int64
+	for _, r := range ratios {
+		sum += int64(r)
+	}
+
+	total := money.New(amount, currency)
+	parties, err := total.Allocate(ratios...)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := []distributionShare{}
+	for idx := range ratios {
+		party := money.New(parties[idx].Amount(), currency)
+		fees, err := party.Allocate(cuts[idx], 100-cuts[idx])
+		if err != nil {
+			return nil, err
+		}
+
+		// the truncated exact share of amount * ratio/sum * pct/100
+		exact := func(pct int) int64 {
+			return amount * int64(ratios[idx]) * int64(pct) / (sum * 100)
+		}
+		shares = append(shares, distributionShare{
+			Amount:       fees[1].Amount(),
+			Remainder:    fees[1].Amount() - exact(100-cuts[idx]),
+			Cut:          fees[0].Amount(),
+			CutRemainder: fees[0].Amount() - exact(cuts[idx]),
+		})
+	}
+
+	return shares, nil
+}
+
+// Update a payment's code and move it into payment.State, if that's set.
+// Codes can only be changed until a payment gets approved; received payments
+// become matched once they got a code. Changing the code voids the payment's
+// approval requests. Approved payments are only queued for processing once
+// the update has been committed. If they can't be queued, they move back to
+// matched and need to be approved again. Donors get a confirmation once their
+// payment has been approved. Outgoing payments from
+// Processing.ApprovalThreshold on can only be approved through an approval
+// request and return ErrApprovalRequired otherwise, after their code has been
+// updated
+func (payment *Payment) Update(context *APIContext) error {
+	// payments only get processed & refunded by booking them
+	to := payment.State
+	switch to {
+	case "", PAYMENT_STATE_MATCHED, PAYMENT_STATE_APPROVED:
+	default:
+		return ErrInvalidTransition
 	}
 
-	eur := money.New(payment.Amount, "EUR")
-	parties, err := eur.Allocate(ratios...)
+	_, err := context.LoadCodeByCode(payment.Code)
 	if err != nil {
 		return err
 	}
 
-	for idx, b := range budgets {
-		peur := money.New(parties[idx].Amount(), "EUR")
-		fees, err := peur.Allocate(cuts[idx], 100-cuts[idx])
+	var approved, needsApproval bool
+	err = context.Transact(func(tx *APIContextTx) error {
+		var code string
+		err := tx.QueryRow("SELECT state, code FROM payments WHERE id = $1 FOR UPDATE", payment.ID).Scan(&payment.State, &code)
 		if err != nil {
 			return err
 		}
 
-		if fees[1].Amount() != 0 && payment.BudgetID != b.ID {
-			_, err = context.Transfer(payment.BudgetID, b.ID, fees[1].Amount(), payment.Purpose, payment.ID, payment.CreatedAt)
+		if code != payment.Code {
+			switch payment.State {
+			case PAYMENT_STATE_RECEIVED:
+				if err = payment.transition(tx, PAYMENT_STATE_MATCHED); err != nil {
+					return err
+				}
+			case PAYMENT_STATE_MATCHED:
+			default:
+				return ErrPaymentLocked
+			}
+
+			_, err = tx.Exec("UPDATE payments SET code = $1 WHERE id = $2", payment.Code, payment.ID)
 			if err != nil {
 				return err
 			}
+			if err = supersedePaymentApprovals(tx, payment.ID); err != nil {
+				return err
+			}
 		}
 
-		if fees[0].Amount() != 0 {
-			_, err = context.Transfer(payment.BudgetID, cutBudget, fees[0].Amount(), payment.Purpose, payment.ID, payment.CreatedAt)
+		if len(to) == 0 || to == payment.State {
+			return nil
+		}
+		if to == PAYMENT_STATE_APPROVED && payment.Amount < 0 && context.NeedsApproval(payment.Amount) {
+			ok, err := paymentApproved(tx, payment.ID)
 			if err != nil {
 				return err
 			}
+			if !ok {
+				// keep the code change, the approval has to be requested
+				needsApproval = true
+				return nil
+			}
 		}
+		approved = to == PAYMENT_STATE_APPROVED
+		return payment.transition(tx, to)
+	})
+	if err == nil && needsApproval {
+		return ErrApprovalRequired
+	}
+	if err != nil || !approved {
+		return err
 	}
 
-	return nil
-}
+	p := mq.Payment{
+		Name:    payment.RemoteName,
+		Address: []string{},
 
-// Update a payment in the database
-func (payment *Payment) Update(context *APIContext) error {
-	_, err := context.LoadCodeByCode(payment.Code)
-	if err != nil {
+		DateTime: payment.CreatedAt,
+		Amount:   payment.Amount,
+		Currency: payment.Currency,
+
+		TransactionCode: payment.Code,
+		Description:     payment.Purpose,
+
+		Source:              payment.Source,
+		SourceID:            payment.RemoteBankID,
+		SourcePayerID:       payment.RemoteAccount,
+		SourceTransactionID: payment.RemoteTransactionID,
+
+		BudgetID:  payment.BudgetID,
+		PaymentID: payment.ID,
+	}
+	if err = p.Process(); err != nil {
+		context.Transact(func(tx *APIContextTx) error {
+			return payment.transition(tx, PAYMENT_STATE_MATCHED)
+		})
 		return err
 	}
 
-	_, err = context.Exec("UPDATE payments SET code = $1, pending = $2 WHERE id = $3",
-		payment.Code, payment.Pending, payment.ID)
-	if err != nil {
-		return err
+	// failed deliveries are recorded and get retried by 'mail retry'
+	if err = payment.SendConfirmation(context); err != nil {
+		log.WithFields(log.Fields{
+			"Payment": payment.ID,
+			"Error":   err,
+		}).Warn("Can't send payment confirmation")
 	}
 
-	if !payment.Pending {
-		p := mq.Payment{
-			Name:    payment.RemoteName,
-			Address: []string{},
+	return nil
+}
 
-			DateTime: payment.CreatedAt,
-			Amount:   payment.Amount,
-			Currency: payment.Currency,
+// LoadPaymentByIdempotencyKey loads the payment that got stored with an
+// idempotency key from the database
+func (context *APIContext) LoadPaymentByIdempotencyKey(key string) (Payment, error) {
+	payment := Payment{}
+	if len(key) == 0 {
+		return payment, sql.ErrNoRows
+	}
 
-			TransactionCode: payment.Code,
-			Description:     payment.Purpose,
+	err := context.QueryRow("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
+		"FROM payments "+
+		"WHERE idempotency_key = $1", key).
+		Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
-			Source:              payment.Source,
-			SourceID:            payment.RemoteBankID,
-			SourcePayerID:       payment.RemoteAccount,
-			SourceTransactionID: payment.RemoteTransactionID,
+	return payment, err
+}
 
-			BudgetID:  payment.BudgetID,
-			PaymentID: payment.ID,
-		}
-		err = p.Process()
+// LoadPaymentBySourceTransactionID loads the payment a source reported with
+// a transaction ID from the database
+func (context *APIContext) LoadPaymentBySourceTransactionID(source, transactionID string) (Payment, error) {
+	payment := Payment{}
+	if len(transactionID) == 0 {
+		return payment, sql.ErrNoRows
 	}
 
-	return err
+	err := context.QueryRow("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
+		"FROM payments "+
+		"WHERE source = $1 AND remote_transaction_id = $2", source, transactionID).
+		Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
+
+	return payment, err
 }
 
-// Save a payment to the database
-func (payment *Payment) Save(context *APIContext) error {
-	if payment.Code == "" {
-		r := regexp.MustCompile("[^\\W]+")
-		p := r.FindAllString(payment.Purpose, -1)
+// loadStoredPayment loads the payment a new payment duplicates, either by
+// its idempotency key or by its provider's transaction ID
+func (context *APIContext) loadStoredPayment(payment *Payment) (Payment, error) {
+	stored := Payment{}
 
-		codes, err := context.LoadAllCodes()
-		if err != nil {
-			panic(err)
-		}
-		tokens := []string{}
-		for _, code := range codes {
-			tokens = append(tokens, code.Code)
+	err := context.QueryRow("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
+		"FROM payments "+
+		"WHERE idempotency_key = NULLIF($1, '') OR "+
+		"(source = $2 AND remote_transaction_id = $3 AND remote_transaction_id <> '') "+
+		"ORDER BY id ASC LIMIT 1", payment.IdempotencyKey, payment.Source, payment.RemoteTransactionID).
+		Scan(&stored.ID, &stored.BudgetID, &stored.CreatedAt, &stored.Amount, &stored.Currency, &stored.Code,
+			&stored.Purpose, &stored.RemoteAccount, &stored.RemoteName, &stored.RemoteEmail, &stored.RemoteTransactionID, &stored.RemoteBankID,
+			&stored.RemoteAmount, &stored.RemoteCurrency, &stored.Source, &stored.State, &stored.RefundedAt, &stored.RefundKind, &stored.SepaExportID, &stored.UserID)
+
+	return stored, err
+}
+
+// Save a payment to the database. Payments are only stored once per
+// idempotency key and provider transaction ID: saving a duplicate loads the
+// stored payment and returns ErrPaymentExists. Codes reported by providers
+// are dropped unless they exist, and payments without a code get matched by
+// their purpose. Callers saving many payments should pass a matcher, one gets
+// loaded on demand otherwise
+func (payment *Payment) Save(context *APIContext, matcher *CodeMatcher) error {
+	if payment.Code != "" {
+		_, err := context.LoadCodeByCode(payment.Code)
+		if err == sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"Source": payment.Source,
+				"Code":   payment.Code,
+			}).Warn("Dropped unknown code of payment")
+			payment.Code = ""
+		} else if err != nil {
+			return err
 		}
+	}
 
-		// FIXME: we want to populate the bucket at startup
-		bucket, _ := toktok.NewBucket(8)
-		bucket.LoadTokens(tokens)
-
-		ldist := 32768
-		var lmatch string
-		for _, c := range p {
-			fmt.Println("Resolving", c)
-			match, dist := bucket.Resolve(c)
-			if dist < ldist && dist < 4 {
-				ldist = dist
-				lmatch = match
+	if payment.Code == "" {
+		if matcher == nil {
+			var err error
+			if matcher, err = context.NewCodeMatcher(); err != nil {
+				return err
 			}
-			fmt.Println("Match", match, dist)
 		}
-		fmt.Println("Lowest Match", lmatch, ldist)
-		if ldist < 2 {
-			payment.Code = lmatch
+		if code, ok := matcher.Assign(payment.Purpose); ok {
+			log.WithFields(log.Fields{
+				"Purpose": payment.Purpose,
+				"Code":    code,
+			}).Debug("Matched payment purpose to code")
+			payment.Code = code
+		}
+	}
+
+	if payment.Currency == "" {
+		payment.Currency = DefaultCurrency
+	}
+	payment.Currency = strings.ToUpper(payment.Currency)
+
+	payment.State = PAYMENT_STATE_RECEIVED
+	if len(payment.Code) > 0 {
+		payment.State = PAYMENT_STATE_MATCHED
+	}
+
+	// the payment's initial state gets recorded as its first transition.
+	// Known donors get linked to the payment
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		if payment.UserID, err = donorForPayment(tx, payment); err != nil {
+			return err
 		}
+
+		err = tx.QueryRow("INSERT INTO payments (budget_id, created_at, amount, currency, code, purpose, remote_account, "+
+			"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, idempotency_key, state, user_id) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17) "+
+			"ON CONFLICT DO NOTHING "+
+			"RETURNING id",
+			payment.BudgetID, payment.CreatedAt, payment.Amount, payment.Currency, payment.Code, payment.Purpose, payment.RemoteAccount,
+			payment.RemoteName, payment.RemoteEmail, payment.RemoteTransactionID, payment.RemoteBankID, payment.RemoteAmount,
+			payment.RemoteCurrency, payment.Source, payment.IdempotencyKey, payment.State, payment.UserID).Scan(&payment.ID)
+		if err != nil {
+			return err
+		}
+
+		if err = recordTransition(tx, payment.ID, "", payment.State); err != nil {
+			return err
+		}
+		return verifyDonorAccount(tx, payment)
+	})
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	stored, err := context.loadStoredPayment(payment)
+	if err != nil {
+		return err
 	}
 
-	err := context.QueryRow("INSERT INTO payments (budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) "+
-		"RETURNING id",
-		payment.BudgetID, payment.CreatedAt, payment.Amount, payment.Currency, payment.Code, payment.Purpose, payment.RemoteAccount,
-		payment.RemoteName, payment.RemoteTransactionID, payment.RemoteBankID, payment.Source).Scan(&payment.ID)
-	return err
+	*payment = stored
+	return ErrPaymentExists
 }
 
+// LatestPaymentFromSource loads the latest payment a source reported. Payments
+// without a transaction ID of the source, e.g. manually registered ones, are
+// not considered
 func (context *APIContext) LatestPaymentFromSource(source string) (Payment, error) {
 	payment := Payment{}
 
 	err := context.QueryRow("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
-		"remote_name, remote_transaction_id, remote_bank_id, source, pending "+
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
 		"FROM payments "+
-		"WHERE source = $1 "+
+		"WHERE source = $1 AND remote_transaction_id <> '' "+
 		"ORDER BY created_at DESC LIMIT 1", source).
 		Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
-			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteTransactionID, &payment.RemoteBankID,
-			&payment.Source, &payment.Pending)
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
 
 	return payment, err
 }
@@ -0,0 +1,33 @@
+package db
+
+import "testing"
+
+func TestDistribute(t *testing.T) {
+	// 1001 cents split 1:1:1 leaves two cents, handed to the first shares.
+	// A 10% cut of the first share of 334 leaves another cent, which goes
+	// to the cut
+	shares, err := distribute(1001, "EUR", []int{1, 1, 1}, []int{10, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []distributionShare{
+		{Amount: 300, Remainder: 0, Cut: 34, CutRemainder: 1},
+		{Amount: 334, Remainder: 1, Cut: 0, CutRemainder: 0},
+		{Amount: 333, Remainder: 0, Cut: 0, CutRemainder: 0},
+	}
+	if len(shares) != len(expected) {
+		t.Fatalf("expected %d shares, got %d", len(expected), len(shares))
+	}
+
+	var total int64
+	for i, s := range shares {
+		if s != expected[i] {
+			t.Errorf("share %d: expected %+v, got %+v", i, expected[i], s)
+		}
+		total += s.Amount + s.Cut
+	}
+	if total != 1001 {
+		t.Errorf("expected shares to add up to 1001, got %d", total)
+	}
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// A payment is received without a code or matched to one. Once approved it
+// gets queued for processing, which books it. Payments can be refunded in
+// any state
+const (
+	PAYMENT_STATE_RECEIVED  = "received"
+	PAYMENT_STATE_MATCHED   = "matched"
+	PAYMENT_STATE_APPROVED  = "approved"
+	PAYMENT_STATE_PROCESSED = "processed"
+	PAYMENT_STATE_REFUNDED  = "refunded"
+)
+
+var (
+	// ErrInvalidTransition is the error returned when moving a payment into
+	// a state it can't reach from its current state
+	ErrInvalidTransition = errors.New("Payment can't be moved into this state")
+	// ErrPaymentLocked is the error returned when changing the code of a
+	// payment that has already been approved
+	ErrPaymentLocked = errors.New("Payment can't be changed anymore")
+
+	// paymentTransitions lists the states a payment can be moved into from
+	// each state. Approved payments move back to matched if they can't be
+	// queued for processing
+	paymentTransitions = map[string][]string{
+		PAYMENT_STATE_RECEIVED:  {PAYMENT_STATE_MATCHED, PAYMENT_STATE_REFUNDED},
+		PAYMENT_STATE_MATCHED:   {PAYMENT_STATE_APPROVED, PAYMENT_STATE_REFUNDED},
+		PAYMENT_STATE_APPROVED:  {PAYMENT_STATE_MATCHED, PAYMENT_STATE_PROCESSED, PAYMENT_STATE_REFUNDED},
+		PAYMENT_STATE_PROCESSED: {PAYMENT_STATE_REFUNDED},
+	}
+)
+
+// PaymentTransition represents the db schema of a payment's move from one
+// state into another
+type PaymentTransition struct {
+	ID        int64
+	PaymentID int64
+	From      string
+	To        string
+	UserID    *int64
+	CreatedAt time.Time
+}
+
+// ValidPaymentState returns true if state is one of the PAYMENT_STATE_* states
+func ValidPaymentState(state string) bool {
+	_, ok := paymentTransitions[state]
+	return ok || state == PAYMENT_STATE_REFUNDED
+}
+
+// CanTransition returns true if a payment can be moved from one state into
+// another
+func CanTransition(from, to string) bool {
+	for _, s := range paymentTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns true while a payment waits for its approval
+func (payment *Payment) Pending() bool {
+	return payment.State == PAYMENT_STATE_RECEIVED || payment.State == PAYMENT_STATE_MATCHED
+}
+
+// transition moves a payment into a new state within a transaction and
+// records who moved it. Payments moving back from approved to matched lose
+// their approvals. The payment stays locked until the transaction ends
+func (payment *Payment) transition(tx *APIContextTx, to string) error {
+	var from string
+	err := tx.QueryRow("SELECT state FROM payments WHERE id = $1 FOR UPDATE", payment.ID).Scan(&from)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(from, to) {
+		if from == PAYMENT_STATE_REFUNDED {
+			return ErrPaymentRefunded
+		}
+		return ErrInvalidTransition
+	}
+
+	if _, err = tx.Exec("UPDATE payments SET state = $1 WHERE id = $2", to, payment.ID); err != nil {
+		return err
+	}
+	if err = recordTransition(tx, payment.ID, from, to); err != nil {
+		return err
+	}
+	if from == PAYMENT_STATE_APPROVED && to == PAYMENT_STATE_MATCHED {
+		// matched payments can be changed, their approval doesn't apply anymore
+		if err = supersedePaymentApprovals(tx, payment.ID); err != nil {
+			return err
+		}
+	}
+
+	payment.State = to
+	return nil
+}
+
+// recordTransition stores a payment's move into a new state. The user of
+// the transaction's context is recorded as its actor, if there is one
+func recordTransition(tx *APIContextTx, paymentID int64, from, to string) error {
+	var userID *int64
+	if auth := tx.Context().Auth; auth != nil && auth.ID > 0 {
+		userID = &auth.ID
+	}
+
+	_, err := tx.Exec("INSERT INTO payment_transitions (payment_id, from_state, to_state, user_id, created_at) VALUES ($1, $2, $3, $4, $5)",
+		paymentID, from, to, userID, time.Now().UTC())
+	return err
+}
+
+// LoadTransitions loads the state transitions of a payment, oldest first
+func (payment *Payment) LoadTransitions(context *APIContext) ([]PaymentTransition, error) {
+	transitions := []PaymentTransition{}
+
+	rows, err := context.Query("SELECT id, payment_id, from_state, to_state, user_id, created_at "+
+		"FROM payment_transitions "+
+		"WHERE payment_id = $1 "+
+		"ORDER BY id ASC", payment.ID)
+	if err != nil {
+		return transitions, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		t := PaymentTransition{}
+		err = rows.Scan(&t.ID, &t.PaymentID, &t.From, &t.To, &t.UserID, &t.CreatedAt)
+		if err != nil {
+			return transitions, err
+		}
+
+		transitions = append(transitions, t)
+	}
+
+	return transitions, rows.Err()
+}
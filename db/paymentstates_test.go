@@ -0,0 +1,41 @@
+package db
+
+import "testing"
+
+func TestPaymentTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{PAYMENT_STATE_RECEIVED, PAYMENT_STATE_MATCHED, true},
+		{PAYMENT_STATE_RECEIVED, PAYMENT_STATE_APPROVED, false},
+		{PAYMENT_STATE_MATCHED, PAYMENT_STATE_APPROVED, true},
+		{PAYMENT_STATE_MATCHED, PAYMENT_STATE_PROCESSED, false},
+		{PAYMENT_STATE_APPROVED, PAYMENT_STATE_PROCESSED, true},
+		{PAYMENT_STATE_APPROVED, PAYMENT_STATE_MATCHED, true},
+		// processed payments can't be booked again or have their code changed
+		{PAYMENT_STATE_PROCESSED, PAYMENT_STATE_PROCESSED, false},
+		{PAYMENT_STATE_PROCESSED, PAYMENT_STATE_APPROVED, false},
+		{PAYMENT_STATE_PROCESSED, PAYMENT_STATE_MATCHED, false},
+		{PAYMENT_STATE_PROCESSED, PAYMENT_STATE_REFUNDED, true},
+		{PAYMENT_STATE_RECEIVED, PAYMENT_STATE_REFUNDED, true},
+		{PAYMENT_STATE_REFUNDED, PAYMENT_STATE_REFUNDED, false},
+		{PAYMENT_STATE_REFUNDED, PAYMENT_STATE_PROCESSED, false},
+	}
+
+	for _, test := range tests {
+		if allowed := CanTransition(test.from, test.to); allowed != test.allowed {
+			t.Errorf("%s -> %s: expected %v, got %v", test.from, test.to, test.allowed, allowed)
+		}
+	}
+
+	for _, state := range []string{PAYMENT_STATE_RECEIVED, PAYMENT_STATE_MATCHED, PAYMENT_STATE_APPROVED,
+		PAYMENT_STATE_PROCESSED, PAYMENT_STATE_REFUNDED} {
+		if !ValidPaymentState(state) {
+			t.Errorf("%s is not a valid state", state)
+		}
+	}
+	if ValidPaymentState("pending") {
+		t.Error("pending is a valid state")
+	}
+}
@@ -210,11 +210,11 @@ func (project *Project) BalanceStats(context *APIContext) ([]int64, error) {
 }
 
 func (project *Project) HasAccess(user *User) bool {
-	return !project.Private || user.ID == 1 || (project.UserID != nil && user.ID == *project.UserID)
+	return !project.Private || user.Can(PERMISSION_VIEW_PRIVATE, &project.ID) || (user != nil && project.UserID != nil && user.ID == *project.UserID)
 }
 
 func (project *Project) HasTransactionAccess(user *User) bool {
-	return !project.PrivateBalance || user.ID == 1 || (project.UserID != nil && user.ID == *project.UserID)
+	return !project.PrivateBalance || user.Can(PERMISSION_VIEW_PRIVATE, &project.ID) || (user != nil && project.UserID != nil && user.ID == *project.UserID)
 }
 
 // SearchProjects searches database for projects
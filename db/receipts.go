@@ -0,0 +1,428 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RECEIPT_CURRENCY is the currency donation receipts are issued in. Donations
+// in other currencies aren't receipted
+const RECEIPT_CURRENCY = "EUR"
+
+// Receipts stating a donation that got refunded or charged back later need
+// to be corrected, until the tax office has been notified
+const (
+	RECEIPT_STATE_VALID            = "valid"
+	RECEIPT_STATE_NEEDS_CORRECTION = "needs_correction"
+	RECEIPT_STATE_CORRECTED        = "corrected"
+)
+
+var (
+	// ErrNoDonations is the error returned when issuing a receipt for a year
+	// without processed donations
+	ErrNoDonations = errors.New("No donations to issue a receipt for")
+	// ErrYearNotOver is the error returned when issuing a receipt for a year
+	// that hasn't ended yet
+	ErrYearNotOver = errors.New("Receipts can only be issued once the year is over")
+	// ErrAlreadyReceipted is the error returned when issuing a receipt for a
+	// year whose donations have all been receipted already
+	ErrAlreadyReceipted = errors.New("All donations of the year have already been receipted")
+	// ErrIncompleteDonor is the error returned when issuing a receipt to a
+	// user without a full name or postal address
+	ErrIncompleteDonor = errors.New("Receipts require the donor's full name and postal address")
+	// ErrReceiptNotFlagged is the error returned when correcting a receipt
+	// that doesn't need to be corrected
+	ErrReceiptNotFlagged = errors.New("Receipt doesn't need to be corrected")
+)
+
+// DonationReceipt represents the db schema of an annual donation receipt.
+// Content holds everything the document got rendered from, so a receipt can
+// be reproduced exactly
+type DonationReceipt struct {
+	ID       int64
+	Number   string
+	Year     int
+	UserID   int64
+	Amount   int64
+	Currency string
+	IssuedAt time.Time
+	State    string
+	Content  ReceiptContent
+	Document []byte
+}
+
+// ReceiptContent is what a donation receipt states
+type ReceiptContent struct {
+	Number    string            `json:"number"`
+	Year      int               `json:"year"`
+	IssuedAt  time.Time         `json:"issued_at"`
+	Donor     ReceiptDonor      `json:"donor"`
+	Donations []ReceiptDonation `json:"donations"`
+	Amount    int64             `json:"amount"`
+	Currency  string            `json:"currency"`
+	Issuer    ReceiptIssuer     `json:"issuer"`
+
+	// Supplements lists the numbers of the donor's earlier receipts for the
+	// year, which don't contain the donations on this one
+	Supplements []string `json:"supplements,omitempty"`
+}
+
+// ReceiptDonor is the donor a receipt is issued to
+type ReceiptDonor struct {
+	Name    string   `json:"name"`
+	Address []string `json:"address"`
+	ZIP     string   `json:"zip"`
+	City    string   `json:"city"`
+	Country string   `json:"country"`
+}
+
+// ReceiptDonation is a single donation on a receipt
+type ReceiptDonation struct {
+	PaymentID int64     `json:"payment_id"`
+	Date      time.Time `json:"date"`
+	Amount    int64     `json:"amount"`
+}
+
+// ReceiptIssuer is the organisation issuing a receipt
+type ReceiptIssuer struct {
+	Name      string   `json:"name"`
+	Address   []string `json:"address"`
+	TaxOffice string   `json:"tax_office"`
+	Exemption string   `json:"exemption"`
+	Purpose   string   `json:"purpose"`
+	Place     string   `json:"place"`
+	Signatory string   `json:"signatory"`
+}
+
+// IssueDonationReceipt issues a user's receipt for all their processed
+// donations in a year that haven't been receipted yet, numbered
+// consecutively within the year. Donations processed after a receipt got
+// issued are confirmed on a supplementary receipt with a new number. render
+// fills in the receipt's issuer and creates its document
+func (context *APIContext) IssueDonationReceipt(userID int64, year int, render func(*DonationReceipt) error) (DonationReceipt, error) {
+	receipt := DonationReceipt{}
+	if year >= time.Now().UTC().Year() {
+		return receipt, ErrYearNotOver
+	}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		// receipt numbers have to be gapless
+		_, err := tx.Exec("LOCK TABLE donation_receipts IN EXCLUSIVE MODE")
+		if err != nil {
+			return err
+		}
+
+		user, err := context.LoadUserByID(userID)
+		if err != nil {
+			return err
+		}
+
+		donor, err := newReceiptDonor(user)
+		if err != nil {
+			return err
+		}
+
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		rows, err := tx.Query("SELECT id, created_at, amount FROM payments "+
+			"WHERE user_id = $1 AND amount > 0 AND state = 'processed' AND currency = $2 AND created_at >= $3 AND created_at < $4 "+
+			"AND id NOT IN (SELECT payment_id FROM donation_receipt_payments) "+
+			"ORDER BY created_at ASC, id ASC", userID, RECEIPT_CURRENCY, from, from.AddDate(1, 0, 0))
+		if err != nil {
+			return err
+		}
+
+		var donations []ReceiptDonation
+		for rows.Next() {
+			d := ReceiptDonation{}
+			if err = rows.Scan(&d.PaymentID, &d.Date, &d.Amount); err != nil {
+				rows.Close()
+				return err
+			}
+			donations = append(donations, d)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		issued, err := loadDonationReceiptNumbers(tx, userID, year)
+		if err != nil {
+			return err
+		}
+		c, err := newReceiptContent(year, donor, donations, issued)
+		if err != nil {
+			return err
+		}
+
+		var seq int
+		err = tx.QueryRow("SELECT COALESCE(MAX(seq), 0) + 1 FROM donation_receipts WHERE year = $1", year).Scan(&seq)
+		if err != nil {
+			return err
+		}
+		c.Number = fmt.Sprintf("%d-%05d", year, seq)
+		c.IssuedAt = time.Now().UTC()
+
+		receipt = DonationReceipt{
+			Number:   c.Number,
+			Year:     year,
+			UserID:   userID,
+			Amount:   c.Amount,
+			Currency: c.Currency,
+			IssuedAt: c.IssuedAt,
+			State:    RECEIPT_STATE_VALID,
+			Content:  c,
+		}
+		if err = render(&receipt); err != nil {
+			return err
+		}
+
+		content, err := json.Marshal(receipt.Content)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow("INSERT INTO donation_receipts (number, year, seq, user_id, amount, currency, issued_at, state, content, document) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id",
+			receipt.Number, receipt.Year, seq, receipt.UserID, receipt.Amount, receipt.Currency, receipt.IssuedAt, receipt.State,
+			string(content), receipt.Document).Scan(&receipt.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range c.Donations {
+			_, err = tx.Exec("INSERT INTO donation_receipt_payments (receipt_id, payment_id) VALUES ($1, $2)", receipt.ID, d.PaymentID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return receipt, err
+}
+
+// newReceiptDonor returns the donor a receipt for user gets issued to. Only
+// the name users set on their account identifies them, payments may carry
+// the name of anyone holding the account they were sent from
+func newReceiptDonor(user User) (ReceiptDonor, error) {
+	donor := ReceiptDonor{
+		Name:    strings.TrimSpace(user.Name),
+		Address: user.Address,
+		ZIP:     strings.TrimSpace(user.ZIP),
+		City:    strings.TrimSpace(user.City),
+		Country: user.Country,
+	}
+
+	var address bool
+	for _, l := range donor.Address {
+		if len(strings.TrimSpace(l)) > 0 {
+			address = true
+		}
+	}
+	if len(donor.Name) == 0 || !address || len(donor.ZIP) == 0 || len(donor.City) == 0 {
+		return donor, ErrIncompleteDonor
+	}
+	return donor, nil
+}
+
+// newReceiptContent sums up a donor's unreceipted donations of a year. issued
+// are the numbers of the receipts the donor got for the year before, which
+// the new receipt supplements
+func newReceiptContent(year int, donor ReceiptDonor, donations []ReceiptDonation, issued []string) (ReceiptContent, error) {
+	c := ReceiptContent{
+		Year:        year,
+		Currency:    RECEIPT_CURRENCY,
+		Donor:       donor,
+		Donations:   donations,
+		Supplements: issued,
+	}
+	if len(donations) == 0 {
+		if len(issued) > 0 {
+			return c, ErrAlreadyReceipted
+		}
+		return c, ErrNoDonations
+	}
+
+	for _, d := range donations {
+		c.Amount += d.Amount
+	}
+	return c, nil
+}
+
+// loadDonationReceiptNumbers loads the numbers of all receipts issued to a
+// user for a year, in the order they were issued
+func loadDonationReceiptNumbers(context sqlAdapter, userID int64, year int) ([]string, error) {
+	numbers := []string{}
+
+	rows, err := context.Query("SELECT number FROM donation_receipts WHERE user_id = $1 AND year = $2 ORDER BY seq ASC", userID, year)
+	if err != nil {
+		return numbers, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var number string
+		if err = rows.Scan(&number); err != nil {
+			return numbers, err
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, rows.Err()
+}
+
+// LoadReceiptDonors loads the IDs of all users with processed donations in a
+// year that haven't been receipted yet
+func (context *APIContext) LoadReceiptDonors(year int) ([]int64, error) {
+	ids := []int64{}
+
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows, err := context.Query("SELECT DISTINCT user_id FROM payments "+
+		"WHERE user_id IS NOT NULL AND amount > 0 AND state = 'processed' AND currency = $1 AND created_at >= $2 AND created_at < $3 "+
+		"AND id NOT IN (SELECT payment_id FROM donation_receipt_payments) "+
+		"ORDER BY user_id ASC", RECEIPT_CURRENCY, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		return ids, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// LoadDonationReceiptByID loads a donation receipt by ID from the database
+func (context *APIContext) LoadDonationReceiptByID(id int64) (DonationReceipt, error) {
+	if id < 1 {
+		return DonationReceipt{}, ErrInvalidID
+	}
+	return loadDonationReceipt(context, "WHERE id = $1", id)
+}
+
+// LoadDonationReceipts loads all receipts issued to a user, latest year first
+func (user *User) LoadDonationReceipts(context *APIContext) ([]DonationReceipt, error) {
+	receipts := []DonationReceipt{}
+
+	rows, err := context.Query("SELECT id FROM donation_receipts WHERE user_id = $1 ORDER BY year DESC, seq DESC", user.ID)
+	if err != nil {
+		return receipts, err
+	}
+
+	var ids []int64
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return receipts, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return receipts, err
+	}
+
+	for _, id := range ids {
+		receipt, err := context.LoadDonationReceiptByID(id)
+		if err != nil {
+			return receipts, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, nil
+}
+
+// flagReceiptsOfPayment marks the receipts stating a refunded payment as
+// needing a correction and returns their numbers
+func flagReceiptsOfPayment(tx *APIContextTx, paymentID int64) ([]string, error) {
+	numbers := []string{}
+
+	rows, err := tx.Query("UPDATE donation_receipts SET state = $1 "+
+		"WHERE id IN (SELECT receipt_id FROM donation_receipt_payments WHERE payment_id = $2) "+
+		"RETURNING number", RECEIPT_STATE_NEEDS_CORRECTION, paymentID)
+	if err != nil {
+		return numbers, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var number string
+		if err = rows.Scan(&number); err != nil {
+			return numbers, err
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers, rows.Err()
+}
+
+// LoadDonationReceiptsToCorrect loads all receipts stating donations that
+// have been refunded since, oldest first
+func (context *APIContext) LoadDonationReceiptsToCorrect() ([]DonationReceipt, error) {
+	receipts := []DonationReceipt{}
+
+	rows, err := context.Query("SELECT id FROM donation_receipts WHERE state = $1 ORDER BY year ASC, seq ASC", RECEIPT_STATE_NEEDS_CORRECTION)
+	if err != nil {
+		return receipts, err
+	}
+
+	var ids []int64
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return receipts, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return receipts, err
+	}
+
+	for _, id := range ids {
+		receipt, err := context.LoadDonationReceiptByID(id)
+		if err != nil {
+			return receipts, err
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, nil
+}
+
+// MarkDonationReceiptCorrected records that the tax office has been notified
+// about a receipt needing a correction
+func (context *APIContext) MarkDonationReceiptCorrected(number string) error {
+	res, err := context.Exec("UPDATE donation_receipts SET state = $1 WHERE number = $2 AND state = $3",
+		RECEIPT_STATE_CORRECTED, number, RECEIPT_STATE_NEEDS_CORRECTION)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrReceiptNotFlagged
+	}
+	return nil
+}
+
+func loadDonationReceipt(context sqlAdapter, where string, args ...interface{}) (DonationReceipt, error) {
+	receipt := DonationReceipt{}
+
+	var content string
+	err := context.QueryRow("SELECT id, number, year, user_id, amount, currency, issued_at, state, content, document FROM donation_receipts "+where, args...).
+		Scan(&receipt.ID, &receipt.Number, &receipt.Year, &receipt.UserID, &receipt.Amount, &receipt.Currency, &receipt.IssuedAt,
+			&receipt.State, &content, &receipt.Document)
+	if err != nil {
+		return receipt, err
+	}
+
+	err = json.Unmarshal([]byte(content), &receipt.Content)
+	return receipt, err
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewReceiptContent(t *testing.T) {
+	donor := ReceiptDonor{Name: "Jane Doe", Address: []string{"Hauptstraße 1"}, ZIP: "10115", City: "Berlin"}
+	donations := []ReceiptDonation{
+		{PaymentID: 7, Date: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 2500},
+		{PaymentID: 9, Date: time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC), Amount: 1000},
+	}
+
+	if _, err := newReceiptContent(2025, donor, nil, nil); err != ErrNoDonations {
+		t.Errorf("expected %v, got %v", ErrNoDonations, err)
+	}
+
+	c, err := newReceiptContent(2025, donor, donations, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Amount != 3500 || len(c.Supplements) != 0 {
+		t.Errorf("expected 3500 without supplements, got %d %v", c.Amount, c.Supplements)
+	}
+
+	// donations processed after the first receipt get a supplementary one
+	issued := []string{"2025-00004"}
+	c, err = newReceiptContent(2025, donor, donations[1:], issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Amount != 1000 || len(c.Donations) != 1 || c.Donations[0].PaymentID != 9 {
+		t.Errorf("expected only the unreceipted donation, got %d %v", c.Amount, c.Donations)
+	}
+	if !reflect.DeepEqual(c.Supplements, issued) {
+		t.Errorf("expected supplements %v, got %v", issued, c.Supplements)
+	}
+
+	if _, err := newReceiptContent(2025, donor, nil, issued); err != ErrAlreadyReceipted {
+		t.Errorf("expected %v, got %v", ErrAlreadyReceipted, err)
+	}
+}
+
+func TestNewReceiptDonor(t *testing.T) {
+	user := User{
+		Nickname: "jd",
+		Name:     "Jane Doe",
+		Address:  StringSlice{"Hauptstraße 1"},
+		ZIP:      "10115",
+		City:     "Berlin",
+		Country:  "Germany",
+	}
+	donor, err := newReceiptDonor(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if donor.Name != user.Name {
+		t.Errorf("expected donor %q, got %q", user.Name, donor.Name)
+	}
+
+	incomplete := []func(u *User){
+		func(u *User) { u.Name = " " },
+		func(u *User) { u.Address = nil },
+		func(u *User) { u.Address = StringSlice{""} },
+		func(u *User) { u.ZIP = "" },
+		func(u *User) { u.City = "" },
+	}
+	for i, f := range incomplete {
+		u := user
+		f(&u)
+		if _, err := newReceiptDonor(u); err != ErrIncompleteDonor {
+			t.Errorf("%d: expected %v, got %v", i, ErrIncompleteDonor, err)
+		}
+	}
+}
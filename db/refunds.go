@@ -0,0 +1,167 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Payments can be reversed by refunding them or by a chargeback initiated by
+// the payment source
+const (
+	PAYMENT_REFUND_REFUND     = "refund"
+	PAYMENT_REFUND_CHARGEBACK = "chargeback"
+)
+
+var (
+	// ErrPaymentRefunded is the error returned when reversing a payment that
+	// has already been refunded or charged back
+	ErrPaymentRefunded = errors.New("Payment has already been refunded")
+	// ErrInvalidRefundKind is the error returned for unknown refund kinds
+	ErrInvalidRefundKind = errors.New("Invalid refund kind")
+)
+
+// ValidRefundKind returns true if kind is one of the PAYMENT_REFUND_* kinds
+func ValidRefundKind(kind string) bool {
+	switch kind {
+	case PAYMENT_REFUND_REFUND, PAYMENT_REFUND_CHARGEBACK:
+		return true
+	}
+	return false
+}
+
+// Refund reverses a payment. Everything the payment booked, including the
+// processing cut, gets booked back atomically. Unless override is set, this
+// fails with ErrInsufficientFunds if a budget can't cover its share anymore
+func (payment *Payment) Refund(context *APIContext, kind string, override bool) error {
+	return context.Transact(func(tx *APIContextTx) error {
+		return payment.RefundTx(tx, kind, override)
+	})
+}
+
+// RefundTx reverses a payment within an existing transaction. Payments that
+// haven't been processed yet only get marked as refunded. Donation receipts
+// stating the payment get flagged as needing a correction
+func (payment *Payment) RefundTx(tx *APIContextTx, kind string, override bool) error {
+	if !ValidRefundKind(kind) {
+		return ErrInvalidRefundKind
+	}
+
+	// the payment stays locked, so it can't be processed or refunded
+	// concurrently
+	err := payment.transition(tx, PAYMENT_STATE_REFUNDED)
+	if err != nil {
+		return err
+	}
+
+	transactions, err := loadPaymentTransactions(tx, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	// budgets get locked in a fixed order to avoid deadlocks
+	deltas := map[int64]int64{}
+	var budgets []int64
+	for _, t := range transactions {
+		if _, ok := deltas[t.BudgetID]; !ok {
+			budgets = append(budgets, t.BudgetID)
+		}
+		deltas[t.BudgetID] -= t.Amount
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i] < budgets[j] })
+
+	for _, bid := range budgets {
+		var id, balance int64
+		err = tx.QueryRow("SELECT id FROM budgets WHERE id = $1 FOR UPDATE", bid).Scan(&id)
+		if err != nil {
+			return err
+		}
+		if override || deltas[bid] >= 0 {
+			continue
+		}
+
+		err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE budget_id = $1", bid).Scan(&balance)
+		if err != nil {
+			return err
+		}
+		if balance+deltas[bid] < 0 {
+			return ErrInsufficientFunds
+		}
+	}
+
+	// every booking gets mirrored in its own currency and at its original
+	// exchange rate, so the budgets end up exactly where they were
+	now := time.Now().UTC()
+	for _, t := range transactions {
+		ct := Transaction{
+			BudgetID:       t.BudgetID,
+			FromBudgetID:   t.ToBudgetID,
+			ToBudgetID:     t.FromBudgetID,
+			Amount:         -t.Amount,
+			CreatedAt:      now,
+			Purpose:        refundPurpose(kind, t.Purpose),
+			PaymentID:      &payment.ID,
+			Currency:       t.Currency,
+			ExchangeRateID: t.ExchangeRateID,
+		}
+		if err = ct.Save(tx); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE payments SET refunded_at = $1, refund_kind = $2 WHERE id = $3", now, kind, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	receipts, err := flagReceiptsOfPayment(tx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if len(receipts) > 0 {
+		log.WithFields(log.Fields{
+			"Payment":  payment.ID,
+			"Receipts": receipts,
+		}).Warn("Refunded a receipted donation, the receipts need to be corrected")
+	}
+
+	payment.RefundedAt = &now
+	payment.RefundKind = kind
+	return nil
+}
+
+// loadPaymentTransactions loads all transactions a payment booked
+func loadPaymentTransactions(context sqlAdapter, paymentID int64) ([]Transaction, error) {
+	transactions := []Transaction{}
+
+	rows, err := context.Query("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id, currency, exchange_rate_id "+
+		"FROM transactions "+
+		"WHERE payment_id = $1 "+
+		"ORDER BY id ASC", paymentID)
+	if err != nil {
+		return transactions, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		transaction := Transaction{}
+		err = rows.Scan(&transaction.ID, &transaction.BudgetID, &transaction.FromBudgetID, &transaction.ToBudgetID, &transaction.Amount,
+			&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID, &transaction.Currency, &transaction.ExchangeRateID)
+		if err != nil {
+			return transactions, err
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+func refundPurpose(kind, purpose string) string {
+	if kind == PAYMENT_REFUND_CHARGEBACK {
+		return "Chargeback: " + purpose
+	}
+	return "Refund: " + purpose
+}
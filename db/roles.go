@@ -0,0 +1,182 @@
+package db
+
+import (
+	"errors"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// Role describes what a user may do, either globally or within a project
+type Role string
+
+// Global roles are stored with the user, project roles with the contributor
+const (
+	ROLE_NONE       Role = ""
+	ROLE_ADMIN      Role = "admin"
+	ROLE_TREASURER  Role = "treasurer"
+	ROLE_MAINTAINER Role = "maintainer"
+	ROLE_VIEWER     Role = "viewer"
+)
+
+// Permission is a single privileged operation
+type Permission int
+
+const (
+	PERMISSION_MANAGE_USERS = Permission(iota)
+	PERMISSION_MANAGE_PROJECTS
+	PERMISSION_MANAGE_CONTRIBUTORS
+	PERMISSION_EDIT_PROJECT
+	PERMISSION_MANAGE_BUDGETS
+	PERMISSION_MANAGE_CODES
+	PERMISSION_TRANSFER
+	PERMISSION_VIEW_PAYMENTS
+	PERMISSION_MANAGE_PAYMENTS
+	PERMISSION_MANAGE_RATES
+	PERMISSION_VIEW_PRIVATE
+)
+
+var (
+	// ErrPermissionDenied is returned when a user lacks a required permission
+	ErrPermissionDenied = errors.New("Insufficient permissions for this operation")
+	// ErrInvalidRole is returned for unknown roles
+	ErrInvalidRole = errors.New("Invalid role")
+
+	// globalPermissions lists what a global role grants across all projects
+	globalPermissions = map[Role][]Permission{
+		ROLE_TREASURER: {
+			PERMISSION_MANAGE_CODES,
+			PERMISSION_TRANSFER,
+			PERMISSION_VIEW_PAYMENTS,
+			PERMISSION_MANAGE_PAYMENTS,
+			PERMISSION_MANAGE_RATES,
+			PERMISSION_VIEW_PRIVATE,
+		},
+		ROLE_VIEWER: {
+			PERMISSION_VIEW_PRIVATE,
+		},
+	}
+
+	// projectPermissions lists what a project role grants within its project
+	projectPermissions = map[Role][]Permission{
+		ROLE_MAINTAINER: {
+			PERMISSION_MANAGE_CONTRIBUTORS,
+			PERMISSION_EDIT_PROJECT,
+			PERMISSION_MANAGE_BUDGETS,
+			PERMISSION_MANAGE_CODES,
+			PERMISSION_TRANSFER,
+			PERMISSION_VIEW_PRIVATE,
+		},
+		ROLE_VIEWER: {
+			PERMISSION_VIEW_PRIVATE,
+		},
+	}
+)
+
+// ValidGlobalRole returns true if role can be assigned to a user globally
+func ValidGlobalRole(role Role) bool {
+	switch role {
+	case ROLE_NONE, ROLE_ADMIN, ROLE_TREASURER, ROLE_VIEWER:
+		return true
+	}
+	return false
+}
+
+// ValidProjectRole returns true if role can be assigned to a project contributor
+func ValidProjectRole(role Role) bool {
+	_, ok := projectPermissions[role]
+	return ok
+}
+
+func hasPermission(perms []Permission, perm Permission) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin returns true if the user holds the global admin role
+func (user *User) IsAdmin() bool {
+	return user != nil && user.Role == ROLE_ADMIN
+}
+
+// Can returns true if the user holds a permission, either globally or through
+// their role in the project identified by projectID
+func (user *User) Can(perm Permission, projectID *int64) bool {
+	if user == nil || user.ID == 0 {
+		return false
+	}
+	if user.IsAdmin() || hasPermission(globalPermissions[user.Role], perm) {
+		return true
+	}
+	if projectID == nil {
+		return false
+	}
+
+	role, ok := user.ProjectRoles[*projectID]
+	return ok && hasPermission(projectPermissions[role], perm)
+}
+
+// Authorize checks that the user of a request holds a permission, either
+// globally or within the project identified by projectID. The request only
+// gets authenticated again if that hasn't happened already
+func (context *APIContext) Authorize(request *restful.Request, perm Permission, projectID *int64) (User, error) {
+	if context.Auth == nil || context.Auth.ID == 0 {
+		auth, err := context.Authentication(request)
+		if err != nil || auth == nil {
+			return User{}, ErrPermissionDenied
+		}
+		context.SetAuth(auth)
+	}
+
+	user := *context.Auth
+	if !user.Can(perm, projectID) {
+		return user, ErrPermissionDenied
+	}
+
+	return user, nil
+}
+
+// loadProjectRoles loads the roles a user holds in projects they own or contribute to
+func (context *APIContext) loadProjectRoles(user *User) error {
+	user.ProjectRoles = make(map[int64]Role)
+
+	rows, err := context.Query("SELECT project_id, role FROM contributors WHERE user_id = $1 "+
+		"UNION SELECT id, 'maintainer' FROM projects WHERE user_id = $1", user.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var projectID int64
+		var role Role
+		if err = rows.Scan(&projectID, &role); err != nil {
+			return err
+		}
+
+		// owning a project always outranks a contributor role
+		if r, ok := user.ProjectRoles[projectID]; !ok || r != ROLE_MAINTAINER {
+			user.ProjectRoles[projectID] = role
+		}
+	}
+
+	return rows.Err()
+}
+
+// UpdateRole sets a user's global role in the database
+func (user *User) UpdateRole(context *APIContext, role Role) error {
+	if !ValidGlobalRole(role) {
+		return ErrInvalidRole
+	}
+
+	_, err := context.Exec("UPDATE users SET role = $1 WHERE id = $2", role, user.ID)
+	if err != nil {
+		return err
+	}
+
+	user.Role = role
+	usersCache.Delete(user.UUID)
+	return nil
+}
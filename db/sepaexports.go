@@ -0,0 +1,204 @@
+package db
+
+import (
+	"errors"
+	"time"
+)
+
+// SepaExport represents the db schema of a SEPA credit transfer export
+type SepaExport struct {
+	ID        int64
+	MessageID string
+	CreatedAt time.Time
+	UserID    *int64
+	Document  string
+
+	PaymentIDs []int64
+}
+
+var (
+	// ErrPaymentExported is the error returned when exporting a payment that
+	// has already been exported
+	ErrPaymentExported = errors.New("Payment has already been exported")
+	// ErrPaymentNotExportable is the error returned when exporting a payment
+	// that isn't a processed outgoing payment
+	ErrPaymentNotExportable = errors.New("Only processed outgoing payments can be exported")
+	// ErrNoPayments is the error returned when there's nothing to export
+	ErrNoPayments = errors.New("No payments to export")
+)
+
+// LoadExportablePayments loads all outgoing payments that haven't been
+// exported yet and may be exported
+func (context *APIContext) LoadExportablePayments() ([]Payment, error) {
+	exportable := []Payment{}
+
+	rows, err := context.Query("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, " +
+		"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id " +
+		"FROM payments " +
+		"WHERE amount < 0 AND sepa_export_id IS NULL AND state = 'processed' " +
+		"ORDER BY created_at ASC")
+	if err != nil {
+		return exportable, err
+	}
+
+	payments := []Payment{}
+	defer rows.Close()
+	for rows.Next() {
+		payment := Payment{}
+		err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
+			&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+			&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
+		if err != nil {
+			return exportable, err
+		}
+
+		payments = append(payments, payment)
+	}
+	if err = rows.Err(); err != nil {
+		return exportable, err
+	}
+	rows.Close()
+
+	for _, p := range payments {
+		if err = context.checkExportable(context, p); err == nil {
+			exportable = append(exportable, p)
+		} else if err != ErrPaymentNotExportable && err != ErrApprovalRequired {
+			return exportable, err
+		}
+	}
+	return exportable, nil
+}
+
+// checkExportable returns nil if an outgoing payment may be exported. Only
+// processed payments get exported, so the money has been booked from its
+// budget before the bank sends it. Payments that needed to be approved also
+// need their approval request to still apply
+func (context *APIContext) checkExportable(tx sqlAdapter, payment Payment) error {
+	if payment.Amount >= 0 || payment.State != PAYMENT_STATE_PROCESSED {
+		return ErrPaymentNotExportable
+	}
+	if !context.NeedsApproval(payment.Amount) {
+		return nil
+	}
+
+	approved, err := paymentApproved(tx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrApprovalRequired
+	}
+	return nil
+}
+
+// ExportPayments marks a set of outgoing payments as exported. render turns
+// the payments into the export's message ID & document. The payments stay
+// locked until the export has been stored, so they can't be exported twice.
+// Payments that need an approval they didn't get return ErrApprovalRequired
+func (context *APIContext) ExportPayments(ids []int64, render func(export *SepaExport, payments []Payment) error) (SepaExport, error) {
+	export := SepaExport{
+		CreatedAt: time.Now().UTC(),
+	}
+	if len(ids) == 0 {
+		return export, ErrNoPayments
+	}
+	if context.Auth != nil && context.Auth.ID > 0 {
+		export.UserID = &context.Auth.ID
+	}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		rows, err := tx.Query("SELECT id, budget_id, created_at, amount, currency, code, purpose, remote_account, "+
+			"remote_name, remote_email, remote_transaction_id, remote_bank_id, remote_amount, remote_currency, source, state, refunded_at, refund_kind, sepa_export_id, user_id "+
+			"FROM payments "+
+			"WHERE id = ANY($1) "+
+			"ORDER BY id ASC FOR UPDATE", BigintSlice(ids))
+		if err != nil {
+			return err
+		}
+
+		payments := []Payment{}
+		defer rows.Close()
+		for rows.Next() {
+			payment := Payment{}
+			err = rows.Scan(&payment.ID, &payment.BudgetID, &payment.CreatedAt, &payment.Amount, &payment.Currency, &payment.Code,
+				&payment.Purpose, &payment.RemoteAccount, &payment.RemoteName, &payment.RemoteEmail, &payment.RemoteTransactionID, &payment.RemoteBankID,
+				&payment.RemoteAmount, &payment.RemoteCurrency, &payment.Source, &payment.State, &payment.RefundedAt, &payment.RefundKind, &payment.SepaExportID, &payment.UserID)
+			if err != nil {
+				return err
+			}
+
+			payments = append(payments, payment)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		if len(payments) != len(uniqueIDs(ids)) {
+			return ErrInvalidID
+		}
+		for _, p := range payments {
+			if p.SepaExportID != nil {
+				return ErrPaymentExported
+			}
+			if err = context.checkExportable(tx, p); err != nil {
+				return err
+			}
+			export.PaymentIDs = append(export.PaymentIDs, p.ID)
+		}
+
+		if err = render(&export, payments); err != nil {
+			return err
+		}
+
+		err = tx.QueryRow("INSERT INTO sepa_exports (message_id, created_at, user_id, document) VALUES ($1, $2, $3, $4) RETURNING id",
+			export.MessageID, export.CreatedAt, export.UserID, export.Document).Scan(&export.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE payments SET sepa_export_id = $1 WHERE id = ANY($2)", export.ID, BigintSlice(export.PaymentIDs))
+		return err
+	})
+
+	return export, err
+}
+
+// LoadSepaExportByID loads a SEPA credit transfer export by ID from the
+// database
+func (context *APIContext) LoadSepaExportByID(id int64) (SepaExport, error) {
+	export := SepaExport{}
+	if id < 1 {
+		return export, ErrInvalidID
+	}
+
+	err := context.QueryRow("SELECT id, message_id, created_at, user_id, document FROM sepa_exports WHERE id = $1", id).
+		Scan(&export.ID, &export.MessageID, &export.CreatedAt, &export.UserID, &export.Document)
+	if err != nil {
+		return export, err
+	}
+
+	rows, err := context.Query("SELECT id FROM payments WHERE sepa_export_id = $1 ORDER BY id ASC", id)
+	if err != nil {
+		return export, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var pid int64
+		if err = rows.Scan(&pid); err != nil {
+			return export, err
+		}
+		export.PaymentIDs = append(export.PaymentIDs, pid)
+	}
+
+	return export, rows.Err()
+}
+
+func uniqueIDs(ids []int64) map[int64]bool {
+	m := make(map[int64]bool)
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
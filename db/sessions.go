@@ -0,0 +1,211 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Session represents the db schema of a user session
+type Session struct {
+	ID         int64
+	UserID     int64
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  *time.Time
+	UserAgent  string
+	Scopes     StringSlice
+
+	// Token is only known right after a session has been created, the
+	// database merely stores its hash
+	Token string
+}
+
+// Scopes a session can be limited to
+const (
+	SCOPE_READ  = "read"
+	SCOPE_WRITE = "write"
+)
+
+// DefaultSessionLifetime is used when no session lifetime has been configured
+const DefaultSessionLifetime = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidSession is the error returned for unknown, expired or revoked sessions
+	ErrInvalidSession = errors.New("Invalid or expired session")
+	// ErrInsufficientScope is the error returned when a session's scopes don't cover a request
+	ErrInsufficientScope = errors.New("Session is not allowed to perform this request")
+	// ErrInvalidScope is the error returned for unknown scopes
+	ErrInvalidScope = errors.New("Invalid session scope")
+)
+
+// ValidScope returns true if a session can be limited to scope
+func ValidScope(scope string) bool {
+	return scope == SCOPE_READ || scope == SCOPE_WRITE
+}
+
+// HasScope returns true if the session has been granted scope
+func (session *Session) HasScope(scope string) bool {
+	for _, s := range session.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns true if the session can still be used
+func (session *Session) Active() bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now().UTC())
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sessionLifetime returns the configured session lifetime
+func (context *APIContext) sessionLifetime() time.Duration {
+	d, err := time.ParseDuration(context.Config.API.SessionLifetime)
+	if err != nil || d <= 0 {
+		return DefaultSessionLifetime
+	}
+	return d
+}
+
+// NewSession creates a new session for a user. The returned session carries
+// the token the client needs to authenticate with
+func (context *APIContext) NewSession(user *User, userAgent string, scopes []string) (Session, error) {
+	session := Session{}
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return session, ErrInvalidScope
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = []string{SCOPE_READ, SCOPE_WRITE}
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return session, err
+	}
+
+	now := time.Now().UTC()
+	session = Session{
+		UserID:     user.ID,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(context.sessionLifetime()),
+		LastUsedAt: now,
+		UserAgent:  userAgent,
+		Scopes:     scopes,
+		Token:      token,
+	}
+
+	err = context.QueryRow("INSERT INTO sessions (token_hash, user_id, created_at, expires_at, last_used_at, user_agent, scopes) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		hashToken(token), session.UserID, session.CreatedAt, session.ExpiresAt, session.LastUsedAt, session.UserAgent, session.Scopes).
+		Scan(&session.ID)
+	return session, err
+}
+
+// LoadSessionByID loads a session by ID from the database
+func (context *APIContext) LoadSessionByID(id int64) (Session, error) {
+	session := Session{}
+	if id < 1 {
+		return session, ErrInvalidID
+	}
+
+	err := context.QueryRow("SELECT id, user_id, created_at, expires_at, last_used_at, revoked_at, user_agent, scopes "+
+		"FROM sessions WHERE id = $1", id).
+		Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.LastUsedAt, &session.RevokedAt,
+			&session.UserAgent, &session.Scopes)
+	return session, err
+}
+
+// LoadSessionByToken loads an active session by its token from the database
+func (context *APIContext) LoadSessionByToken(token string) (Session, error) {
+	session := Session{}
+	if len(token) == 0 {
+		return session, ErrInvalidSession
+	}
+
+	err := context.QueryRow("SELECT id, user_id, created_at, expires_at, last_used_at, revoked_at, user_agent, scopes "+
+		"FROM sessions WHERE token_hash = $1", hashToken(token)).
+		Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.LastUsedAt, &session.RevokedAt,
+			&session.UserAgent, &session.Scopes)
+	if err != nil || !session.Active() {
+		return Session{}, ErrInvalidSession
+	}
+
+	return session, nil
+}
+
+// LoadSessions loads all active sessions of a user
+func (user *User) LoadSessions(context *APIContext) ([]Session, error) {
+	sessions := []Session{}
+
+	rows, err := context.Query("SELECT id, user_id, created_at, expires_at, last_used_at, revoked_at, user_agent, scopes "+
+		"FROM sessions "+
+		"WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 "+
+		"ORDER BY last_used_at DESC", user.ID, time.Now().UTC())
+	if err != nil {
+		return sessions, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		session := Session{}
+		err = rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.LastUsedAt, &session.RevokedAt,
+			&session.UserAgent, &session.Scopes)
+		if err != nil {
+			return sessions, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, err
+}
+
+// Touch records that a session has just been used. To keep the write load
+// down, this happens at most once a minute
+func (session *Session) Touch(context *APIContext) error {
+	now := time.Now().UTC()
+	if now.Sub(session.LastUsedAt) < time.Minute {
+		return nil
+	}
+
+	_, err := context.Exec("UPDATE sessions SET last_used_at = $1 WHERE id = $2", now, session.ID)
+	session.LastUsedAt = now
+	return err
+}
+
+// Revoke ends a session
+func (session *Session) Revoke(context *APIContext) error {
+	now := time.Now().UTC()
+	_, err := context.Exec("UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", now, session.ID)
+	if err != nil {
+		return err
+	}
+
+	session.RevokedAt = &now
+	return nil
+}
+
+// RevokeSessions ends all sessions of a user
+func (user *User) RevokeSessions(context sqlAdapter) error {
+	_, err := context.Exec("UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", time.Now().UTC(), user.ID)
+	return err
+}
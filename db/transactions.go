@@ -15,6 +15,9 @@ type Transaction struct {
 	CreatedAt    time.Time
 	Purpose      string
 	PaymentID    *int64
+
+	Currency       string
+	ExchangeRateID *int64
 }
 
 const (
@@ -23,6 +26,11 @@ const (
 	TRANSACTION_OUTGOING
 )
 
+var (
+	// ErrInsufficientFunds is the error returned when a budget can't cover a transfer
+	ErrInsufficientFunds = errors.New("This budget does not have the necessary funds")
+)
+
 // LoadTransactionByID loads a transaction by ID from the database
 func (context *APIContext) LoadTransactionByID(id int64) (Transaction, error) {
 	transaction := Transaction{}
@@ -30,11 +38,11 @@ func (context *APIContext) LoadTransactionByID(id int64) (Transaction, error) {
 		return transaction, ErrInvalidID
 	}
 
-	err := context.QueryRow("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id "+
+	err := context.QueryRow("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id, currency, exchange_rate_id "+
 		"FROM transactions "+
 		"WHERE id = $1", id).
 		Scan(&transaction.ID, &transaction.BudgetID, &transaction.FromBudgetID, &transaction.ToBudgetID, &transaction.Amount,
-			&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID)
+			&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID, &transaction.Currency, &transaction.ExchangeRateID)
 
 	return transaction, err
 }
@@ -66,7 +74,7 @@ func (project *Project) LoadTransactions(context *APIContext) ([]Transaction, er
 		for rows.Next() {
 			transaction := Transaction{}
 			err = rows.Scan(&transaction.ID, &transaction.BudgetID, &transaction.FromBudgetID, &transaction.ToBudgetID, &transaction.Amount,
-				&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID)
+				&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID, &transaction.Currency, &transaction.ExchangeRateID)
 			if err != nil {
 				return transactions, err
 			}
@@ -85,7 +93,7 @@ func (budget *Budget) LoadTransactions(context *APIContext) ([]Transaction, erro
 
 	transactions := []Transaction{}
 
-	rows, err := context.Query("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id "+
+	rows, err := context.Query("SELECT id, budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id, currency, exchange_rate_id "+
 		"FROM transactions "+
 		"WHERE budget_id = $1 "+
 		"ORDER BY created_at, id ASC", budget.ID)
@@ -98,7 +106,7 @@ func (budget *Budget) LoadTransactions(context *APIContext) ([]Transaction, erro
 	for rows.Next() {
 		transaction := Transaction{}
 		err = rows.Scan(&transaction.ID, &transaction.BudgetID, &transaction.FromBudgetID, &transaction.ToBudgetID, &transaction.Amount,
-			&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID)
+			&transaction.CreatedAt, &transaction.Purpose, &transaction.PaymentID, &transaction.Currency, &transaction.ExchangeRateID)
 		if err != nil {
 			return transactions, err
 		}
@@ -110,42 +118,129 @@ func (budget *Budget) LoadTransactions(context *APIContext) ([]Transaction, erro
 }
 
 // Save a transaction to the database
-func (transaction *Transaction) Save(context *APIContext) error {
-	err := context.QueryRow("INSERT INTO transactions (budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
-		transaction.BudgetID, transaction.FromBudgetID, transaction.ToBudgetID, transaction.Amount, transaction.CreatedAt, transaction.Purpose, transaction.PaymentID).Scan(&transaction.ID)
+func (transaction *Transaction) Save(context sqlAdapter) error {
+	if transaction.Currency == "" {
+		transaction.Currency = DefaultCurrency
+	}
+
+	err := context.QueryRow("INSERT INTO transactions (budget_id, from_budget_id, to_budget_id, amount, created_at, purpose, payment_id, currency, exchange_rate_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		transaction.BudgetID, transaction.FromBudgetID, transaction.ToBudgetID, transaction.Amount, transaction.CreatedAt, transaction.Purpose, transaction.PaymentID,
+		transaction.Currency, transaction.ExchangeRateID).Scan(&transaction.ID)
 	return err
 }
 
+// Transfer moves an amount between two budgets. Both sides of the transfer
+// get booked atomically
 func (context *APIContext) Transfer(fromBudget, toBudget int64, amount int64, purpose string, paymentID int64, ts time.Time) (Transaction, error) {
+	var t Transaction
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		t, err = tx.Transfer(fromBudget, toBudget, amount, purpose, paymentID, ts)
+		return err
+	})
+
+	return t, err
+}
+
+// TransferFunds moves an amount between two budgets, provided the source budget
+// can cover it. The source budget stays locked from the balance check until
+// the transfer has been booked, so concurrent transfers can't overdraw it.
+// Transfers from Processing.ApprovalThreshold on need to be approved first
+// and return ErrApprovalRequired
+func (context *APIContext) TransferFunds(fromBudget, toBudget int64, amount int64, purpose string, ts time.Time) (Transaction, error) {
+	var t Transaction
+	if context.NeedsApproval(amount) {
+		return t, ErrApprovalRequired
+	}
+
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		t, err = tx.transferFunds(fromBudget, toBudget, amount, purpose, ts)
+		return err
+	})
+
+	return t, err
+}
+
+// transferFunds books a transfer within the transaction, provided the locked
+// source budget can cover it
+func (hTx *APIContextTx) transferFunds(fromBudget, toBudget int64, amount int64, purpose string, ts time.Time) (Transaction, error) {
+	var id, balance int64
+	err := hTx.QueryRow("SELECT id FROM budgets WHERE id = $1 FOR UPDATE", fromBudget).Scan(&id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	err = hTx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE budget_id = $1", fromBudget).Scan(&balance)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if balance < amount {
+		return Transaction{}, ErrInsufficientFunds
+	}
+
+	return hTx.Transfer(fromBudget, toBudget, amount, purpose, 0, ts)
+}
+
+// Transfer books both sides of a transfer between two budgets within the
+// transaction. The amount is given in the source budget's currency and gets
+// converted when the destination budget uses a different currency
+func (hTx *APIContextTx) Transfer(fromBudget, toBudget int64, amount int64, purpose string, paymentID int64, ts time.Time) (Transaction, error) {
+	fromCurrency, err := budgetCurrency(hTx, fromBudget)
+	if err != nil {
+		return Transaction{}, err
+	}
+	toCurrency, err := budgetCurrency(hTx, toBudget)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	fromAmount, toAmount := amount, amount
+	var rateID *int64
+	if fromCurrency != toCurrency {
+		rate, err := loadExchangeRate(hTx, fromCurrency, toCurrency, ts)
+		if err != nil {
+			return Transaction{}, err
+		}
+		toAmount, err = rate.Convert(amount, fromCurrency)
+		if err != nil {
+			return Transaction{}, err
+		}
+		rateID = &rate.ID
+	}
+
 	if amount < 0 {
 		fromBudget, toBudget = toBudget, fromBudget
-		amount *= -1
+		fromCurrency, toCurrency = toCurrency, fromCurrency
+		fromAmount, toAmount = -toAmount, -fromAmount
 	}
 
-	// FIXME: move to transaction
 	torig := Transaction{
-		BudgetID:   fromBudget,
-		ToBudgetID: &toBudget,
-		Amount:     -amount,
-		CreatedAt:  ts, // FIXME: time.Now().UTC(),
-		Purpose:    purpose,
+		BudgetID:       fromBudget,
+		ToBudgetID:     &toBudget,
+		Amount:         -fromAmount,
+		CreatedAt:      ts, // FIXME: time.Now().UTC(),
+		Purpose:        purpose,
+		Currency:       fromCurrency,
+		ExchangeRateID: rateID,
 	}
 	if paymentID > 0 {
 		torig.PaymentID = &paymentID
 	}
-	if err := torig.Save(context); err != nil {
+	if err := torig.Save(hTx); err != nil {
 		return Transaction{}, err
 	}
 
 	t := Transaction{
-		BudgetID:     toBudget,
-		FromBudgetID: &fromBudget,
-		Amount:       amount,
-		CreatedAt:    ts, // FIXME: time.Now().UTC(),
-		Purpose:      purpose,
+		BudgetID:       toBudget,
+		FromBudgetID:   &fromBudget,
+		Amount:         toAmount,
+		CreatedAt:      ts, // FIXME: time.Now().UTC(),
+		Purpose:        purpose,
+		Currency:       toCurrency,
+		ExchangeRateID: rateID,
 	}
 	if paymentID > 0 {
 		t.PaymentID = &paymentID
 	}
-	return torig, t.Save(context)
+	return torig, t.Save(hTx)
 }
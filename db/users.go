@@ -2,8 +2,9 @@ package db
 
 import (
 	"errors"
+	"unicode/utf8"
 
-	"golang.org/x/crypto/bcrypt"
+	log "github.com/sirupsen/logrus"
 )
 
 // User represents the db schema of a user
@@ -12,6 +13,7 @@ type User struct {
 	UUID      string
 	Email     string
 	Nickname  string
+	Name      string
 	About     string
 	Address   StringSlice
 	ZIP       string
@@ -19,7 +21,28 @@ type User struct {
 	Country   string
 	Avatar    string
 	Activated bool
-	AuthToken StringSlice
+
+	Role         Role
+	ProjectRoles map[int64]Role
+
+	// Session is the session a user authenticated with
+	Session *Session
+}
+
+// MIN_PASSWORD_LENGTH is the minimum number of characters a password needs
+const MIN_PASSWORD_LENGTH = 8
+
+var (
+	// ErrPasswordTooShort is the error returned for passwords below MIN_PASSWORD_LENGTH
+	ErrPasswordTooShort = errors.New("Password is too short")
+)
+
+// ValidPassword checks whether password can be set as a user password
+func ValidPassword(password string) error {
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		return ErrPasswordTooShort
+	}
+	return nil
 }
 
 // LoadUserByUUID loads a user by UUID from the database
@@ -29,8 +52,8 @@ func (context *APIContext) LoadUserByUUID(uuid string) (User, error) {
 		return user, ErrInvalidID
 	}
 
-	err := context.QueryRow("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated FROM users WHERE uuid = $1", uuid).
-		Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated)
+	err := context.QueryRow("SELECT id, uuid, nickname, name, about, email, address, zip, city, country, activated, role FROM users WHERE uuid = $1", uuid).
+		Scan(&user.ID, &user.UUID, &user.Nickname, &user.Name, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.Role)
 	return user, err
 }
 
@@ -41,8 +64,8 @@ func (context *APIContext) LoadUserByID(id int64) (User, error) {
 		return user, ErrInvalidID
 	}
 
-	err := context.QueryRow("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated)
+	err := context.QueryRow("SELECT id, uuid, nickname, name, about, email, address, zip, city, country, activated, role FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.UUID, &user.Nickname, &user.Name, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.Role)
 	return user, err
 }
 
@@ -62,26 +85,39 @@ func (context *APIContext) GetUserByUUID(uuid string) (User, error) {
 func (context *APIContext) GetUserByNameAndPassword(name, password string) (User, error) {
 	user := User{}
 	hashedPassword := ""
-	err := context.QueryRow("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated, authtoken, password FROM users WHERE nickname = $1", name).
-		Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.AuthToken, &hashedPassword)
+	err := context.QueryRow("SELECT id, uuid, nickname, name, about, email, address, zip, city, country, activated, role, password FROM users WHERE nickname = $1", name).
+		Scan(&user.ID, &user.UUID, &user.Nickname, &user.Name, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.Role, &hashedPassword)
 	if err != nil {
 		return User{}, errors.New("Invalid username or password")
 	}
 
-	//FIXME: cryptpepper
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password+"cryptpepper"))
-	if err != nil {
+	ok, rehash := context.verifyPassword(hashedPassword, password)
+	if !ok {
 		return User{}, errors.New("Invalid username or password")
 	}
 
+	if rehash {
+		// upgrade the hash to the current algorithm, parameters & pepper
+		hash, err := context.hashPassword(password)
+		if err == nil {
+			_, err = context.Exec("UPDATE users SET password = $1 WHERE id = $2", hash, user.ID)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"User":  user.UUID,
+				"Error": err,
+			}).Warn("Can't upgrade password hash")
+		}
+	}
+
 	return user, nil
 }
 
 // GetUserByEmail loads a user by email from the database
 func (context *APIContext) GetUserByEmail(email string) (User, error) {
 	user := User{}
-	err := context.QueryRow("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated, authtoken FROM users WHERE email = $1", email).
-		Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.AuthToken)
+	err := context.QueryRow("SELECT id, uuid, nickname, name, about, email, address, zip, city, country, activated, role FROM users WHERE email = $1", email).
+		Scan(&user.ID, &user.UUID, &user.Nickname, &user.Name, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.Role)
 	if err != nil {
 		return User{}, errors.New("Invalid email address")
 	}
@@ -89,20 +125,33 @@ func (context *APIContext) GetUserByEmail(email string) (User, error) {
 	return user, nil
 }
 
-// GetUserByAccessToken loads a user by accesstoken from the database
+// GetUserByAccessToken loads the user of an active session from the database
 func (context *APIContext) GetUserByAccessToken(token string) (interface{}, error) {
-	user := User{}
-	err := context.QueryRow("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated, authtoken FROM users WHERE $1 = ANY(authtoken)", token).
-		Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.AuthToken)
+	session, err := context.LoadSessionByToken(token)
+	if err != nil {
+		return User{}, err
+	}
 
-	return user, err
+	user, err := context.LoadUserByID(session.UserID)
+	if err != nil {
+		return User{}, err
+	}
+	if err = context.loadProjectRoles(&user); err != nil {
+		return User{}, err
+	}
+	if err = session.Touch(context); err != nil {
+		return User{}, err
+	}
+
+	user.Session = &session
+	return user, nil
 }
 
 // LoadAllUsers loads all users from the database
 func (context *APIContext) LoadAllUsers() ([]User, error) {
 	users := []User{}
 
-	rows, err := context.Query("SELECT id, uuid, nickname, about, email, address, zip, city, country, activated FROM users")
+	rows, err := context.Query("SELECT id, uuid, nickname, name, about, email, address, zip, city, country, activated, role FROM users")
 	if err != nil {
 		return users, err
 	}
@@ -110,7 +159,7 @@ func (context *APIContext) LoadAllUsers() ([]User, error) {
 	defer rows.Close()
 	for rows.Next() {
 		user := User{}
-		err = rows.Scan(&user.ID, &user.UUID, &user.Nickname, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated)
+		err = rows.Scan(&user.ID, &user.UUID, &user.Nickname, &user.Name, &user.About, &user.Email, &user.Address, &user.ZIP, &user.City, &user.Country, &user.Activated, &user.Role)
 		if err != nil {
 			return users, err
 		}
@@ -123,8 +172,8 @@ func (context *APIContext) LoadAllUsers() ([]User, error) {
 
 // Update a user in the database
 func (user *User) Update(context *APIContext) error {
-	_, err := context.Exec("UPDATE users SET about = $1, email = $2, address = $3, zip = $4, city = $5, country = $6, authtoken = $7 WHERE id = $8",
-		user.About, user.Email, user.Address, user.ZIP, user.City, user.Country, user.AuthToken, user.ID)
+	_, err := context.Exec("UPDATE users SET name = $1, about = $2, email = $3, address = $4, zip = $5, city = $6, country = $7 WHERE id = $8",
+		user.Name, user.About, user.Email, user.Address, user.ZIP, user.City, user.Country, user.ID)
 	if err != nil {
 		return err
 	}
@@ -135,14 +184,20 @@ func (user *User) Update(context *APIContext) error {
 
 // UpdatePassword sets a new user password in the database
 func (user *User) UpdatePassword(context *APIContext, password string) error {
-	//FIXME: cryptpepper
-	hash, err := bcrypt.GenerateFromPassword([]byte(password+"cryptpepper"), bcrypt.DefaultCost)
+	err := context.setPassword(context, user.ID, password)
+	usersCache.Delete(user.UUID)
+	return err
+}
+
+// setPassword stores a new password hash and activates the account, since
+// setting a password requires a valid login or token
+func (context *APIContext) setPassword(db sqlAdapter, userID int64, password string) error {
+	hash, err := context.hashPassword(password)
 	if err != nil {
 		return err
 	}
 
-	_, err = context.Exec("UPDATE users SET password = $1, activated = true WHERE id = $2", string(hash), user.ID)
-	usersCache.Delete(user.UUID)
+	_, err = db.Exec("UPDATE users SET password = $1, activated = true WHERE id = $2", hash, userID)
 	return err
 }
 
@@ -154,9 +209,8 @@ func (user *User) Save(context *APIContext) error {
 	}
 
 	user.UUID = uuid
-	user.AuthToken = StringSlice{uuid}
-	err = context.QueryRow("INSERT INTO users (uuid, nickname, password, about, address, zip, city, country, email, authtoken) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id",
-		user.UUID, user.Nickname, uuid, user.About, user.Address, user.ZIP, user.City, user.Country, user.Email, user.AuthToken).Scan(&user.ID)
+	err = context.QueryRow("INSERT INTO users (uuid, nickname, name, password, about, address, zip, city, country, email, role) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
+		user.UUID, user.Nickname, user.Name, uuid, user.About, user.Address, user.ZIP, user.City, user.Country, user.Email, user.Role).Scan(&user.ID)
 	usersCache.Delete(user.UUID)
 	return err
 }
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/mailer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// UserToken represents the db schema of a single-use activation or password
+// reset token
+type UserToken struct {
+	ID        int64
+	UserID    int64
+	Purpose   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+
+	// Token is only known right after a token has been created, the database
+	// merely stores its hash
+	Token string
+}
+
+// Purposes a user token can be redeemed for
+const (
+	TOKEN_ACTIVATION     = "activation"
+	TOKEN_PASSWORD_RESET = "password_reset"
+)
+
+// Lifetimes used when no token lifetimes have been configured
+const (
+	DefaultActivationTokenLifetime    = 7 * 24 * time.Hour
+	DefaultPasswordResetTokenLifetime = time.Hour
+)
+
+var (
+	// ErrInvalidUserToken is the error returned for unknown, expired or already used tokens
+	ErrInvalidUserToken = errors.New("Invalid or expired token")
+	// ErrAlreadyActivated is the error returned when activating an activated account
+	ErrAlreadyActivated = errors.New("Account has already been activated")
+
+	defaultActivationTemplate = config.EmailTemplate{
+		Subject: "Activate your account",
+		Text: "Hello {{.User.Nickname}},\n\n" +
+			"please activate your account and choose a password:\n\n{{.URL}}\n\n" +
+			"This link can only be used once and expires on {{.ExpiresAt.Format \"2006-01-02 15:04 MST\"}}.\n",
+	}
+	defaultPasswordResetTemplate = config.EmailTemplate{
+		Subject: "Reset your password",
+		Text: "Hello {{.User.Nickname}},\n\n" +
+			"somebody asked to reset the password of your account. If that was you, choose a new password here:\n\n{{.URL}}\n\n" +
+			"This link can only be used once and expires on {{.ExpiresAt.Format \"2006-01-02 15:04 MST\"}}.\n" +
+			"If you did not ask for this, you can safely ignore this email.\n",
+	}
+)
+
+// userTokenMail holds the values available to activation & password reset
+// email templates
+type userTokenMail struct {
+	User      *User
+	Token     string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// ValidTokenPurpose returns true if a user token can be issued for purpose
+func ValidTokenPurpose(purpose string) bool {
+	return purpose == TOKEN_ACTIVATION || purpose == TOKEN_PASSWORD_RESET
+}
+
+// tokenLifetime returns the configured lifetime of tokens issued for purpose
+func (context *APIContext) tokenLifetime(purpose string) time.Duration {
+	s := context.Config.API.PasswordResetTokenLifetime
+	def := DefaultPasswordResetTokenLifetime
+	if purpose == TOKEN_ACTIVATION {
+		s = context.Config.API.ActivationTokenLifetime
+		def = DefaultActivationTokenLifetime
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// NewUserToken issues a new token for a user. Tokens issued earlier for the
+// same purpose can't be used anymore
+func (context *APIContext) NewUserToken(user *User, purpose string) (UserToken, error) {
+	ut := UserToken{}
+	if !ValidTokenPurpose(purpose) {
+		return ut, ErrInvalidUserToken
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return ut, err
+	}
+
+	now := time.Now().UTC()
+	ut = UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(context.tokenLifetime(purpose)),
+		Token:     token,
+	}
+
+	err = context.Transact(func(tx *APIContextTx) error {
+		_, err := tx.Exec("UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL",
+			now, ut.UserID, ut.Purpose)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRow("INSERT INTO user_tokens (token_hash, user_id, purpose, created_at, expires_at) "+
+			"VALUES ($1, $2, $3, $4, $5) RETURNING id",
+			hashToken(token), ut.UserID, ut.Purpose, ut.CreatedAt, ut.ExpiresAt).
+			Scan(&ut.ID)
+	})
+	return ut, err
+}
+
+// redeemUserToken marks a token as used and returns the ID of the user it
+// has been issued for. The token row stays locked until tx ends
+func redeemUserToken(tx *APIContextTx, token, purpose string) (int64, error) {
+	ut := UserToken{}
+	if len(token) == 0 {
+		return 0, ErrInvalidUserToken
+	}
+
+	err := tx.QueryRow("SELECT id, user_id, expires_at, used_at FROM user_tokens "+
+		"WHERE token_hash = $1 AND purpose = $2 FOR UPDATE", hashToken(token), purpose).
+		Scan(&ut.ID, &ut.UserID, &ut.ExpiresAt, &ut.UsedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidUserToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	if ut.UsedAt != nil || !ut.ExpiresAt.After(now) {
+		return 0, ErrInvalidUserToken
+	}
+
+	_, err = tx.Exec("UPDATE user_tokens SET used_at = $1 WHERE id = $2", now, ut.ID)
+	return ut.UserID, err
+}
+
+// ActivateUser redeems an activation token, activates the account it has been
+// issued for and sets its password
+func (context *APIContext) ActivateUser(token, password string) (User, error) {
+	var userID int64
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		userID, err = redeemUserToken(tx, token, TOKEN_ACTIVATION)
+		if err != nil {
+			return err
+		}
+
+		return context.setPassword(tx, userID, password)
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	return context.reloadUser(userID)
+}
+
+// ResetPassword redeems a password reset token and sets a new password for
+// the account it has been issued for. All existing sessions of the account
+// get revoked
+func (context *APIContext) ResetPassword(token, password string) (User, error) {
+	var userID int64
+	err := context.Transact(func(tx *APIContextTx) error {
+		var err error
+		userID, err = redeemUserToken(tx, token, TOKEN_PASSWORD_RESET)
+		if err != nil {
+			return err
+		}
+
+		if err = context.setPassword(tx, userID, password); err != nil {
+			return err
+		}
+
+		user := User{ID: userID}
+		return user.RevokeSessions(tx)
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	return context.reloadUser(userID)
+}
+
+// reloadUser loads a user by ID and drops it from the cache
+func (context *APIContext) reloadUser(id int64) (User, error) {
+	user, err := context.LoadUserByID(id)
+	if err != nil {
+		return user, err
+	}
+
+	usersCache.Delete(user.UUID)
+	return user, nil
+}
+
+// SendActivation issues an activation token and emails it to the user
+func (context *APIContext) SendActivation(user *User) error {
+	if user.Activated {
+		return ErrAlreadyActivated
+	}
+
+	return context.sendUserToken(user, TOKEN_ACTIVATION, "activate/",
+		context.Config.EmailTemplates.Activation, defaultActivationTemplate)
+}
+
+// SendPasswordReset issues a password reset token and emails it to the user
+func (context *APIContext) SendPasswordReset(user *User) error {
+	return context.sendUserToken(user, TOKEN_PASSWORD_RESET, "reset-password/",
+		context.Config.EmailTemplates.PasswordReset, defaultPasswordResetTemplate)
+}
+
+// RequestUserToken emails a new token for purpose to the account registered
+// with email. Unknown addresses, already activated accounts & failed mails
+// are only logged, so callers can respond the same way whether or not an
+// address belongs to an account. Looking up the account & sending the mail
+// happen in the background, so the response time doesn't reveal it either
+func (context *APIContext) RequestUserToken(email, purpose string) {
+	ctx := context.NewAPIContext().(*APIContext)
+	go ctx.requestUserToken(email, purpose)
+}
+
+func (context *APIContext) requestUserToken(email, purpose string) {
+	user, err := context.GetUserByEmail(email)
+	if err != nil {
+		return
+	}
+
+	switch purpose {
+	case TOKEN_ACTIVATION:
+		if user.Activated {
+			return
+		}
+		err = context.SendActivation(&user)
+	case TOKEN_PASSWORD_RESET:
+		err = context.SendPasswordReset(&user)
+	default:
+		err = ErrInvalidUserToken
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"User":    user.UUID,
+			"Purpose": purpose,
+			"Error":   err,
+		}).Error("Can't send user token email")
+	}
+}
+
+func (context *APIContext) sendUserToken(user *User, purpose, path string, tmpl, def config.EmailTemplate) error {
+	ut, err := context.NewUserToken(user, purpose)
+	if err != nil {
+		return err
+	}
+
+	if len(tmpl.Subject) == 0 {
+		tmpl = def
+	}
+
+	mail, err := mailer.Render(tmpl, userTokenMail{
+		User:      user,
+		Token:     ut.Token,
+		URL:       context.Config.Web.BaseURL + path + ut.Token,
+		ExpiresAt: ut.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	mail.To = user.Email
+	return mailer.Send(context.Config.Connections.Email, mail)
+}
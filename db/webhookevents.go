@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WebhookEvent represents the db schema of a payment event a provider pushed
+// to us
+type WebhookEvent struct {
+	ID         int64
+	Source     string
+	EventID    string
+	Kind       string
+	PaymentID  *int64
+	ReceivedAt time.Time
+}
+
+// Kinds of webhook events
+const (
+	WEBHOOK_EVENT_COMPLETED = "completed"
+	WEBHOOK_EVENT_REFUNDED  = "refunded"
+	WEBHOOK_EVENT_DISPUTED  = "disputed"
+)
+
+// WebhookEventExists returns true if an event of a source has already been
+// handled
+func (context *APIContext) WebhookEventExists(source, eventID string) (bool, error) {
+	var id int64
+	err := context.QueryRow("SELECT id FROM webhook_events WHERE source = $1 AND event_id = $2", source, eventID).
+		Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Save a webhook event to the database. It returns false if the event has
+// already been stored
+func (event *WebhookEvent) Save(context *APIContext) (bool, error) {
+	event.ReceivedAt = time.Now().UTC()
+
+	err := context.QueryRow("INSERT INTO webhook_events (source, event_id, kind, payment_id, received_at) "+
+		"VALUES ($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (source, event_id) DO NOTHING "+
+		"RETURNING id",
+		event.Source, event.EventID, event.Kind, event.PaymentID, event.ReceivedAt).Scan(&event.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,245 @@
+package hbci
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/statements"
+)
+
+const (
+	// DefaultProductID is sent when no registered product ID has been configured
+	DefaultProductID = "sangha"
+	productVersion   = "1.0"
+
+	// one-step PIN/TAN security procedure
+	securityFunction = "999"
+	countryCode      = "280"
+)
+
+// FinTS is a FinTS 3.0 PIN/TAN client. It only supports the one-step TAN
+// procedure, which banks permit for reading account statements
+type FinTS struct {
+	URL       string
+	BankCode  string
+	UserID    string
+	Pin       string
+	Account   string
+	ProductID string
+
+	client   *http.Client
+	dialogID string
+	msgNo    int
+}
+
+// NewFinTS creates a FinTS client for the configured HBCI account
+func NewFinTS(settings config.Data) (*FinTS, error) {
+	cfg := settings.PaymentProviders.Hbci
+	if len(cfg.URL) == 0 || len(cfg.BankCode) == 0 || len(cfg.UserID) == 0 || len(cfg.Account) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	productID := cfg.ProductID
+	if len(productID) == 0 {
+		productID = DefaultProductID
+	}
+
+	return &FinTS{
+		URL:       cfg.URL,
+		BankCode:  cfg.BankCode,
+		UserID:    cfg.UserID,
+		Pin:       cfg.Pin,
+		Account:   cfg.Account,
+		ProductID: productID,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Transactions returns the booked transactions of the account between from
+// and to. It opens a dialog with the bank, pages through the statement and
+// closes the dialog again
+func (f *FinTS) Transactions(from, to time.Time) ([]statements.Entry, error) {
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	defer f.close()
+
+	entries := []statements.Entry{}
+	var touchdown string
+	for {
+		res, err := f.send(
+			"HKKAZ:3:5+" + escape(f.Account) + "::" + countryCode + ":" + escape(f.BankCode) +
+				"+N+" + from.Format("20060102") + "+" + to.Format("20060102") + "++" + escape(touchdown))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seg := range res.segments {
+			if seg.name() != "HIKAZ" {
+				continue
+			}
+			es, err := statements.ParseMT940(strings.NewReader(seg.element(1, 0)))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, es...)
+		}
+
+		if touchdown = res.touchdown; len(touchdown) == 0 {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// response is a bank's reply to a message
+type response struct {
+	segments  []segment
+	dialogID  string
+	touchdown string
+}
+
+// open initialises a dialog with the bank
+func (f *FinTS) open() error {
+	f.dialogID = "0"
+	f.msgNo = 0
+
+	res, err := f.send(
+		"HKIDN:3:2+"+countryCode+":"+escape(f.BankCode)+"+"+escape(f.UserID)+"+0+1",
+		"HKVVB:4:3+0+0+0+"+escape(f.ProductID)+"+"+productVersion)
+	if err != nil {
+		return err
+	}
+	if len(res.dialogID) == 0 || res.dialogID == "0" {
+		return ErrInvalidMessage
+	}
+
+	f.dialogID = res.dialogID
+	return nil
+}
+
+// close ends a dialog. Failures are only logged, the bank ends abandoned
+// dialogs by itself
+func (f *FinTS) close() {
+	if _, err := f.send("HKEND:3:1+" + escape(f.dialogID)); err != nil {
+		log.WithField("Error", err).Warn("Can't end FinTS dialog")
+	}
+}
+
+// send signs & wraps segments into a message, posts it to the bank and
+// checks the bank's return codes
+func (f *FinTS) send(segments ...string) (response, error) {
+	msg := f.message(segments...)
+
+	resp, err := f.client.Post(f.URL, "application/octet-stream",
+		strings.NewReader(base64.StdEncoding.EncodeToString(msg)))
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return response{}, fmt.Errorf("FinTS server responded with %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return response{}, err
+	}
+
+	return parseResponse(body)
+}
+
+// message builds a message from the numbered segments of a business
+// transaction. Segments need to be numbered starting at 3, they get signed
+// with the PIN and wrapped in the (plain text) encryption envelope
+func (f *FinTS) message(segments ...string) []byte {
+	f.msgNo++
+
+	now := time.Now()
+	ref := strconv.Itoa(int(now.UnixNano()%1000000000) + 1)
+	user := countryCode + ":" + escape(f.BankCode) + ":" + escape(f.UserID)
+	stamp := now.Format("20060102") + ":" + now.Format("150405")
+
+	var inner bytes.Buffer
+	inner.WriteString("HNSHK:2:4+PIN:1+" + securityFunction + "+" + ref + "+1+1+1::0+1+1:" + stamp +
+		"+1:999:1+6:10:16+" + user + ":S:0:0'")
+	for _, s := range segments {
+		inner.WriteString(s + "'")
+	}
+	n := len(segments) + 3
+	inner.WriteString("HNSHA:" + strconv.Itoa(n) + ":2+" + ref + "++" + escape(f.Pin) + "'")
+
+	body := "HNVSK:998:3+PIN:1+998+1+1::0+1:" + stamp + "+2:2:13:@8@00000000:5:1+" + user + ":V:0:0+0'" +
+		"HNVSD:999:1+" + binary(inner.Bytes()) + "'" +
+		"HNHBS:" + strconv.Itoa(n+1) + ":1+" + strconv.Itoa(f.msgNo) + "'"
+
+	tail := "+300+" + escape(f.dialogID) + "+" + strconv.Itoa(f.msgNo) + "'"
+	size := len("HNHBK:1:3+") + 12 + len(tail) + len(body)
+	return []byte(fmt.Sprintf("HNHBK:1:3+%012d%s%s", size, tail, body))
+}
+
+// parseResponse decodes a bank's response, unwraps its encryption envelope
+// and evaluates the return codes
+func parseResponse(body []byte) (response, error) {
+	data, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(body), nil)))
+	if err != nil {
+		return response{}, ErrInvalidMessage
+	}
+	segs, err := parseSegments(data)
+	if err != nil {
+		return response{}, err
+	}
+
+	res := response{}
+	for _, seg := range segs {
+		switch seg.name() {
+		case "HNHBK":
+			res.dialogID = seg.element(3, 0)
+		case "HNVSD":
+			inner, err := parseSegments([]byte(seg.element(1, 0)))
+			if err != nil {
+				return response{}, err
+			}
+			res.segments = append(res.segments, inner...)
+			continue
+		}
+		res.segments = append(res.segments, seg)
+	}
+
+	for _, seg := range res.segments {
+		if seg.name() != "HIRMG" && seg.name() != "HIRMS" {
+			continue
+		}
+		for _, rc := range seg[1:] {
+			code := rc[0]
+			text := ""
+			if len(rc) > 2 {
+				text = rc[2]
+			}
+
+			switch {
+			case code == "3040" && len(rc) > 3:
+				res.touchdown = rc[3]
+			case code == "0030":
+				return res, ErrTANRequired
+			case strings.HasPrefix(code, "9"):
+				log.WithFields(log.Fields{
+					"Code": code,
+					"Text": text,
+				}).Error("FinTS request failed")
+				return res, ErrBankError
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,183 @@
+package hbci
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+)
+
+const page1 = `:20:STARTUMS
+:25:12345678/1234567890
+:28C:00001/001
+:60F:C190731EUR1000,00
+:61:1908010801CR25,00NTRFNONREF//REF0001
+:86:166?00GUTSCHRIFT?20SVWZ+Donation abcd1234?30COBADEFFXXX?31DE89370400440532013000?32Jane Doe
+:62F:C190801EUR1025,00
+-`
+
+const page2 = `:20:STARTUMS
+:25:12345678/1234567890
+:28C:00002/001
+:60F:C190801EUR1025,00
+:61:1908020802CR5,00NTRFNONREF//REF0002
+:86:166?00GUTSCHRIFT?20SVWZ+Thanks 'n stuff+more
+:62F:C190802EUR1030,00
+-`
+
+// fakeBank is a minimal FinTS server serving a paged statement
+type fakeBank struct {
+	t        *testing.T
+	pin      string
+	requests []string
+	ended    bool
+}
+
+func (b *fakeBank) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	data, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		b.t.Fatalf("request is not base64 encoded: %v", err)
+	}
+	segs, err := parseSegments(data)
+	if err != nil {
+		b.t.Fatalf("can't parse request: %v", err)
+	}
+
+	var reply []string
+	var pin string
+	for _, seg := range segs {
+		if seg.name() != "HNVSD" {
+			continue
+		}
+		inner, err := parseSegments([]byte(seg.element(1, 0)))
+		if err != nil {
+			b.t.Fatalf("can't parse signed segments: %v", err)
+		}
+		for _, s := range inner {
+			b.requests = append(b.requests, s.name())
+			switch s.name() {
+			case "HNSHA":
+				pin = s.element(3, 0)
+			case "HKIDN":
+				if s.element(1, 1) != "12345678" || s.element(2, 0) != "user" {
+					b.t.Errorf("unexpected identification: %v", s)
+				}
+			case "HKKAZ":
+				if s.element(1, 0) != "1234567890" || s.element(3, 0) != "20190801" || s.element(4, 0) != "20190802" {
+					b.t.Errorf("unexpected statement request: %v", s)
+				}
+				if s.element(6, 0) == "" {
+					reply = append(reply, "HIRMS:3:2:3+3040::Weitere Umsaetze:P2",
+						"HIKAZ:4:5:3+"+binary([]byte(page1)))
+				} else if s.element(6, 0) == "P2" {
+					reply = append(reply, "HIRMS:3:2:3+0020::Auftrag ausgefuehrt",
+						"HIKAZ:4:5:3+"+binary([]byte(page2)))
+				} else {
+					b.t.Errorf("unexpected touchdown: %v", s)
+				}
+			case "HKEND":
+				b.ended = true
+			}
+		}
+	}
+
+	code := "0010::Nachricht entgegengenommen"
+	if pin != b.pin {
+		code = "9340::PIN falsch"
+		reply = nil
+	}
+
+	msg := "HNHBK:1:3+000000000000+300+DIALOG?+1+1'HIRMG:2:2+" + code + "'"
+	for _, s := range reply {
+		msg += s + "'"
+	}
+	msg += "HNHBS:9:1+1'"
+	w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(msg))))
+}
+
+func newTestClient(t *testing.T, url, pin string) *FinTS {
+	settings := config.Data{}
+	settings.PaymentProviders.Hbci.URL = url
+	settings.PaymentProviders.Hbci.BankCode = "12345678"
+	settings.PaymentProviders.Hbci.UserID = "user"
+	settings.PaymentProviders.Hbci.Pin = pin
+	settings.PaymentProviders.Hbci.Account = "1234567890"
+
+	f, err := NewFinTS(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestTransactions(t *testing.T) {
+	bank := &fakeBank{t: t, pin: "s3cr+t"}
+	srv := httptest.NewServer(bank)
+	defer srv.Close()
+
+	f := newTestClient(t, srv.URL, "s3cr+t")
+	entries, err := f.Transactions(time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.dialogID != "DIALOG+1" {
+		t.Errorf("expected dialog ID DIALOG+1, got %s", f.dialogID)
+	}
+	if !bank.ended {
+		t.Error("dialog has not been ended")
+	}
+	if exp := "HNSHK HKIDN HKVVB HNSHA HNSHK HKKAZ HNSHA HNSHK HKKAZ HNSHA HNSHK HKEND HNSHA"; strings.Join(bank.requests, " ") != exp {
+		t.Errorf("unexpected requests: %v", bank.requests)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Amount != 2500 || e.Name != "Jane Doe" || e.Account != "DE89370400440532013000" || e.Purpose != "Donation abcd1234" || e.Reference != "REF0001" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if entries[1].Amount != 500 || entries[1].Reference != "REF0002" {
+		t.Errorf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestWrongPin(t *testing.T) {
+	bank := &fakeBank{t: t, pin: "right"}
+	srv := httptest.NewServer(bank)
+	defer srv.Close()
+
+	f := newTestClient(t, srv.URL, "wrong")
+	_, err := f.Transactions(time.Now(), time.Now())
+	if err != ErrBankError {
+		t.Errorf("expected %v, got %v", ErrBankError, err)
+	}
+}
+
+func TestParseSegments(t *testing.T) {
+	data := []byte("HIRMS:3:2:3+3040::Mehr?: Daten:P?'2+0020'\r\nHIKAZ:4:5+@5@a+b'c'")
+	segs, err := parseSegments(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(segs))
+	}
+	if segs[0].element(1, 2) != "Mehr: Daten" || segs[0].element(1, 3) != "P'2" || segs[0].element(2, 0) != "0020" {
+		t.Errorf("unexpected segment: %v", segs[0])
+	}
+	if segs[1].name() != "HIKAZ" || segs[1].element(1, 0) != "a+b'c" {
+		t.Errorf("unexpected binary segment: %v", segs[1])
+	}
+
+	if _, err = parseSegments([]byte("HIRMS:3:2+@9@abc'")); err != ErrInvalidMessage {
+		t.Errorf("expected %v for truncated binary data, got %v", ErrInvalidMessage, err)
+	}
+}
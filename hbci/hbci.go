@@ -0,0 +1,57 @@
+package hbci
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/statements"
+)
+
+// SOURCE is the payment source synced transactions get stored with
+const SOURCE = "hbci"
+
+// DefaultSyncWindow is how far back the first sync of an account reaches
+const DefaultSyncWindow = 90 * 24 * time.Hour
+
+var (
+	// ErrNotConfigured is the error returned when no account has been configured
+	ErrNotConfigured = errors.New("HBCI account has not been configured")
+	// ErrBankError is the error returned when the bank rejected a request
+	ErrBankError = errors.New("Bank rejected the request")
+	// ErrTANRequired is the error returned when the bank asks for a TAN, which
+	// unattended syncs can't provide
+	ErrTANRequired = errors.New("Bank requires a TAN")
+	// ErrInvalidMessage is the error returned for malformed bank responses
+	ErrInvalidMessage = errors.New("Invalid FinTS message")
+)
+
+// Client retrieves the bookings of a bank account
+type Client interface {
+	// Transactions returns all bookings between from and to, both inclusive
+	Transactions(from, to time.Time) ([]statements.Entry, error)
+}
+
+// Sync fetches all bookings since the latest synced payment and stores them
+// as pending payments. Bookings that have already been stored are skipped
+func Sync(context *db.APIContext, client Client) (statements.Result, error) {
+	to := time.Now().UTC()
+	from := to.Add(-DefaultSyncWindow)
+
+	latest, err := context.LatestPaymentFromSource(SOURCE)
+	if err == nil {
+		// the latest day may have seen more bookings since the last sync
+		from = latest.CreatedAt
+	} else if err != sql.ErrNoRows {
+		return statements.Result{}, err
+	}
+
+	entries, err := client.Transactions(from, to)
+	if err != nil {
+		return statements.Result{}, err
+	}
+
+	statements.AssignReferences(entries)
+	return statements.Store(context, SOURCE, entries)
+}
@@ -0,0 +1,98 @@
+package hbci
+
+import (
+	"strconv"
+	"strings"
+)
+
+// segment is a parsed FinTS segment. The first data element is the segment
+// header: name, number, version and optionally the referenced segment
+type segment [][]string
+
+func (s segment) name() string {
+	return s.element(0, 0)
+}
+
+// element returns group element j of data element i, or an empty string
+func (s segment) element(i, j int) string {
+	if i >= len(s) || j >= len(s[i]) {
+		return ""
+	}
+	return s[i][j]
+}
+
+// escape masks the FinTS syntax characters in a value
+func escape(s string) string {
+	return strings.NewReplacer("?", "??", "'", "?'", "+", "?+", ":", "?:", "@", "?@").Replace(s)
+}
+
+// binary encodes data as a binary data element
+func binary(data []byte) string {
+	return "@" + strconv.Itoa(len(data)) + "@" + string(data)
+}
+
+// parseSegments splits a FinTS message into its segments, data elements and
+// group elements. Escapes get resolved, binary data is returned as is
+func parseSegments(data []byte) ([]segment, error) {
+	segments := []segment{}
+	seg := segment{{""}}
+	start := true // at the start of a group element
+
+	cur := func() *string {
+		de := seg[len(seg)-1]
+		return &de[len(de)-1]
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '?':
+			if i+1 >= len(data) {
+				return nil, ErrInvalidMessage
+			}
+			i++
+			*cur() += string(data[i : i+1])
+			start = false
+			continue
+
+		case c == '@' && start:
+			end := i + 1
+			for end < len(data) && data[end] >= '0' && data[end] <= '9' {
+				end++
+			}
+			if end >= len(data) || data[end] != '@' {
+				return nil, ErrInvalidMessage
+			}
+			n, err := strconv.Atoi(string(data[i+1 : end]))
+			if err != nil || end+1+n > len(data) {
+				return nil, ErrInvalidMessage
+			}
+			*cur() += string(data[end+1 : end+1+n])
+			i = end + n
+			start = false
+			continue
+
+		case c == ':':
+			seg[len(seg)-1] = append(seg[len(seg)-1], "")
+		case c == '+':
+			seg = append(seg, []string{""})
+		case c == '\'':
+			segments = append(segments, seg)
+			seg = segment{{""}}
+		case (c == '\r' || c == '\n') && start && len(seg) == 1 && len(seg[0]) == 1:
+			// line breaks between segments
+			continue
+		default:
+			*cur() += string(data[i : i+1])
+			start = false
+			continue
+		}
+		start = true
+	}
+
+	if len(seg) > 1 || len(seg[0]) > 1 || len(seg[0][0]) > 0 {
+		// unterminated segment
+		return nil, ErrInvalidMessage
+	}
+	return segments, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+var (
+	ledgerCmd = &cobra.Command{
+		Use:   "ledger",
+		Short: "inspect the ledger",
+		Long:  `The ledger command is used to check the consistency of all bookings`,
+		RunE:  nil,
+	}
+	ledgerVerifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "verify the ledger",
+		Long: `The verify command checks that all transfers balance and that every payment
+has been booked with its full amount and distributed according to its code
+and the processing cuts it got booked with. It exits with a non-zero status
+when inconsistencies are found`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeLedgerVerify()
+		},
+	}
+)
+
+func init() {
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	RootCmd.AddCommand(ledgerCmd)
+}
+
+func executeLedgerVerify() error {
+	log.Println("Verifying ledger")
+
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	issues, err := ctx.VerifyLedger()
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		var tid, pid string
+		if issue.TransactionID > 0 {
+			tid = fmt.Sprintf(" transaction %d", issue.TransactionID)
+		}
+		if issue.PaymentID != nil {
+			pid = fmt.Sprintf(" payment %d", *issue.PaymentID)
+		}
+		fmt.Printf("%s:%s%s: %s\n", issue.Kind, tid, pid, issue.Description)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("Found %d ledger inconsistencies", len(issues))
+	}
+
+	log.Println("Ledger is consistent")
+	return nil
+}
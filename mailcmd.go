@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/mailer"
+)
+
+var (
+	mailCmd = &cobra.Command{
+		Use:   "mail",
+		Short: "manage outgoing emails",
+		Long:  `The mail command is used to manage outgoing emails`,
+		RunE:  nil,
+	}
+	mailRetryCmd = &cobra.Command{
+		Use:   "retry",
+		Short: "retry failed email deliveries",
+		Long: `The retry command re-sends all emails whose delivery failed, until they
+reached the maximum number of delivery attempts`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeMailRetry()
+		},
+	}
+	mailTestCmd = &cobra.Command{
+		Use:   "test [email]",
+		Short: "send a test email",
+		Long: `The test command sends an email via the configured SMTP server. Point the
+SMTP settings at a local SMTP sink to inspect outgoing emails during development`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeMailTest(args[0])
+		},
+	}
+)
+
+func init() {
+	mailCmd.AddCommand(mailRetryCmd)
+	mailCmd.AddCommand(mailTestCmd)
+	RootCmd.AddCommand(mailCmd)
+}
+
+func executeMailRetry() error {
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	deliveries, err := ctx.LoadUndeliveredEmails()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, delivery := range deliveries {
+		if err = delivery.Deliver(ctx); err != nil {
+			failed++
+			log.WithFields(log.Fields{
+				"Delivery":  delivery.ID,
+				"Recipient": delivery.Recipient,
+				"Attempts":  delivery.Attempts,
+				"Error":     err,
+			}).Error("Can't deliver email")
+		}
+	}
+
+	log.Printf("Delivered %d of %d emails", len(deliveries)-failed, len(deliveries))
+	if failed > 0 {
+		return fmt.Errorf("%d emails could not be delivered", failed)
+	}
+	return nil
+}
+
+func executeMailTest(to string) error {
+	err := mailer.Send(config.Settings.Connections.Email, mailer.Mail{
+		To:      to,
+		Subject: "sangha test email",
+		Text:    "If you can read this, sangha is able to send emails.\n",
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sent test email to %s", to)
+	return nil
+}
@@ -0,0 +1,179 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+)
+
+// Mail is a rendered email, ready to be sent
+type Mail struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+var (
+	// ErrNoRecipient is the error returned when sending a mail without recipient
+	ErrNoRecipient = errors.New("Email has no recipient")
+	// ErrNoSender is the error returned when no sender address has been configured
+	ErrNoSender = errors.New("No sender email address configured")
+)
+
+// Render executes an email template with data
+func Render(tmpl config.EmailTemplate, data interface{}) (Mail, error) {
+	m := Mail{}
+
+	var err error
+	if m.Subject, err = renderText(tmpl.Subject, data); err != nil {
+		return m, err
+	}
+	// headers can't span multiple lines
+	m.Subject = strings.Join(strings.Fields(m.Subject), " ")
+
+	if m.Text, err = renderText(tmpl.Text, data); err != nil {
+		return m, err
+	}
+
+	if len(tmpl.HTML) > 0 {
+		t, err := htmltemplate.New("html").Parse(tmpl.HTML)
+		if err != nil {
+			return m, err
+		}
+		var buf bytes.Buffer
+		if err = t.Execute(&buf, data); err != nil {
+			return m, err
+		}
+		m.HTML = buf.String()
+	}
+
+	return m, nil
+}
+
+func renderText(s string, data interface{}) (string, error) {
+	t, err := template.New("text").Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, data)
+	return buf.String(), err
+}
+
+// Send delivers a mail via the configured SMTP server. Authentication is
+// skipped when no SMTP user has been configured, which allows sending to a
+// local SMTP sink during development
+func Send(cfg config.EmailConfig, m Mail) error {
+	if len(m.To) == 0 {
+		return ErrNoRecipient
+	}
+	if len(cfg.ReplyTo) == 0 {
+		return ErrNoSender
+	}
+
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(cfg.ReplyTo)
+	if err != nil {
+		return err
+	}
+
+	msg, err := m.message(from, to)
+	if err != nil {
+		return err
+	}
+
+	port := cfg.SMTP.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(cfg.SMTP.Server, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if len(cfg.SMTP.User) > 0 {
+		auth = smtp.PlainAuth("", cfg.SMTP.User, cfg.SMTP.Password, cfg.SMTP.Server)
+	}
+
+	return smtp.SendMail(addr, auth, from.Address, []string{to.Address}, msg)
+}
+
+// message encodes a mail as a MIME message. Mails with an HTML part get sent
+// as multipart/alternative
+func (m Mail) message(from, to *mail.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Reply-To", from.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if len(m.HTML) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		err := writeQuotedPrintable(&buf, m.Text)
+		return buf.Bytes(), err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
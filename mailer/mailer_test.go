@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+)
+
+// smtpSink accepts a single SMTP session and hands over the received data
+func smtpSink(t *testing.T) (int, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				b, _ := ioutil.ReadAll(tp.DotReader())
+				data <- string(b)
+				tp.PrintfLine("250 ok")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port, data
+}
+
+func TestSend(t *testing.T) {
+	port, data := smtpSink(t)
+
+	cfg := config.EmailConfig{ReplyTo: "no-reply@domain.tld"}
+	cfg.SMTP.Server = "127.0.0.1"
+	cfg.SMTP.Port = port
+
+	m, err := Render(config.EmailTemplate{
+		Subject: "Hello {{.}}",
+		Text:    "Hi {{.}}, how are you?",
+		HTML:    "<p>Hi {{.}}</p>",
+	}, "<Alice>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HTML != "<p>Hi &lt;Alice&gt;</p>" {
+		t.Errorf("HTML part did not get escaped: %s", m.HTML)
+	}
+
+	m.To = "alice@domain.tld"
+	if err = Send(cfg, m); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-data
+	for _, exp := range []string{
+		"To: <alice@domain.tld>",
+		"Subject: Hello <Alice>",
+		"Content-Type: multipart/alternative",
+		"Hi <Alice>, how are you?",
+		"<p>Hi &lt;Alice&gt;</p>",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("expected message to contain %q, got:\n%s", exp, msg)
+		}
+	}
+}
+
+func TestSendWithoutRecipient(t *testing.T) {
+	cfg := config.EmailConfig{ReplyTo: "no-reply@domain.tld"}
+
+	if err := Send(cfg, Mail{Subject: "Hello"}); err != ErrNoRecipient {
+		t.Errorf("expected %v, got %v", ErrNoRecipient, err)
+	}
+}
@@ -46,10 +46,21 @@ func initConfig() {
 
 	log.Infoln("Starting sangha JSON API")
 
+	if err := db.CheckPasswordSettings(*config.Settings); err != nil {
+		log.Fatal(err)
+	}
+
 	db.SetupPostgres(config.Settings.Connections.PostgreSQL)
 	mq.SetupAMQP(config.Settings.Connections.AMQP)
 	db.GetDatabase()
-	db.InitDatabase()
+	if err := db.CheckSchemaVersion(); err != nil {
+		// never serve on a schema we don't know, but let the database
+		// commands run so the schema can be fixed
+		if serveCmd.CalledAs() != "" {
+			log.Fatal(err)
+		}
+		log.Warn(err)
+	}
 }
 
 func init() {
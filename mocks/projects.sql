@@ -150,124 +150,126 @@ INSERT INTO codes (code, budget_ids, ratios) VALUES ('ABCDEFGH', '{1,2}', '{66,3
 -- Users
 --
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         1,
         'mnop',
         'muesli@gmail.com',
         'muesli',
-        '',
-        '{9fec2b9fb02e2ec6e9c68351a3bb0c51}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         2,
         'nopq',
         'user2@gmail.com',
         'user2',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         3,
         'opqr',
         'user3@gmail.com',
         'user3',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         4,
         'pqrs',
         'user4@gmail.com',
         'user4',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         5,
         'qrst',
         'user5@gmail.com',
         'user5',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         6,
         'rstu',
         'user6@gmail.com',
         'user6',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         7,
         'stuv',
         'user7@gmail.com',
         'user7',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         8,
         'tuvw',
         'user8@gmail.com',
         'user8',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         9,
         'uvwx',
         'user9@gmail.com',
         'user9',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         10,
         'vwxy',
         'user10@gmail.com',
         'user10',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         11,
         'wxyz',
         'user11@gmail.com',
         'user11',
-        '',
-        '{}'
+        ''
     );
 INSERT INTO users
-    (id, uuid, email, nickname, password, authtoken)
+    (id, uuid, email, nickname, password)
     VALUES (
         12,
         'xyza',
         'user12@gmail.com',
         'user12',
-        '',
-        '{}'
+        ''
+    );
+
+--
+-- Sessions
+--
+INSERT INTO sessions
+    (token_hash, user_id, created_at, expires_at, last_used_at, scopes)
+    VALUES (
+        encode(sha256(convert_to('9fec2b9fb02e2ec6e9c68351a3bb0c51', 'UTF8')), 'hex'),
+        1,
+        now(),
+        now() + interval '30 days',
+        now(),
+        '{read,write}'
     );
 
 --
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	money "github.com/Rhymond/go-money"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/sepa"
+	"gitlab.techcultivation.org/sangha/sangha/statements"
+)
+
+var (
+	paymentsCmd = &cobra.Command{
+		Use:   "payments",
+		Short: "manage payments",
+		Long:  `The payments command is used to manage payments`,
+		RunE:  nil,
+	}
+	paymentsImportCmd = &cobra.Command{
+		Use:   "import [file]",
+		Short: "import a bank statement",
+		Long: `The import command stores the bookings of a CAMT.053 or MT940 bank statement
+as pending bank_transfer payments. Bookings that have already been imported
+are skipped`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executePaymentsImport(args[0])
+		},
+	}
+
+	paymentsExportCmd = &cobra.Command{
+		Use:   "export [payment-id...]",
+		Short: "export outgoing payments as SEPA credit transfers",
+		Long: `The export command writes processed outgoing payments into a pain.001.001.03
+credit transfer batch, which can be uploaded to online banking. Without
+payment IDs all processed outgoing payments that haven't been exported yet are
+exported. Exported payments are marked and can't be exported again`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executePaymentsExport(args)
+		},
+	}
+
+	paymentsPreviewCmd = &cobra.Command{
+		Use:   "preview [payment-id...]",
+		Short: "preview how payments would be processed",
+		Long: `The preview command prints what processing matched or approved payments would
+book, without writing anything. Payments get booked once they have been
+approved via the API`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executePaymentsPreview(args)
+		},
+	}
+
+	importFormat  string
+	exportOutput  string
+	exportExecute string
+)
+
+func init() {
+	paymentsImportCmd.Flags().StringVarP(&importFormat, "format", "f", "", "statement format (camt053 or mt940), detected if empty")
+
+	paymentsExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write the batch to (required)")
+	paymentsExportCmd.Flags().StringVarP(&exportExecute, "execution-date", "e", "", "requested execution date (YYYY-MM-DD), defaults to today")
+	paymentsExportCmd.MarkFlagRequired("output")
+
+	paymentsCmd.AddCommand(paymentsImportCmd)
+	paymentsCmd.AddCommand(paymentsExportCmd)
+	paymentsCmd.AddCommand(paymentsPreviewCmd)
+	RootCmd.AddCommand(paymentsCmd)
+}
+
+func executePaymentsImport(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	res, err := statements.Import(ctx, importFormat, data)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d of %d bookings from %s statement, skipped %d",
+		len(res.Imported), res.Entries, res.Format, res.Skipped)
+	return nil
+}
+
+func parsePaymentIDs(args []string) ([]int64, error) {
+	var ids []int64
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid payment ID: %s", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func executePaymentsExport(args []string) error {
+	ids, err := parsePaymentIDs(args)
+	if err != nil {
+		return err
+	}
+
+	var execution time.Time
+	if len(exportExecute) > 0 {
+		if execution, err = time.Parse("2006-01-02", exportExecute); err != nil {
+			return fmt.Errorf("Invalid execution date: %s", exportExecute)
+		}
+	}
+
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	if len(ids) == 0 {
+		payments, err := ctx.LoadExportablePayments()
+		if err != nil {
+			return err
+		}
+		for _, p := range payments {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	export, err := sepa.Export(ctx, ids, execution)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(exportOutput, []byte(export.Document), 0600); err != nil {
+		return fmt.Errorf("Exported payments as %s, but can't write %s: %s", export.MessageID, exportOutput, err)
+	}
+
+	log.Printf("Exported %d payments as %s to %s", len(export.PaymentIDs), export.MessageID, exportOutput)
+	return nil
+}
+
+func executePaymentsPreview(args []string) error {
+	ids, err := parsePaymentIDs(args)
+	if err != nil {
+		return err
+	}
+
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+	cutBudget := ctx.Config.Processing.DonationCutBudget
+
+	for _, id := range ids {
+		payment, err := ctx.LoadPaymentByID(id)
+		if err != nil {
+			return fmt.Errorf("Can't load payment %d: %s", id, err)
+		}
+
+		entries, err := payment.Preview(ctx, cutBudget)
+		if err != nil {
+			return fmt.Errorf("Can't preview payment %d: %s", id, err)
+		}
+
+		fmt.Printf("Payment %d: %s\n", id, money.New(payment.Amount, payment.Currency).Display())
+		for _, e := range entries {
+			budget, err := ctx.LoadBudgetByID(e.BudgetID)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  %-9s %-40s %14s\n", e.Kind, budget.Name, money.New(e.Amount, e.Currency).Display())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// BankTransfer is the provider for manually registered bank transfers. There
+// is no remote party to ask, so the request itself describes the payment
+type BankTransfer struct{}
+
+func init() {
+	Register("bank_transfer", func(settings config.Data) (PaymentProvider, error) {
+		return &BankTransfer{}, nil
+	})
+}
+
+// Manual returns true because bank transfers get registered by hand
+func (p *BankTransfer) Manual() bool {
+	return true
+}
+
+// Fetch turns the request into a payment
+func (p *BankTransfer) Fetch(request Request) (RemotePayment, error) {
+	return RemotePayment{
+		Source:    "bank_transfer",
+		Amount:    request.Amount,
+		Currency:  request.Currency,
+		Code:      request.Code,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Verify checks that the payment has an amount
+func (p *BankTransfer) Verify(payment RemotePayment) error {
+	if payment.Amount == 0 {
+		return ErrInvalidPayment
+	}
+	if len(payment.Currency) > 0 && !db.ValidCurrency(payment.Currency) {
+		return db.ErrInvalidCurrency
+	}
+	return nil
+}
+
+// Normalize turns a verified payment into a db.Payment
+func (p *BankTransfer) Normalize(payment RemotePayment) (db.Payment, error) {
+	return normalize(payment), nil
+}
+
+func normalize(payment RemotePayment) db.Payment {
+	return db.Payment{
+		CreatedAt:           payment.CreatedAt,
+		Amount:              payment.Amount,
+		Currency:            payment.Currency,
+		Code:                payment.Code,
+		Purpose:             payment.Description,
+		RemoteAccount:       payment.SourcePayerID,
+		RemoteName:          payment.Name,
+		RemoteEmail:         payment.SourcePayerEmail,
+		RemoteEmailVerified: payment.SourcePayerEmailVerified,
+		RemoteTransactionID: payment.SourceTransactionID,
+		RemoteBankID:        payment.SourceID,
+		RemoteAmount:        payment.SourceAmount,
+		RemoteCurrency:      payment.SourceCurrency,
+		Source:              payment.Source,
+	}
+}
@@ -0,0 +1,301 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// BITPAY_API_URL is the live BitPay API
+const BITPAY_API_URL = "https://bitpay.com"
+
+// InvoiceRequest holds the values of an invoice a donor asked for
+type InvoiceRequest struct {
+	Code     string
+	Amount   int64
+	Currency string
+	Email    string
+	// NotificationURL is where the provider reports status changes
+	NotificationURL string
+	// RedirectURL is where donors get sent after paying
+	RedirectURL string
+}
+
+// Invoice is an invoice created at a provider, which donors pay at URL
+type Invoice struct {
+	ID       string
+	URL      string
+	Status   string
+	Code     string
+	Amount   int64
+	Currency string
+}
+
+// InvoiceProvider is implemented by providers donors pay via invoices
+type InvoiceProvider interface {
+	CreateInvoice(request InvoiceRequest) (Invoice, error)
+}
+
+// BitPay is the provider for crypto currency payments via BitPay invoices.
+// The invoice's pos data carries the code a donation is meant for
+type BitPay struct {
+	URL   string
+	Token string
+
+	key    *bitpayKey
+	client *http.Client
+}
+
+// bitpayInvoice is an invoice as reported by the BitPay API
+type bitpayInvoice struct {
+	ID                  string          `json:"id"`
+	URL                 string          `json:"url"`
+	Status              string          `json:"status"`
+	Price               json.Number     `json:"price"`
+	Currency            string          `json:"currency"`
+	PosData             string          `json:"posData"`
+	InvoiceTime         int64           `json:"invoiceTime"`
+	TransactionCurrency string          `json:"transactionCurrency"`
+	AmountPaid          json.Number     `json:"amountPaid"`
+	BuyerProvidedEmail  string          `json:"buyerProvidedEmail"`
+	Buyer               json.RawMessage `json:"buyer"`
+}
+
+func init() {
+	Register("bitpay", func(settings config.Data) (PaymentProvider, error) {
+		return NewBitPay(settings)
+	})
+}
+
+// NewBitPay returns a BitPay provider for the configured merchant key & token
+func NewBitPay(settings config.Data) (*BitPay, error) {
+	cfg := settings.PaymentProviders.Bitpay
+	if len(cfg.Pem) == 0 || len(cfg.Token) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	key, err := parseBitpayKey(cfg.Pem)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &BitPay{
+		URL:    strings.TrimSuffix(cfg.URL, "/"),
+		Token:  cfg.Token,
+		key:    key,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if len(p.URL) == 0 {
+		p.URL = BITPAY_API_URL
+	}
+	return p, nil
+}
+
+// CreateInvoice creates an invoice for a donation to a code
+func (p *BitPay) CreateInvoice(request InvoiceRequest) (Invoice, error) {
+	price, err := formatDecimal(request.Amount, request.Currency)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	body := map[string]interface{}{
+		"token":             p.Token,
+		"price":             json.Number(price),
+		"currency":          strings.ToUpper(request.Currency),
+		"posData":           request.Code,
+		"itemDesc":          "Donation " + request.Code,
+		"notificationURL":   request.NotificationURL,
+		"redirectURL":       request.RedirectURL,
+		"fullNotifications": true,
+	}
+	if len(request.Email) > 0 {
+		body["buyer"] = map[string]interface{}{"email": request.Email, "notify": true}
+	}
+
+	bi := bitpayInvoice{}
+	if err = p.do(http.MethodPost, "/invoices", body, &bi); err != nil {
+		return Invoice{}, err
+	}
+
+	return bi.invoice()
+}
+
+// Fetch looks an invoice up at BitPay. Only confirmed & complete invoices
+// are considered payments
+func (p *BitPay) Fetch(request Request) (RemotePayment, error) {
+	if len(request.SourceID) == 0 {
+		return RemotePayment{}, ErrUnknownPayment
+	}
+
+	bi, err := p.loadInvoice(request.SourceID)
+	if err != nil {
+		return RemotePayment{}, err
+	}
+	return bi.remotePayment()
+}
+
+func (p *BitPay) loadInvoice(id string) (bitpayInvoice, error) {
+	bi := bitpayInvoice{}
+	err := p.do(http.MethodGet, "/invoices/"+url.PathEscape(id)+"?token="+url.QueryEscape(p.Token), nil, &bi)
+	return bi, err
+}
+
+// Verify checks that an invoice has been confirmed and is complete
+func (p *BitPay) Verify(payment RemotePayment) error {
+	if err := verifyRemote("bitpay", payment); err != nil {
+		return err
+	}
+	if len(payment.SourceAmount) == 0 || len(payment.SourceCurrency) == 0 {
+		return ErrInvalidPayment
+	}
+	return nil
+}
+
+// Normalize turns a verified payment into a db.Payment
+func (p *BitPay) Normalize(payment RemotePayment) (db.Payment, error) {
+	return normalize(payment), nil
+}
+
+// Webhook handles BitPay's invoice status notifications. They aren't signed,
+// so the invoice gets looked up at BitPay to learn its actual state
+func (p *BitPay) Webhook(header http.Header, body []byte) (Event, error) {
+	var ipn struct {
+		ID   string `json:"id"`
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &ipn); err != nil {
+		return Event{}, ErrInvalidEvent
+	}
+	id := firstNonEmpty(ipn.Data.ID, ipn.ID)
+	if len(id) == 0 {
+		return Event{}, ErrInvalidEvent
+	}
+
+	bi, err := p.loadInvoice(id)
+	if err == ErrUnknownPayment {
+		return Event{}, ErrInvalidEvent
+	}
+	if err != nil {
+		return Event{}, err
+	}
+
+	// invoices get confirmed and later completed, each state is an event
+	event := Event{ID: bi.ID + "/" + bi.Status}
+	rp, err := bi.remotePayment()
+	switch err {
+	case nil:
+		event.Kind = db.WEBHOOK_EVENT_COMPLETED
+		event.Payment = rp
+	case ErrUnknownPayment:
+		// new, paid, expired & invalid invoices aren't payments (yet)
+	default:
+		return Event{}, err
+	}
+	return event, nil
+}
+
+// do sends a signed request to the BitPay API and decodes the data it
+// responds with
+func (p *BitPay) do(method, path string, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	u := p.URL + path
+	r, err := http.NewRequest(method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Accept-Version", "2.0.0")
+	r.Header.Set("X-Identity", p.key.Identity())
+	r.Header.Set("X-Signature", p.key.Sign(append([]byte(u), data...)))
+
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnknownPayment
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("BitPay responded with %s", resp.Status)
+	}
+
+	res := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	return json.Unmarshal(res.Data, result)
+}
+
+func (bi bitpayInvoice) invoice() (Invoice, error) {
+	amount, err := parseDecimal(bi.Price.String(), bi.Currency)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	return Invoice{
+		ID:       bi.ID,
+		URL:      bi.URL,
+		Status:   bi.Status,
+		Code:     bi.PosData,
+		Amount:   amount,
+		Currency: strings.ToUpper(bi.Currency),
+	}, nil
+}
+
+// remotePayment turns a confirmed or complete invoice into a payment. The
+// invoice's price is the fiat amount we book, the paid crypto amount is kept
+// as source amount
+func (bi bitpayInvoice) remotePayment() (RemotePayment, error) {
+	switch bi.Status {
+	case "confirmed", "complete":
+	default:
+		return RemotePayment{}, ErrUnknownPayment
+	}
+
+	inv, err := bi.invoice()
+	if err != nil {
+		return RemotePayment{}, err
+	}
+
+	var buyer struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	json.Unmarshal(bi.Buyer, &buyer)
+
+	// buyers enter their email address themselves, it isn't verified
+	return RemotePayment{
+		Source:              "bitpay",
+		SourceID:            bi.ID,
+		SourcePayerEmail:    firstNonEmpty(bi.BuyerProvidedEmail, buyer.Email),
+		SourceTransactionID: bi.ID,
+		Name:                buyer.Name,
+		Amount:              inv.Amount,
+		Currency:            inv.Currency,
+		Code:                bi.PosData,
+		Description:         "BitPay invoice " + bi.ID,
+		CreatedAt:           time.Unix(bi.InvoiceTime/1000, 0).UTC(),
+		SourceAmount:        bi.AmountPaid.String(),
+		SourceCurrency:      strings.ToUpper(bi.TransactionCurrency),
+	}, nil
+}
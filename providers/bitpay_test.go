@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+func testBitpayPem(d int64) string {
+	der, _ := asn1.Marshal(struct {
+		Version    int
+		PrivateKey []byte
+		Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	}{1, big.NewInt(d).Bytes(), oidSecp256k1})
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+// verifyBitpaySignature checks an ECDSA signature like BitPay does
+func verifyBitpaySignature(pub *secp256k1.PublicKey, msg []byte, sigHex string) bool {
+	der, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false
+	}
+	sig, err := ecdsa.ParseDERSignature(der)
+	if err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256(msg)
+	return sig.Verify(digest[:], pub)
+}
+
+func TestBitpayKey(t *testing.T) {
+	key, err := parseBitpayKey(testBitpayPem(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"; key.Identity() != exp {
+		t.Errorf("expected identity %s, got %s", exp, key.Identity())
+	}
+
+	key, _ = parseBitpayKey(testBitpayPem(3))
+	if exp := "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"; key.Identity() != exp {
+		t.Errorf("expected identity %s, got %s", exp, key.Identity())
+	}
+
+	sig := key.Sign([]byte("message"))
+	if !verifyBitpaySignature(key.PublicKey(), []byte("message"), sig) {
+		t.Error("signature does not verify")
+	}
+	if verifyBitpaySignature(key.PublicKey(), []byte("other message"), sig) {
+		t.Error("signature verifies for another message")
+	}
+
+	if _, err = parseBitpayKey("garbage"); err != ErrInvalidKey {
+		t.Errorf("expected %v, got %v", ErrInvalidKey, err)
+	}
+	if _, err = parseBitpayKey(testBitpayPem(0)); err != ErrInvalidKey {
+		t.Errorf("expected %v for a zero key, got %v", ErrInvalidKey, err)
+	}
+}
+
+func TestBitpay(t *testing.T) {
+	key, _ := parseBitpayKey(testBitpayPem(123456789))
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Identity") != key.Identity() ||
+			!verifyBitpaySignature(key.PublicKey(), append([]byte(srv.URL+r.URL.RequestURI()), body...), r.Header.Get("X-Signature")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/invoices":
+			req := map[string]interface{}{}
+			json.Unmarshal(body, &req)
+			if req["token"] != "token" || req["price"] != 12.5 || req["posData"] != "abcd1234" {
+				t.Errorf("unexpected invoice request: %s", body)
+			}
+			w.Write([]byte(`{"data": {"id": "INV1", "url": "https://bitpay.com/i/INV1", "status": "new",
+				"price": 12.5, "currency": "EUR", "posData": "abcd1234"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/invoices/INV1":
+			if r.URL.Query().Get("token") != "token" {
+				t.Errorf("missing token: %s", r.URL)
+			}
+			w.Write([]byte(`{"data": {"id": "INV1", "status": "confirmed", "price": 12.5, "currency": "EUR",
+				"posData": "abcd1234", "invoiceTime": 1564617600000, "transactionCurrency": "BTC",
+				"amountPaid": 141200, "buyerProvidedEmail": "jane@domain.tld"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	settings := config.Data{}
+	settings.PaymentProviders.Bitpay.Pem = testBitpayPem(123456789)
+	settings.PaymentProviders.Bitpay.Token = "token"
+	settings.PaymentProviders.Bitpay.URL = srv.URL
+	p, err := NewBitPay(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoice, err := p.CreateInvoice(InvoiceRequest{Code: "abcd1234", Amount: 1250, Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.ID != "INV1" || invoice.Amount != 1250 || !strings.HasSuffix(invoice.URL, "/INV1") {
+		t.Errorf("unexpected invoice: %+v", invoice)
+	}
+
+	event, err := p.Webhook(http.Header{}, []byte(`{"id": "INV1", "status": "confirmed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "INV1/confirmed" || event.Kind != db.WEBHOOK_EVENT_COMPLETED {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if err = p.Verify(event.Payment); err != nil {
+		t.Errorf("expected valid payment, got %v", err)
+	}
+
+	payment, _ := p.Normalize(event.Payment)
+	if payment.Amount != 1250 || payment.Currency != "EUR" || payment.RemoteAmount != "141200" ||
+		payment.RemoteCurrency != "BTC" || payment.RemoteTransactionID != "INV1" || payment.Code != "abcd1234" ||
+		payment.RemoteEmail != "jane@domain.tld" || payment.RemoteEmailVerified {
+		t.Errorf("unexpected payment: %+v", payment)
+	}
+
+	if _, err = p.Webhook(http.Header{}, []byte(`{"id": "INV2"}`)); err != ErrInvalidEvent {
+		t.Errorf("expected %v for unknown invoice, got %v", ErrInvalidEvent, err)
+	}
+}
@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// BitPay signs requests with secp256k1 keys, a curve the standard library
+// doesn't implement
+
+var (
+	// ErrInvalidKey is the error returned for unusable BitPay keys
+	ErrInvalidKey = errors.New("Invalid BitPay key")
+
+	oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// bitpayKey is a secp256k1 private key
+type bitpayKey struct {
+	key *secp256k1.PrivateKey
+}
+
+// parseBitpayKey parses a PEM encoded SEC1 secp256k1 private key
+func parseBitpayKey(data string) (*bitpayKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var k struct {
+		Version    int
+		PrivateKey []byte
+		Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+		PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+	}
+	if _, err := asn1.Unmarshal(block.Bytes, &k); err != nil {
+		return nil, ErrInvalidKey
+	}
+	if len(k.Curve) > 0 && !k.Curve.Equal(oidSecp256k1) {
+		return nil, ErrInvalidKey
+	}
+
+	var d secp256k1.ModNScalar
+	if len(k.PrivateKey) > 32 || d.SetByteSlice(k.PrivateKey) || d.IsZero() {
+		return nil, ErrInvalidKey
+	}
+
+	return &bitpayKey{key: secp256k1.NewPrivateKey(&d)}, nil
+}
+
+// PublicKey returns the public key belonging to the private key
+func (k *bitpayKey) PublicKey() *secp256k1.PublicKey {
+	return k.key.PubKey()
+}
+
+// Identity returns the hex encoded compressed public key
+func (k *bitpayKey) Identity() string {
+	return hex.EncodeToString(k.PublicKey().SerializeCompressed())
+}
+
+// Sign returns the hex encoded DER ECDSA signature of msg's SHA-256 digest.
+// Nonces are derived deterministically (RFC 6979) and signatures use the low
+// s value, as required by most secp256k1 verifiers
+func (k *bitpayKey) Sign(msg []byte) string {
+	h := sha256.Sum256(msg)
+	return hex.EncodeToString(ecdsa.Sign(k.key, h[:]).Serialize())
+}
@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// Gateway is a provider backed by one of our payment gateway services, which
+// look payments up at PayPal, Stripe & co. and report them in a common format
+type Gateway struct {
+	Source string
+	URL    string
+
+	client *http.Client
+}
+
+// gatewayResponse is the format gateway services report payments in
+type gatewayResponse struct {
+	Payments []struct {
+		Name             string `json:"name"`
+		Amount           int64  `json:"amount"`
+		Currency         string `json:"currency"`
+		Code             string `json:"code"`
+		Description      string `json:"description"`
+		Source           string `json:"source"`
+		SourceID         string `json:"source_id"`
+		SourcePayerID    string `json:"source_payer_id"`
+		SourcePayerEmail string `json:"source_payer_email"`
+		// SourcePayerEmailVerified is reported by gateways whose payment
+		// source verifies email addresses, e.g. those of PayPal accounts
+		SourcePayerEmailVerified bool      `json:"source_payer_email_verified"`
+		SourceTransactionID      string    `json:"source_transaction_id"`
+		CreatedAt                time.Time `json:"created_at"`
+	} `json:"payments"`
+}
+
+// NewGateway returns a provider for the gateway service reachable at url
+func NewGateway(source, url string) (*Gateway, error) {
+	if len(url) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	return &Gateway{
+		Source: source,
+		URL:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Fetch looks a payment up at the gateway service
+func (p *Gateway) Fetch(request Request) (RemotePayment, error) {
+	if len(request.SourceID) == 0 {
+		return RemotePayment{}, ErrUnknownPayment
+	}
+
+	resp, err := p.client.Get(p.URL + "/" + url.PathEscape(request.SourceID))
+	if err != nil {
+		return RemotePayment{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return RemotePayment{}, ErrUnknownPayment
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RemotePayment{}, fmt.Errorf("Payment gateway %s responded with %s", p.Source, resp.Status)
+	}
+
+	gr := gatewayResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		return RemotePayment{}, err
+	}
+	if len(gr.Payments) == 0 {
+		return RemotePayment{}, ErrUnknownPayment
+	}
+
+	gp := gr.Payments[0]
+	return RemotePayment{
+		Source:                   gp.Source,
+		SourceID:                 gp.SourceID,
+		SourcePayerID:            gp.SourcePayerID,
+		SourcePayerEmail:         gp.SourcePayerEmail,
+		SourcePayerEmailVerified: gp.SourcePayerEmailVerified,
+		SourceTransactionID:      gp.SourceTransactionID,
+		Name:                     gp.Name,
+		Amount:                   gp.Amount,
+		Currency:                 gp.Currency,
+		Code:                     gp.Code,
+		Description:              gp.Description,
+		CreatedAt:                gp.CreatedAt,
+	}, nil
+}
+
+// Verify checks that the gateway reported a complete payment from the
+// expected source
+func (p *Gateway) Verify(payment RemotePayment) error {
+	return verifyRemote(p.Source, payment)
+}
+
+// Normalize turns a verified payment into a db.Payment
+func (p *Gateway) Normalize(payment RemotePayment) (db.Payment, error) {
+	return normalize(payment), nil
+}
+
+// verifyRemote checks that a payment reported by a remote party is complete
+// and comes from the expected source
+func verifyRemote(source string, payment RemotePayment) error {
+	if payment.Source != source || payment.Amount <= 0 ||
+		len(payment.SourceTransactionID) == 0 || payment.CreatedAt.IsZero() {
+		return ErrInvalidPayment
+	}
+	if !db.ValidCurrency(payment.Currency) {
+		return db.ErrInvalidCurrency
+	}
+	return nil
+}
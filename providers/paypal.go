@@ -0,0 +1,286 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// PAYPAL_API_URL is the live PayPal REST API
+const PAYPAL_API_URL = "https://api-m.paypal.com"
+
+// PayPal is the provider for PayPal payments. Payments can be looked up via
+// the PayPal gateway service or get pushed to our webhook
+type PayPal struct {
+	ClientID  string
+	Secret    string
+	WebhookID string
+	URL       string
+
+	gateway *Gateway
+	client  *http.Client
+}
+
+// paypalEvent is the envelope of all PayPal notifications
+type paypalEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Resource  json.RawMessage `json:"resource"`
+}
+
+type paypalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type paypalCapture struct {
+	ID                string       `json:"id"`
+	Amount            paypalAmount `json:"amount"`
+	CustomID          string       `json:"custom_id"`
+	InvoiceID         string       `json:"invoice_id"`
+	CreateTime        time.Time    `json:"create_time"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+	Links []struct {
+		Href string `json:"href"`
+		Rel  string `json:"rel"`
+	} `json:"links"`
+}
+
+type paypalDispute struct {
+	DisputeID            string       `json:"dispute_id"`
+	CreateTime           time.Time    `json:"create_time"`
+	DisputeAmount        paypalAmount `json:"dispute_amount"`
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+	} `json:"disputed_transactions"`
+}
+
+func init() {
+	Register("paypal", func(settings config.Data) (PaymentProvider, error) {
+		return NewPayPal(settings)
+	})
+}
+
+// NewPayPal returns a PayPal provider. Either the gateway service or the
+// webhook needs to be configured
+func NewPayPal(settings config.Data) (*PayPal, error) {
+	cfg := settings.PaymentProviders.PayPal
+	p := &PayPal{
+		ClientID:  cfg.ClientID,
+		Secret:    cfg.Secret,
+		WebhookID: cfg.WebhookID,
+		URL:       strings.TrimSuffix(cfg.URL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if len(p.URL) == 0 {
+		p.URL = PAYPAL_API_URL
+	}
+
+	gateway, err := NewGateway("paypal", settings.Connections.PayPal)
+	if err == nil {
+		p.gateway = gateway
+	} else if !p.webhookConfigured() {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *PayPal) webhookConfigured() bool {
+	return len(p.ClientID) > 0 && len(p.Secret) > 0 && len(p.WebhookID) > 0
+}
+
+// Fetch looks a payment up at the PayPal gateway service
+func (p *PayPal) Fetch(request Request) (RemotePayment, error) {
+	if p.gateway == nil {
+		return RemotePayment{}, ErrNotConfigured
+	}
+	return p.gateway.Fetch(request)
+}
+
+// Verify checks that a payment is complete & valid
+func (p *PayPal) Verify(payment RemotePayment) error {
+	return verifyRemote("paypal", payment)
+}
+
+// Normalize turns a verified payment into a db.Payment
+func (p *PayPal) Normalize(payment RemotePayment) (db.Payment, error) {
+	return normalize(payment), nil
+}
+
+// Webhook lets PayPal verify the signature of a notification and parses
+// completed & refunded captures as well as disputes
+func (p *PayPal) Webhook(header http.Header, body []byte) (Event, error) {
+	if !p.webhookConfigured() {
+		return Event{}, ErrNotConfigured
+	}
+	if err := p.verifySignature(header, body); err != nil {
+		return Event{}, err
+	}
+
+	pe := paypalEvent{}
+	if err := json.Unmarshal(body, &pe); err != nil || len(pe.ID) == 0 {
+		return Event{}, ErrInvalidEvent
+	}
+	event := Event{ID: pe.ID}
+
+	switch pe.EventType {
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.REFUNDED":
+		capture := paypalCapture{}
+		if err := json.Unmarshal(pe.Resource, &capture); err != nil {
+			return Event{}, ErrInvalidEvent
+		}
+		amount, err := parseDecimal(capture.Amount.Value, capture.Amount.CurrencyCode)
+		if err != nil {
+			return Event{}, err
+		}
+
+		event.Kind = db.WEBHOOK_EVENT_COMPLETED
+		event.Payment = RemotePayment{
+			Source:              "paypal",
+			SourceID:            firstNonEmpty(capture.SupplementaryData.RelatedIDs.OrderID, capture.ID),
+			SourceTransactionID: capture.ID,
+			Amount:              amount,
+			Currency:            strings.ToUpper(capture.Amount.CurrencyCode),
+			Code:                capture.CustomID,
+			Description:         capture.InvoiceID,
+			CreatedAt:           capture.CreateTime.UTC(),
+		}
+
+		if pe.EventType == "PAYMENT.CAPTURE.REFUNDED" {
+			// the resource is the refund, which links up to its capture
+			event.Kind = db.WEBHOOK_EVENT_REFUNDED
+			event.Payment.SourceID = capture.ID
+			event.Payment.SourceTransactionID = ""
+			for _, l := range capture.Links {
+				if l.Rel == "up" {
+					event.Payment.SourceTransactionID = l.Href[strings.LastIndex(l.Href, "/")+1:]
+				}
+			}
+		}
+
+	case "CUSTOMER.DISPUTE.CREATED":
+		dispute := paypalDispute{}
+		if err := json.Unmarshal(pe.Resource, &dispute); err != nil || len(dispute.DisputedTransactions) == 0 {
+			return Event{}, ErrInvalidEvent
+		}
+		amount, err := parseDecimal(dispute.DisputeAmount.Value, dispute.DisputeAmount.CurrencyCode)
+		if err != nil {
+			return Event{}, err
+		}
+
+		event.Kind = db.WEBHOOK_EVENT_DISPUTED
+		event.Payment = RemotePayment{
+			Source:              "paypal",
+			SourceID:            dispute.DisputeID,
+			SourceTransactionID: dispute.DisputedTransactions[0].SellerTransactionID,
+			Amount:              amount,
+			Currency:            strings.ToUpper(dispute.DisputeAmount.CurrencyCode),
+			CreatedAt:           dispute.CreateTime.UTC(),
+		}
+	}
+
+	return event, nil
+}
+
+// verifySignature asks PayPal to verify the transmission signature of a
+// notification against our webhook ID
+func (p *PayPal) verifySignature(header http.Header, body []byte) error {
+	if len(header.Get("Paypal-Transmission-Sig")) == 0 {
+		return ErrInvalidSignature
+	}
+	if !json.Valid(body) {
+		return ErrInvalidEvent
+	}
+
+	token, err := p.accessToken()
+	if err != nil {
+		return err
+	}
+
+	req := struct {
+		AuthAlgo         string          `json:"auth_algo"`
+		CertURL          string          `json:"cert_url"`
+		TransmissionID   string          `json:"transmission_id"`
+		TransmissionSig  string          `json:"transmission_sig"`
+		TransmissionTime string          `json:"transmission_time"`
+		WebhookID        string          `json:"webhook_id"`
+		WebhookEvent     json.RawMessage `json:"webhook_event"`
+	}{
+		AuthAlgo:         header.Get("Paypal-Auth-Algo"),
+		CertURL:          header.Get("Paypal-Cert-Url"),
+		TransmissionID:   header.Get("Paypal-Transmission-Id"),
+		TransmissionSig:  header.Get("Paypal-Transmission-Sig"),
+		TransmissionTime: header.Get("Paypal-Transmission-Time"),
+		WebhookID:        p.WebhookID,
+		WebhookEvent:     json.RawMessage(body),
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodPost, p.URL+"/v1/notifications/verify-webhook-signature", strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PayPal responded with %s", resp.Status)
+	}
+
+	res := struct {
+		VerificationStatus string `json:"verification_status"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if res.VerificationStatus != "SUCCESS" {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// accessToken requests an OAuth access token with our client credentials
+func (p *PayPal) accessToken() (string, error) {
+	r, err := http.NewRequest(http.MethodPost, p.URL+"/v1/oauth2/token",
+		strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(p.ClientID, p.Secret)
+
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("PayPal responded with %s", resp.Status)
+	}
+
+	res := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.AccessToken, nil
+}
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// Request holds the values a client submitted to register a payment
+type Request struct {
+	SourceID string
+	Amount   int64
+	Currency string
+	Code     string
+}
+
+// RemotePayment is a payment as reported by a provider, before it has been
+// normalised
+type RemotePayment struct {
+	Source              string
+	SourceID            string
+	SourcePayerID       string
+	SourcePayerEmail    string
+	SourceTransactionID string
+	Name                string
+	Amount              int64
+	Currency            string
+	Code                string
+	Description         string
+	CreatedAt           time.Time
+
+	// SourcePayerEmailVerified is true if the provider verified that the
+	// payer owns SourcePayerEmail. Only verified addresses link payments to
+	// donor accounts
+	SourcePayerEmailVerified bool
+
+	// SourceAmount is the amount in the smallest unit of SourceCurrency, if
+	// the payer paid in a currency we can't book
+	SourceAmount   string
+	SourceCurrency string
+}
+
+// PaymentProvider is the interface all payment sources need to implement
+type PaymentProvider interface {
+	// Fetch retrieves a payment from the provider
+	Fetch(request Request) (RemotePayment, error)
+	// Verify checks that a fetched payment is complete & valid
+	Verify(payment RemotePayment) error
+	// Normalize turns a verified payment into a db.Payment
+	Normalize(payment RemotePayment) (db.Payment, error)
+}
+
+// ManualProvider is implemented by providers whose payments can't be
+// verified with a remote party. Only authorized users may register them
+type ManualProvider interface {
+	Manual() bool
+}
+
+// IsManual returns true if a provider's payments can't be verified remotely
+func IsManual(provider PaymentProvider) bool {
+	m, ok := provider.(ManualProvider)
+	return ok && m.Manual()
+}
+
+// Factory creates a provider from the configuration. It returns
+// ErrNotConfigured if the provider hasn't been set up
+type Factory func(settings config.Data) (PaymentProvider, error)
+
+var (
+	// ErrUnknownProvider is the error returned for unknown payment sources
+	ErrUnknownProvider = errors.New("Unknown payment source")
+	// ErrNotConfigured is the error returned for providers that haven't been configured
+	ErrNotConfigured = errors.New("Payment source has not been configured")
+	// ErrUnknownPayment is the error returned when a provider doesn't know a payment
+	ErrUnknownPayment = errors.New("Unknown payment ID")
+	// ErrInvalidPayment is the error returned for incomplete or inconsistent payments
+	ErrInvalidPayment = errors.New("Invalid payment")
+
+	registry   = make(map[string]Factory)
+	registryMu sync.RWMutex
+)
+
+// Register makes a provider available under a source name. It is meant to be
+// called from the init function of the package implementing the provider
+func Register(source string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[source]; ok {
+		panic("providers: Register called twice for source " + source)
+	}
+	registry[source] = factory
+}
+
+// Sources returns the names of all registered providers
+func Sources() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	sources := []string{}
+	for s := range registry {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Get returns the provider for a source, configured from settings
+func Get(settings config.Data, source string) (PaymentProvider, error) {
+	registryMu.RLock()
+	factory, ok := registry[source]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return factory(settings)
+}
+
+// Payment fetches, verifies & normalises a payment from a provider
+func Payment(provider PaymentProvider, request Request) (db.Payment, error) {
+	rp, err := provider.Fetch(request)
+	if err != nil {
+		return db.Payment{}, err
+	}
+	if err = provider.Verify(rp); err != nil {
+		return db.Payment{}, err
+	}
+
+	return provider.Normalize(rp)
+}
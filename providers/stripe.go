@@ -0,0 +1,219 @@
+package providers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// STRIPE_SIGNATURE_TOLERANCE is how old a signed Stripe notification may be,
+// older ones are rejected as replays
+const STRIPE_SIGNATURE_TOLERANCE = 5 * time.Minute
+
+// Stripe is the provider for Stripe payments. Payments can be looked up via
+// the Stripe gateway service or get pushed to our webhook
+type Stripe struct {
+	WebhookSecret string
+
+	gateway *Gateway
+	now     func() time.Time
+}
+
+// stripeEvent is the envelope of all Stripe notifications
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+type stripeCharge struct {
+	ID             string            `json:"id"`
+	Amount         int64             `json:"amount"`
+	AmountRefunded int64             `json:"amount_refunded"`
+	Currency       string            `json:"currency"`
+	Created        int64             `json:"created"`
+	Description    string            `json:"description"`
+	Customer       string            `json:"customer"`
+	PaymentIntent  string            `json:"payment_intent"`
+	ReceiptEmail   string            `json:"receipt_email"`
+	Metadata       map[string]string `json:"metadata"`
+	BillingDetails struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"billing_details"`
+}
+
+type stripeDispute struct {
+	ID       string `json:"id"`
+	Charge   string `json:"charge"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Created  int64  `json:"created"`
+}
+
+func init() {
+	Register("stripe", func(settings config.Data) (PaymentProvider, error) {
+		return NewStripe(settings)
+	})
+}
+
+// NewStripe returns a Stripe provider. Either the gateway service or the
+// webhook needs to be configured
+func NewStripe(settings config.Data) (*Stripe, error) {
+	p := &Stripe{
+		WebhookSecret: settings.PaymentProviders.Stripe.WebhookSecret,
+		now:           time.Now,
+	}
+
+	gateway, err := NewGateway("stripe", settings.Connections.Stripe)
+	if err == nil {
+		p.gateway = gateway
+	} else if len(p.WebhookSecret) == 0 {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Fetch looks a payment up at the Stripe gateway service
+func (p *Stripe) Fetch(request Request) (RemotePayment, error) {
+	if p.gateway == nil {
+		return RemotePayment{}, ErrNotConfigured
+	}
+	return p.gateway.Fetch(request)
+}
+
+// Verify checks that a payment is complete & valid
+func (p *Stripe) Verify(payment RemotePayment) error {
+	return verifyRemote("stripe", payment)
+}
+
+// Normalize turns a verified payment into a db.Payment
+func (p *Stripe) Normalize(payment RemotePayment) (db.Payment, error) {
+	return normalize(payment), nil
+}
+
+// Webhook verifies the Stripe-Signature header of a notification and parses
+// succeeded, refunded & disputed charges
+func (p *Stripe) Webhook(header http.Header, body []byte) (Event, error) {
+	if len(p.WebhookSecret) == 0 {
+		return Event{}, ErrNotConfigured
+	}
+	if err := p.verifySignature(header.Get("Stripe-Signature"), body); err != nil {
+		return Event{}, err
+	}
+
+	se := stripeEvent{}
+	if err := json.Unmarshal(body, &se); err != nil || len(se.ID) == 0 {
+		return Event{}, ErrInvalidEvent
+	}
+	event := Event{ID: se.ID}
+
+	switch se.Type {
+	case "charge.succeeded", "charge.refunded":
+		charge := stripeCharge{}
+		if err := json.Unmarshal(se.Data.Object, &charge); err != nil {
+			return Event{}, ErrInvalidEvent
+		}
+
+		// payers enter their email address themselves, it isn't verified
+		event.Payment = RemotePayment{
+			Source:              "stripe",
+			SourceID:            firstNonEmpty(charge.PaymentIntent, charge.ID),
+			SourcePayerID:       charge.Customer,
+			SourcePayerEmail:    firstNonEmpty(charge.BillingDetails.Email, charge.ReceiptEmail),
+			SourceTransactionID: charge.ID,
+			Name:                charge.BillingDetails.Name,
+			Amount:              charge.Amount,
+			Currency:            strings.ToUpper(charge.Currency),
+			Code:                charge.Metadata["code"],
+			Description:         charge.Description,
+			CreatedAt:           time.Unix(charge.Created, 0).UTC(),
+		}
+		event.Kind = db.WEBHOOK_EVENT_COMPLETED
+		if se.Type == "charge.refunded" {
+			event.Kind = db.WEBHOOK_EVENT_REFUNDED
+			event.Payment.Amount = charge.AmountRefunded
+		}
+
+	case "charge.dispute.created":
+		dispute := stripeDispute{}
+		if err := json.Unmarshal(se.Data.Object, &dispute); err != nil {
+			return Event{}, ErrInvalidEvent
+		}
+
+		event.Kind = db.WEBHOOK_EVENT_DISPUTED
+		event.Payment = RemotePayment{
+			Source:              "stripe",
+			SourceID:            dispute.ID,
+			SourceTransactionID: dispute.Charge,
+			Amount:              dispute.Amount,
+			Currency:            strings.ToUpper(dispute.Currency),
+			CreatedAt:           time.Unix(dispute.Created, 0).UTC(),
+		}
+	}
+
+	return event, nil
+}
+
+// verifySignature checks a Stripe-Signature header, which carries a
+// timestamp and one or more HMAC-SHA256 signatures of "timestamp.body"
+func (p *Stripe) verifySignature(signature string, body []byte) error {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := p.now().Sub(time.Unix(ts, 0)); age > STRIPE_SIGNATURE_TOLERANCE || age < -STRIPE_SIGNATURE_TOLERANCE {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	money "github.com/Rhymond/go-money"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// Event is a payment event a provider pushed to our webhook
+type Event struct {
+	ID string
+	// Kind is one of the db.WEBHOOK_EVENT_* kinds, or empty for events we
+	// don't handle
+	Kind string
+	// Payment is the payment the event is about. Refunds & disputes only
+	// carry the source transaction ID and the affected amount
+	Payment RemotePayment
+}
+
+// WebhookProvider is implemented by providers that push payment events
+type WebhookProvider interface {
+	PaymentProvider
+	// Webhook verifies the signature of a notification and parses its event
+	Webhook(header http.Header, body []byte) (Event, error)
+}
+
+var (
+	// ErrInvalidSignature is the error returned for notifications whose
+	// signature can't be verified
+	ErrInvalidSignature = errors.New("Invalid webhook signature")
+	// ErrInvalidEvent is the error returned for malformed notifications
+	ErrInvalidEvent = errors.New("Invalid webhook event")
+)
+
+// GetWebhook returns the webhook provider for a source, configured from
+// settings
+func GetWebhook(settings config.Data, source string) (WebhookProvider, error) {
+	provider, err := Get(settings, source)
+	if err != nil {
+		return nil, err
+	}
+
+	wp, ok := provider.(WebhookProvider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return wp, nil
+}
+
+// parseDecimal converts a decimal amount like "12.50" into the currency's
+// minor units
+func parseDecimal(value, currency string) (int64, error) {
+	c := money.GetCurrency(strings.ToUpper(currency))
+	if c == nil {
+		return 0, db.ErrInvalidCurrency
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(value), ".", 2)
+	frac := ""
+	if len(parts) == 2 {
+		frac = parts[1]
+	}
+	if len(frac) > c.Fraction {
+		return 0, ErrInvalidEvent
+	}
+	frac += strings.Repeat("0", c.Fraction-len(frac))
+
+	v, err := strconv.ParseInt(parts[0]+frac, 10, 64)
+	if err != nil || v < 0 {
+		return 0, ErrInvalidEvent
+	}
+	return v, nil
+}
+
+// formatDecimal converts an amount in the currency's minor units into a
+// decimal amount like "12.50"
+func formatDecimal(amount int64, currency string) (string, error) {
+	c := money.GetCurrency(strings.ToUpper(currency))
+	if c == nil {
+		return "", db.ErrInvalidCurrency
+	}
+	if amount < 0 || c.Fraction == 0 {
+		return strconv.FormatInt(amount, 10), nil
+	}
+
+	s := fmt.Sprintf("%0*d", c.Fraction+1, amount)
+	return s[:len(s)-c.Fraction] + "." + s[len(s)-c.Fraction:], nil
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+const stripeChargeEvent = `{
+  "id": "evt_1",
+  "type": "charge.succeeded",
+  "data": {"object": {
+    "id": "ch_1", "amount": 2500, "currency": "eur", "created": 1564617600,
+    "payment_intent": "pi_1", "metadata": {"code": "abcd1234"},
+    "billing_details": {"name": "Jane Doe", "email": "jane@domain.tld"}
+  }}
+}`
+
+func stripeSignature(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts, body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestStripeWebhook(t *testing.T) {
+	settings := config.Data{}
+	settings.PaymentProviders.Stripe.WebhookSecret = "whsec_test"
+	p, err := NewStripe(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1564617600, 0)
+	p.now = func() time.Time { return now }
+
+	h := http.Header{}
+	h.Set("Stripe-Signature", stripeSignature("whsec_test", now.Unix(), stripeChargeEvent))
+	event, err := p.Webhook(h, []byte(stripeChargeEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "evt_1" || event.Kind != db.WEBHOOK_EVENT_COMPLETED {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	rp := event.Payment
+	if rp.Amount != 2500 || rp.Currency != "EUR" || rp.Code != "abcd1234" || rp.SourceTransactionID != "ch_1" ||
+		rp.SourcePayerEmail != "jane@domain.tld" || rp.SourcePayerEmailVerified || rp.Name != "Jane Doe" {
+		t.Errorf("unexpected payment: %+v", rp)
+	}
+	if err = p.Verify(rp); err != nil {
+		t.Errorf("expected valid payment, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"wrong secret", stripeSignature("whsec_other", now.Unix(), stripeChargeEvent)},
+		{"tampered body", stripeSignature("whsec_test", now.Unix(), stripeChargeEvent+" ")},
+		{"replayed", stripeSignature("whsec_test", now.Add(-time.Hour).Unix(), stripeChargeEvent)},
+		{"missing", ""},
+	}
+	for _, test := range tests {
+		h.Set("Stripe-Signature", test.signature)
+		if _, err = p.Webhook(h, []byte(stripeChargeEvent)); err != ErrInvalidSignature {
+			t.Errorf("%s: expected %v, got %v", test.name, ErrInvalidSignature, err)
+		}
+	}
+}
+
+func TestPayPalWebhook(t *testing.T) {
+	body := `{"id": "WH-1", "event_type": "PAYMENT.CAPTURE.REFUNDED", "resource": {
+		"id": "R1", "amount": {"currency_code": "EUR", "value": "10.5"},
+		"create_time": "2019-08-01T10:00:00Z",
+		"links": [{"rel": "up", "href": "https://api.paypal.com/v2/payments/captures/C1"}]}}`
+
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/oauth2/token":
+			if u, p, ok := r.BasicAuth(); !ok || u != "client" || p != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "token"}`))
+		case "/v1/notifications/verify-webhook-signature":
+			req := struct {
+				TransmissionSig string          `json:"transmission_sig"`
+				WebhookID       string          `json:"webhook_id"`
+				WebhookEvent    json.RawMessage `json:"webhook_event"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&req)
+
+			status := "FAILURE"
+			if r.Header.Get("Authorization") == "Bearer token" && req.TransmissionSig == "valid" &&
+				req.WebhookID == "WH" && len(req.WebhookEvent) > 0 {
+				status = "SUCCESS"
+				verified = true
+			}
+			fmt.Fprintf(w, `{"verification_status": "%s"}`, status)
+		}
+	}))
+	defer srv.Close()
+
+	settings := config.Data{}
+	settings.PaymentProviders.PayPal.ClientID = "client"
+	settings.PaymentProviders.PayPal.Secret = "secret"
+	settings.PaymentProviders.PayPal.WebhookID = "WH"
+	settings.PaymentProviders.PayPal.URL = srv.URL
+	p, err := NewPayPal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := http.Header{}
+	h.Set("Paypal-Transmission-Sig", "valid")
+	event, err := p.Webhook(h, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("signature has not been verified")
+	}
+	if event.Kind != db.WEBHOOK_EVENT_REFUNDED || event.Payment.SourceTransactionID != "C1" || event.Payment.Amount != 1050 {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	h.Set("Paypal-Transmission-Sig", "forged")
+	if _, err = p.Webhook(h, []byte(body)); err != ErrInvalidSignature {
+		t.Errorf("expected %v, got %v", ErrInvalidSignature, err)
+	}
+}
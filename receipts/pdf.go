@@ -0,0 +1,160 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	PAGE_WIDTH  = 595.28
+	PAGE_HEIGHT = 841.89
+)
+
+// pdf is a minimal PDF 1.4 writer for text documents, using the standard
+// Helvetica fonts. Its output doesn't depend on the time it got created, so
+// the same content always renders to the same document
+type pdf struct {
+	pages []*bytes.Buffer
+}
+
+// addPage starts a new A4 page
+func (p *pdf) addPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+}
+
+func (p *pdf) page() *bytes.Buffer {
+	if len(p.pages) == 0 {
+		p.addPage()
+	}
+	return p.pages[len(p.pages)-1]
+}
+
+// text draws s with its baseline starting at x, y, measured from the bottom
+// left corner of the page
+func (p *pdf) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page(), "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// textRight draws s so it ends at x
+func (p *pdf) textRight(x, y, size float64, bold bool, s string) {
+	p.text(x-textWidth(s, size), y, size, bold, s)
+}
+
+// line draws a thin line between two points
+func (p *pdf) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// bytes encodes the document
+func (p *pdf) bytes() []byte {
+	p.page()
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	// pages & their contents follow the catalog, page tree & fonts
+	var kids []string
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", PAGE_WIDTH, PAGE_HEIGHT, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// escape encodes s in WinAnsiEncoding as PDF string. Characters the encoding
+// lacks are replaced by '?'
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		case r == '–':
+			b.WriteString("\\226")
+		case r == '„':
+			b.WriteString("\\204")
+		case r == '“':
+			b.WriteString("\\223")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth approximates the width of s in Helvetica. Digits & the
+// characters amounts are formatted with are exact, so amounts can be
+// aligned to the right
+func textWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '€':
+			w += 556
+		case r == '.' || r == ',' || r == ' ' || r == 'i' || r == 'l' || r == 'I':
+			w += 278
+		case r >= 'A' && r <= 'Z':
+			w += 667
+		default:
+			w += 500
+		}
+	}
+	return w * size / 1000
+}
+
+// wrap breaks s into lines of at most width points
+func wrap(s string, width, size float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = line + " " + word
+		}
+		if len(line) > 0 && textWidth(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
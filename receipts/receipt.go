@@ -0,0 +1,194 @@
+package receipts
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// ErrNotConfigured is the error returned when the organisation issuing
+// receipts hasn't been configured
+var ErrNotConfigured = errors.New("Donation receipts not configured")
+
+const (
+	margin     = 70.0
+	lineHeight = 14.0
+	fontSize   = 10.0
+)
+
+// Issue issues a donor's annual receipt for all their processed donations
+// in a year that haven't been receipted yet and renders it as PDF
+func Issue(context *db.APIContext, userID int64, year int) (db.DonationReceipt, error) {
+	cfg := context.Config.Receipts
+	if len(cfg.Issuer) == 0 || len(cfg.TaxOffice) == 0 || len(cfg.Purpose) == 0 {
+		return db.DonationReceipt{}, ErrNotConfigured
+	}
+
+	return context.IssueDonationReceipt(userID, year, func(receipt *db.DonationReceipt) error {
+		receipt.Content.Issuer = db.ReceiptIssuer{
+			Name:      cfg.Issuer,
+			Address:   cfg.Address,
+			TaxOffice: cfg.TaxOffice,
+			Exemption: cfg.Exemption,
+			Purpose:   cfg.Purpose,
+			Place:     cfg.Place,
+			Signatory: cfg.Signatory,
+		}
+		receipt.Document = Render(receipt.Content)
+		return nil
+	})
+}
+
+// Render creates a receipt's PDF document, a collective receipt for
+// donations (Sammelbestätigung über Geldzuwendungen) with the single
+// donations listed in its appendix
+func Render(c db.ReceiptContent) []byte {
+	doc := &pdf{}
+	doc.addPage()
+	y := PAGE_HEIGHT - margin
+	width := PAGE_WIDTH - 2*margin
+
+	paragraph := func(s string) {
+		for _, l := range wrap(s, width, fontSize) {
+			doc.text(margin, y, fontSize, false, l)
+			y -= lineHeight
+		}
+		y -= lineHeight / 2
+	}
+
+	// issuer
+	doc.text(margin, y, fontSize, true, c.Issuer.Name)
+	y -= lineHeight
+	for _, l := range c.Issuer.Address {
+		doc.text(margin, y, fontSize, false, l)
+		y -= lineHeight
+	}
+	y -= 2 * lineHeight
+
+	// donor
+	doc.text(margin, y, 8, false, "Name und Anschrift des Zuwendenden:")
+	y -= lineHeight
+	for _, l := range donorAddress(c.Donor) {
+		doc.text(margin, y, fontSize, false, l)
+		y -= lineHeight
+	}
+	y -= 2 * lineHeight
+
+	doc.text(margin, y, 14, true, "Sammelbestätigung über Geldzuwendungen")
+	y -= lineHeight
+	paragraph("im Sinne des § 10b des Einkommensteuergesetzes an eine der in § 5 Abs. 1 Nr. 9 des " +
+		"Körperschaftsteuergesetzes bezeichneten Körperschaften, Personenvereinigungen oder Vermögensmassen")
+
+	doc.text(margin, y, fontSize, false, "Bestätigung Nr.")
+	doc.text(margin+170, y, fontSize, true, c.Number)
+	y -= lineHeight
+	doc.text(margin, y, fontSize, false, "Gesamtbetrag der Zuwendung:")
+	doc.text(margin+170, y, fontSize, true, formatAmount(c.Amount))
+	y -= lineHeight
+	doc.text(margin, y, fontSize, false, "in Buchstaben:")
+	doc.text(margin+170, y, fontSize, false, amountWords(c.Amount))
+	y -= lineHeight
+	doc.text(margin, y, fontSize, false, "Zeitraum der Sammelbestätigung:")
+	doc.text(margin+170, y, fontSize, false, "01.01."+strconv.Itoa(c.Year)+" bis 31.12."+strconv.Itoa(c.Year))
+	y -= 2 * lineHeight
+
+	if len(c.Supplements) > 0 {
+		paragraph("Diese Bestätigung ergänzt die Bestätigung Nr. " + strings.Join(c.Supplements, ", ") +
+			" und umfasst nur Zuwendungen, die darin nicht bestätigt wurden.")
+	}
+
+	exemption := c.Issuer.TaxOffice
+	if len(c.Issuer.Exemption) > 0 {
+		exemption += ", " + c.Issuer.Exemption
+	}
+	paragraph("Wir sind wegen " + c.Issuer.Purpose + " nach dem Freistellungsbescheid bzw. nach der Anlage zum " +
+		"Körperschaftsteuerbescheid (" + exemption + ") nach § 5 Abs. 1 Nr. 9 des " +
+		"Körperschaftsteuergesetzes von der Körperschaftsteuer und nach § 3 Nr. 6 des Gewerbesteuergesetzes " +
+		"von der Gewerbesteuer befreit.")
+	paragraph("Es wird bestätigt, dass die Zuwendungen nur zur " + c.Issuer.Purpose + " verwendet werden.")
+	paragraph("Es wird bestätigt, dass über die in der Gesamtsumme enthaltenen Zuwendungen keine weiteren " +
+		"Bestätigungen, weder formelle Zuwendungsbestätigungen noch Beitragsquittungen oder ähnliches " +
+		"ausgestellt wurden und werden.")
+	paragraph("Ob es sich um den Verzicht auf Erstattung von Aufwendungen handelt, ist der Anlage zur " +
+		"Sammelbestätigung zu entnehmen.")
+	y -= lineHeight
+
+	place := c.IssuedAt.Format("02.01.2006")
+	if len(c.Issuer.Place) > 0 {
+		place = c.Issuer.Place + ", " + place
+	}
+	doc.text(margin, y, fontSize, false, place)
+	y -= lineHeight
+	doc.text(margin, y, fontSize, false, c.Issuer.Signatory)
+	y -= 2 * lineHeight
+
+	doc.text(margin, y, 8, true, "Hinweis:")
+	y -= lineHeight
+	for _, l := range wrap("Wer vorsätzlich oder grob fahrlässig eine unrichtige Zuwendungsbestätigung erstellt "+
+		"oder veranlasst, dass Zuwendungen nicht zu den in der Zuwendungsbestätigung angegebenen "+
+		"steuerbegünstigten Zwecken verwendet werden, haftet für die entgangene Steuer (§ 10b Abs. 4 EStG, "+
+		"§ 9 Abs. 3 KStG, § 9 Nr. 5 GewStG).", width, 8) {
+		doc.text(margin, y, 8, false, l)
+		y -= 10
+	}
+
+	renderAppendix(doc, c)
+	return doc.bytes()
+}
+
+// renderAppendix lists the single donations of a receipt on as many pages as
+// they need
+func renderAppendix(doc *pdf, c db.ReceiptContent) {
+	right := PAGE_WIDTH - margin
+	var y float64
+	header := func() {
+		doc.addPage()
+		y = PAGE_HEIGHT - margin
+		doc.text(margin, y, 12, true, "Anlage zur Sammelbestätigung Nr. "+c.Number)
+		y -= 2 * lineHeight
+		doc.text(margin, y, fontSize, true, "Datum")
+		doc.text(margin+90, y, fontSize, true, "Art der Zuwendung")
+		doc.text(margin+220, y, fontSize, true, "Verzicht auf Erstattung")
+		doc.textRight(right, y, fontSize, true, "Betrag")
+		y -= lineHeight / 2
+		doc.line(margin, y, right, y)
+		y -= lineHeight
+	}
+
+	header()
+	for _, d := range c.Donations {
+		if y < margin+2*lineHeight {
+			header()
+		}
+		doc.text(margin, y, fontSize, false, d.Date.Format("02.01.2006"))
+		doc.text(margin+90, y, fontSize, false, "Geldzuwendung")
+		doc.text(margin+220, y, fontSize, false, "nein")
+		doc.textRight(right, y, fontSize, false, formatAmount(d.Amount))
+		y -= lineHeight
+	}
+
+	y += lineHeight / 2
+	doc.line(margin, y, right, y)
+	y -= lineHeight
+	doc.text(margin, y, fontSize, true, "Gesamtsumme")
+	doc.textRight(right, y, fontSize, true, formatAmount(c.Amount))
+}
+
+// donorAddress returns the lines of a donor's postal address
+func donorAddress(donor db.ReceiptDonor) []string {
+	lines := []string{donor.Name}
+	for _, l := range donor.Address {
+		if len(strings.TrimSpace(l)) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	if city := strings.TrimSpace(donor.ZIP + " " + donor.City); len(city) > 0 {
+		lines = append(lines, city)
+	}
+	if len(donor.Country) > 0 {
+		lines = append(lines, donor.Country)
+	}
+	return lines
+}
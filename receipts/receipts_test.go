@@ -0,0 +1,86 @@
+package receipts
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+func TestAmountWords(t *testing.T) {
+	tests := []struct {
+		cents int64
+		words string
+	}{
+		{100, "ein Euro"},
+		{2100, "einundzwanzig Euro"},
+		{10100, "einhundertein Euro"},
+		{125050, "eintausendzweihundertfünfzig Euro und fünfzig Cent"},
+		{100000000, "eine Million Euro"},
+		{330000001, "drei Millionen dreihunderttausend Euro und ein Cent"},
+		{99, "null Euro und neunundneunzig Cent"},
+		{606, "sechs Euro und sechs Cent"},
+	}
+
+	for _, test := range tests {
+		if words := amountWords(test.cents); words != test.words {
+			t.Errorf("%d: expected %q, got %q", test.cents, test.words, words)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for cents, s := range map[int64]string{5: "0,05 €", 125050: "1.250,50 €", 123456789: "1.234.567,89 €"} {
+		if f := formatAmount(cents); f != s {
+			t.Errorf("%d: expected %q, got %q", cents, s, f)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c := db.ReceiptContent{
+		Number:   "2025-00001",
+		Year:     2025,
+		IssuedAt: time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC),
+		Donor:    db.ReceiptDonor{Name: "Jörg Müller", Address: []string{"Hauptstraße 1"}, ZIP: "10115", City: "Berlin"},
+		Amount:   0,
+		Currency: "EUR",
+		Issuer:   db.ReceiptIssuer{Name: "Sangha e.V.", TaxOffice: "Finanzamt Berlin", Purpose: "Förderung der Bildung"},
+	}
+	// enough donations for the appendix to need a second page
+	for i := 0; i < 60; i++ {
+		c.Donations = append(c.Donations, db.ReceiptDonation{PaymentID: int64(i), Date: c.IssuedAt.AddDate(-1, 0, i), Amount: 1000})
+		c.Amount += 1000
+	}
+
+	doc := Render(c)
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Error("not a PDF document")
+	}
+	if n := bytes.Count(doc, []byte("/Type /Page ")); n != 3 {
+		t.Errorf("expected 3 pages, got %d", n)
+	}
+	// umlauts are encoded in WinAnsiEncoding
+	if !bytes.Contains(doc, []byte(`(J\366rg M\374ller)`)) {
+		t.Error("donor name not encoded")
+	}
+	if !bytes.Contains(doc, []byte(`(600,00 \200)`)) {
+		t.Error("total amount missing")
+	}
+
+	// receipts can be reproduced from their content
+	if !bytes.Equal(doc, Render(c)) {
+		t.Error("rendering the same content differs")
+	}
+
+	// supplementary receipts refer to the ones they supplement
+	if bytes.Contains(doc, []byte("2025-00003")) {
+		t.Error("unexpected reference to another receipt")
+	}
+	c.Number = "2025-00007"
+	c.Supplements = []string{"2025-00003"}
+	if !bytes.Contains(Render(c), []byte("2025-00003")) {
+		t.Error("supplemented receipt missing")
+	}
+}
@@ -0,0 +1,101 @@
+package receipts
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	smallNumbers = []string{
+		"null", "eins", "zwei", "drei", "vier", "fünf", "sechs", "sieben", "acht", "neun",
+		"zehn", "elf", "zwölf", "dreizehn", "vierzehn", "fünfzehn", "sechzehn", "siebzehn", "achtzehn", "neunzehn",
+	}
+	tens = []string{
+		"", "", "zwanzig", "dreißig", "vierzig", "fünfzig", "sechzig", "siebzig", "achtzig", "neunzig",
+	}
+)
+
+// numberWords spells out a non-negative number in German, as donation
+// receipts state their amount in words
+func numberWords(n int64) string {
+	if n == 0 {
+		return smallNumbers[0]
+	}
+
+	var words string
+	if m := n / 1000000; m > 0 {
+		if m == 1 {
+			words = "eine Million "
+		} else {
+			words = below1000(m, true) + " Millionen "
+		}
+		n %= 1000000
+	}
+	if t := n / 1000; t > 0 {
+		words += below1000(t, true) + "tausend"
+		n %= 1000
+	}
+	if n > 0 {
+		words += below1000(n, false)
+	}
+
+	return strings.TrimSpace(words)
+}
+
+// below1000 spells out 0 < n < 1000. Numbers ending in one are spelled "ein"
+// when they're followed by another word, e.g. "eintausend"
+func below1000(n int64, compound bool) string {
+	var words string
+	if h := n / 100; h > 0 {
+		words = below100(h, true) + "hundert"
+		n %= 100
+	}
+	if n > 0 {
+		words += below100(n, compound)
+	}
+	return words
+}
+
+func below100(n int64, compound bool) string {
+	switch {
+	case n == 1 && compound:
+		return "ein"
+	case n < 20:
+		return smallNumbers[n]
+	case n%10 == 0:
+		return tens[n/10]
+	}
+	return below100(n%10, true) + "und" + tens[n/10]
+}
+
+// amountWords spells out an amount of euro cents
+func amountWords(cents int64) string {
+	words := unitWords(cents/100) + " Euro"
+	if c := cents % 100; c > 0 {
+		words += " und " + unitWords(c) + " Cent"
+	}
+	return words
+}
+
+// unitWords spells out a number followed by a unit, which turns a final
+// "eins" into "ein", e.g. "einhundertein Euro"
+func unitWords(n int64) string {
+	words := numberWords(n)
+	if strings.HasSuffix(words, "eins") {
+		words = strings.TrimSuffix(words, "s")
+	}
+	return words
+}
+
+// formatAmount formats euro cents the German way, e.g. 1.250,50 €
+func formatAmount(cents int64) string {
+	euros := fmt.Sprint(cents / 100)
+	var b strings.Builder
+	for i, r := range euros {
+		if i > 0 && (len(euros)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	return fmt.Sprintf("%s,%02d €", b.String(), cents%100)
+}
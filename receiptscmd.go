@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/receipts"
+)
+
+var (
+	receiptsCmd = &cobra.Command{
+		Use:   "receipts",
+		Short: "manage donation receipts",
+		Long:  `The receipts command is used to manage annual donation receipts`,
+		RunE:  nil,
+	}
+	receiptsIssueCmd = &cobra.Command{
+		Use:   "issue [user-uuid...]",
+		Short: "issue annual donation receipts",
+		Long: `The issue command issues donation receipts (Zuwendungsbestätigungen) for all
+processed donations of a year and writes them as PDF files into the output
+directory. Without user UUIDs receipts are issued to all donors with donations
+that haven't been receipted yet. Donations processed after a donor's receipt
+got issued are confirmed on a supplementary receipt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeReceiptsIssue(args)
+		},
+	}
+
+	receiptsCorrectionsCmd = &cobra.Command{
+		Use:   "corrections",
+		Short: "list donation receipts that need to be corrected",
+		Long: `The corrections command lists the donation receipts stating donations that
+have been refunded or charged back since they were issued. The tax office
+needs to be notified about each of them`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeReceiptsCorrections()
+		},
+	}
+	receiptsCorrectedCmd = &cobra.Command{
+		Use:   "corrected [number...]",
+		Short: "mark donation receipts as corrected",
+		Long: `The corrected command records that the tax office has been notified about
+donation receipts that needed to be corrected`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeReceiptsCorrected(args)
+		},
+	}
+
+	receiptsYear   int
+	receiptsOutput string
+)
+
+func init() {
+	receiptsIssueCmd.Flags().IntVarP(&receiptsYear, "year", "y", time.Now().UTC().Year()-1, "year to issue receipts for, defaults to last year")
+	receiptsIssueCmd.Flags().StringVarP(&receiptsOutput, "output-dir", "o", ".", "directory to write the receipts to")
+
+	receiptsCmd.AddCommand(receiptsIssueCmd)
+	receiptsCmd.AddCommand(receiptsCorrectionsCmd)
+	receiptsCmd.AddCommand(receiptsCorrectedCmd)
+	RootCmd.AddCommand(receiptsCmd)
+}
+
+func executeReceiptsIssue(uuids []string) error {
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	var donors []int64
+	if len(uuids) == 0 {
+		var err error
+		donors, err = ctx.LoadReceiptDonors(receiptsYear)
+		if err != nil {
+			return err
+		}
+	}
+	for _, uuid := range uuids {
+		user, err := ctx.LoadUserByUUID(uuid)
+		if err != nil {
+			return fmt.Errorf("Can't load user %s: %v", uuid, err)
+		}
+		donors = append(donors, user.ID)
+	}
+
+	var issued, failed int
+	for _, id := range donors {
+		receipt, err := receipts.Issue(ctx, id, receiptsYear)
+		if err == db.ErrAlreadyReceipted {
+			log.WithFields(log.Fields{
+				"User": id,
+				"Year": receiptsYear,
+			}).Info("All donations have been receipted already")
+			continue
+		}
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(receiptsOutput, receipt.Number+".pdf"), receipt.Document, 0600)
+		}
+		if err != nil {
+			failed++
+			log.WithFields(log.Fields{
+				"User":  id,
+				"Year":  receiptsYear,
+				"Error": err,
+			}).Error("Can't issue donation receipt")
+			continue
+		}
+		issued++
+	}
+
+	log.Printf("Issued %d of %d donation receipts for %d", issued, len(donors), receiptsYear)
+	if failed > 0 {
+		return fmt.Errorf("%d donation receipts could not be issued", failed)
+	}
+	return nil
+}
+
+func executeReceiptsCorrections() error {
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	receipts, err := ctx.LoadDonationReceiptsToCorrect()
+	if err != nil {
+		return err
+	}
+
+	for _, receipt := range receipts {
+		fmt.Printf("%s: %s, issued %s\n", receipt.Number, receipt.Content.Donor.Name, receipt.IssuedAt.Format("2006-01-02"))
+	}
+	if len(receipts) > 0 {
+		log.Warnf("%d donation receipts need to be corrected", len(receipts))
+	}
+	return nil
+}
+
+func executeReceiptsCorrected(numbers []string) error {
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	for _, number := range numbers {
+		if err := ctx.MarkDonationReceiptCorrected(number); err != nil {
+			return fmt.Errorf("Can't mark receipt %s as corrected: %v", number, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package activations
+
+import (
+	"errors"
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/badoux/checkmail"
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ActivationResource is the resource responsible for /activations
+type ActivationResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.PostSupported = &ActivationResource{}
+	_ smolder.PutSupported  = &ActivationResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *ActivationResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "ActivationResource"
+	r.TypeName = "activation"
+	r.Endpoint = "activations"
+	r.Doc = "Activate user accounts"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *ActivationResource) Reads() interface{} {
+	return &ActivationPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *ActivationResource) Returns() interface{} {
+	return ActivationResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *ActivationResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	aps := data.(*ActivationPostStruct)
+
+	if request.Request.Method == http.MethodPut {
+		return db.ValidPassword(aps.Activation.Password)
+	}
+
+	if err := checkmail.ValidateFormat(aps.Activation.Email); err != nil {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
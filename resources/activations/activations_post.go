@@ -0,0 +1,48 @@
+package activations
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ActivationPostStruct holds all values of an incoming POST request
+type ActivationPostStruct struct {
+	Activation struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	} `json:"activation"`
+}
+
+// PostAuthRequired returns false because users can't log in before their
+// account has been activated
+func (r *ActivationResource) PostAuthRequired() bool {
+	return false
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *ActivationResource) PostDoc() string {
+	return "send a new activation email"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *ActivationResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request. To not reveal which
+// email addresses are registered, it responds the same way for unknown
+// addresses, already activated accounts & failed mails
+func (r *ActivationResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := ActivationResponse{}
+	resp.Init(context)
+
+	aps := data.(*ActivationPostStruct)
+	ctx.RequestUserToken(aps.Activation.Email, db.TOKEN_ACTIVATION)
+
+	resp.SendWithHeader(http.StatusAccepted, response)
+}
@@ -0,0 +1,53 @@
+package activations
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// PutAuthRequired returns false because the activation token authenticates
+// the request
+func (r *ActivationResource) PutAuthRequired() bool {
+	return false
+}
+
+// PutDoc returns the description of this API endpoint
+func (r *ActivationResource) PutDoc() string {
+	return "activate an account with an activation token & choose a password"
+}
+
+// PutParams returns the parameters supported by this API endpoint
+func (r *ActivationResource) PutParams() []*restful.Parameter {
+	return nil
+}
+
+// Put processes an incoming PUT (update) request
+func (r *ActivationResource) Put(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := ActivationResponse{}
+	resp.Init(context)
+
+	aps := data.(*ActivationPostStruct)
+	user, err := ctx.ActivateUser(request.PathParameter("activation-id"), aps.Activation.Password)
+	if err == db.ErrInvalidUserToken {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"ActivationResource PUT"))
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't activate account",
+			"ActivationResource PUT"))
+		return
+	}
+
+	resp.AddUser(&user)
+	resp.Send(response)
+}
@@ -0,0 +1,53 @@
+package activations
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// ActivationResponse is the common response to 'activation' requests
+type ActivationResponse struct {
+	smolder.Response
+
+	Activations []activationInfoResponse `json:"activations,omitempty"`
+	users       []db.User
+}
+
+type activationInfoResponse struct {
+	UserID    string `json:"user_id"`
+	Activated bool   `json:"activated"`
+}
+
+// Init a new response
+func (r *ActivationResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Activations = []activationInfoResponse{}
+}
+
+// AddUser adds an activated user to the response
+func (r *ActivationResponse) AddUser(user *db.User) {
+	r.users = append(r.users, *user)
+	r.Activations = append(r.Activations, prepareActivationResponse(r.Context, user))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *ActivationResponse) EmptyResponse() interface{} {
+	if len(r.users) == 0 {
+		var out struct {
+			Activations interface{} `json:"activations"`
+		}
+		out.Activations = []activationInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareActivationResponse(context smolder.APIContext, user *db.User) activationInfoResponse {
+	return activationInfoResponse{
+		UserID:    user.UUID,
+		Activated: user.Activated,
+	}
+}
@@ -0,0 +1,57 @@
+package approvalrequests
+
+import (
+	"errors"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ApprovalRequestResource is the resource responsible for /approval_requests
+type ApprovalRequestResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetSupported   = &ApprovalRequestResource{}
+	_ smolder.GetIDSupported = &ApprovalRequestResource{}
+	_ smolder.PutSupported   = &ApprovalRequestResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *ApprovalRequestResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "ApprovalRequestResource"
+	r.TypeName = "approval_request"
+	r.Endpoint = "approval_requests"
+	r.Doc = "Approve or reject large transfers & outgoing payments"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *ApprovalRequestResource) Reads() interface{} {
+	return &ApprovalRequestPutStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *ApprovalRequestResource) Returns() interface{} {
+	return ApprovalRequestResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *ApprovalRequestResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	aps := data.(*ApprovalRequestPutStruct)
+
+	switch aps.ApprovalRequest.Decision {
+	case db.APPROVAL_DECISION_APPROVE, db.APPROVAL_DECISION_REJECT:
+	default:
+		return errors.New("Invalid decision")
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package approvalrequests
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *ApprovalRequestResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetByIDsAuthRequired returns true because all requests need authentication
+func (r *ApprovalRequestResource) GetByIDsAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *ApprovalRequestResource) GetDoc() string {
+	return "retrieve approval requests"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *ApprovalRequestResource) GetParams() []*restful.Parameter {
+	params := []*restful.Parameter{}
+	params = append(params, restful.QueryParameter("state", "returns only 'pending' (default), 'approved' or 'rejected' requests").DataType("string"))
+
+	return params
+}
+
+// GetByIDs sends out all items matching a set of IDs
+func (r *ApprovalRequestResource) GetByIDs(context smolder.APIContext, request *restful.Request, response *restful.Response, ids []string) {
+	ctx := context.(*db.APIContext)
+	resp := ApprovalRequestResponse{}
+	resp.Init(context)
+
+	for _, id := range ids {
+		iid, _ := strconv.ParseInt(id, 10, 0)
+		ar, err := ctx.LoadApprovalRequestByID(iid)
+		if err != nil {
+			r.NotFound(request, response)
+			return
+		}
+		if !ar.CanDecide(ctx, ctx.Auth) {
+			smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"ApprovalRequestResource GET"))
+			return
+		}
+
+		resp.AddApprovalRequest(ar)
+	}
+
+	resp.Send(response)
+}
+
+// Get sends out items matching the query parameters. Only requests the user
+// may decide on are returned
+func (r *ApprovalRequestResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := ApprovalRequestResponse{}
+	resp.Init(context)
+
+	state := db.APPROVAL_STATE_PENDING
+	if len(params["state"]) > 0 {
+		state = params["state"][0]
+	}
+
+	requests, err := ctx.LoadApprovalRequests(state)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load approval requests",
+			"ApprovalRequestResource GET"))
+		return
+	}
+
+	for _, ar := range requests {
+		if ar.CanDecide(ctx, ctx.Auth) {
+			resp.AddApprovalRequest(ar)
+		}
+	}
+
+	resp.Send(response)
+}
@@ -0,0 +1,80 @@
+package approvalrequests
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ApprovalRequestPutStruct holds all values of an incoming PUT request
+type ApprovalRequestPutStruct struct {
+	ApprovalRequest struct {
+		Decision string `json:"decision"`
+	} `json:"approval_request"`
+}
+
+// PutAuthRequired returns true because all requests need authentication
+func (r *ApprovalRequestResource) PutAuthRequired() bool {
+	return true
+}
+
+// PutDoc returns the description of this API endpoint
+func (r *ApprovalRequestResource) PutDoc() string {
+	return "approve or reject a request"
+}
+
+// PutParams returns the parameters supported by this API endpoint
+func (r *ApprovalRequestResource) PutParams() []*restful.Parameter {
+	return nil
+}
+
+// Put processes an incoming PUT (update) request
+func (r *ApprovalRequestResource) Put(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := ApprovalRequestResponse{}
+	resp.Init(context)
+
+	iid, _ := strconv.ParseInt(request.PathParameter("approval_request-id"), 10, 0)
+	ar, err := ctx.LoadApprovalRequestByID(iid)
+	if err != nil {
+		r.NotFound(request, response)
+		return
+	}
+	if !ar.CanDecide(ctx, ctx.Auth) {
+		smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"ApprovalRequestResource PUT"))
+		return
+	}
+
+	aps := data.(*ApprovalRequestPutStruct)
+	ar, err = ctx.DecideApprovalRequest(ar.ID, aps.ApprovalRequest.Decision)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		r.NotFound(request, response)
+		return
+	case db.ErrInvalidDecision, db.ErrApprovalDecided, db.ErrAlreadyApproved, db.ErrInsufficientFunds,
+		db.ErrInvalidTransition, db.ErrPaymentRefunded:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"ApprovalRequestResource PUT"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't decide on approval request",
+			"ApprovalRequestResource PUT"))
+		return
+	}
+
+	resp.AddApprovalRequest(ar)
+	resp.Send(response)
+}
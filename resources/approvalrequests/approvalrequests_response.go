@@ -0,0 +1,114 @@
+package approvalrequests
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// ApprovalRequestResponse is the common response to 'approval_request' requests
+type ApprovalRequestResponse struct {
+	smolder.Response
+
+	ApprovalRequests []approvalRequestInfoResponse `json:"approval_requests,omitempty"`
+	approvalRequests []db.ApprovalRequest
+}
+
+type approvalRequestInfoResponse struct {
+	ID                int64              `json:"id"`
+	Kind              string             `json:"kind"`
+	State             string             `json:"state"`
+	PaymentID         *int64             `json:"payment_id,omitempty"`
+	BudgetID          string             `json:"budget_id,omitempty"`
+	ToBudgetID        string             `json:"to_budget_id,omitempty"`
+	Amount            int64              `json:"amount"`
+	Code              string             `json:"code,omitempty"`
+	Purpose           string             `json:"purpose"`
+	UserID            string             `json:"user_id,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	DecidedAt         *time.Time         `json:"decided_at,omitempty"`
+	TransactionID     *int64             `json:"transaction_id,omitempty"`
+	RequiredApprovals int                `json:"required_approvals"`
+	Approvals         []approvalResponse `json:"approvals"`
+}
+
+type approvalResponse struct {
+	UserID    string    `json:"user_id"`
+	Decision  string    `json:"decision"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Init a new response
+func (r *ApprovalRequestResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.ApprovalRequests = []approvalRequestInfoResponse{}
+}
+
+// AddApprovalRequest adds an approval request to the response
+func (r *ApprovalRequestResponse) AddApprovalRequest(request db.ApprovalRequest) {
+	r.approvalRequests = append(r.approvalRequests, request)
+	r.ApprovalRequests = append(r.ApprovalRequests, prepareApprovalRequestResponse(r.Context, request))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *ApprovalRequestResponse) EmptyResponse() interface{} {
+	if len(r.approvalRequests) == 0 {
+		var out struct {
+			ApprovalRequests interface{} `json:"approval_requests"`
+		}
+		out.ApprovalRequests = []approvalRequestInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareApprovalRequestResponse(context smolder.APIContext, request db.ApprovalRequest) approvalRequestInfoResponse {
+	ctx := context.(*db.APIContext)
+	resp := approvalRequestInfoResponse{
+		ID:                request.ID,
+		Kind:              request.Kind,
+		State:             request.State,
+		PaymentID:         request.PaymentID,
+		Amount:            request.Amount,
+		Code:              request.Code,
+		Purpose:           request.Purpose,
+		CreatedAt:         request.CreatedAt,
+		DecidedAt:         request.DecidedAt,
+		TransactionID:     request.TransactionID,
+		RequiredApprovals: ctx.RequiredApprovals(),
+		Approvals:         []approvalResponse{},
+	}
+
+	if request.FromBudgetID != nil {
+		if budget, err := ctx.LoadBudgetByID(*request.FromBudgetID); err == nil {
+			resp.BudgetID = budget.UUID
+		}
+	}
+	if request.ToBudgetID != nil {
+		if budget, err := ctx.LoadBudgetByID(*request.ToBudgetID); err == nil {
+			resp.ToBudgetID = budget.UUID
+		}
+	}
+	if request.UserID != nil {
+		if user, err := ctx.LoadUserByID(*request.UserID); err == nil {
+			resp.UserID = user.UUID
+		}
+	}
+
+	for _, a := range request.Approvals {
+		ar := approvalResponse{
+			Decision:  a.Decision,
+			CreatedAt: a.CreatedAt,
+		}
+		if user, err := ctx.LoadUserByID(a.UserID); err == nil {
+			ar.UserID = user.UUID
+		}
+		resp.Approvals = append(resp.Approvals, ar)
+	}
+
+	return resp
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"strings"
 
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
 )
@@ -51,6 +53,9 @@ func (r *BudgetResource) Validate(context smolder.APIContext, data interface{},
 	if strings.TrimSpace(ups.Budget.Name) == "" {
 		return errors.New("Invalid budget name")
 	}
+	if ups.Budget.Currency != "" && !db.ValidCurrency(strings.ToUpper(ups.Budget.Currency)) {
+		return db.ErrInvalidCurrency
+	}
 
 	return nil
 }
@@ -26,15 +26,6 @@ func (r *BudgetResource) DeleteParams() []*restful.Parameter {
 
 // Post processes an incoming POST (create) request
 func (r *BudgetResource) Delete(context smolder.APIContext, request *restful.Request, response *restful.Response) {
-	auth, err := context.Authentication(request)
-	if err != nil || auth.(db.User).ID != 1 {
-		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-			http.StatusUnauthorized,
-			"Admin permission required for this operation",
-			"BudgetResource DELETE"))
-		return
-	}
-
 	ctx := context.(*db.APIContext)
 	budget, err := ctx.GetBudgetByUUID(request.PathParameter("budget-id"))
 	if err != nil {
@@ -42,6 +33,19 @@ func (r *BudgetResource) Delete(context smolder.APIContext, request *restful.Req
 		return
 	}
 
+	perm := db.PERMISSION_MANAGE_BUDGETS
+	if budget.ParentID == 0 {
+		perm = db.PERMISSION_MANAGE_PROJECTS
+	}
+	_, err = ctx.Authorize(request, perm, budget.ProjectID)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"BudgetResource DELETE"))
+		return
+	}
+
 	err = budget.Delete(ctx)
 	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
@@ -2,6 +2,7 @@ package budgets
 
 import (
 	"net/http"
+	"strings"
 
 	"gitlab.techcultivation.org/sangha/sangha/db"
 
@@ -18,6 +19,7 @@ type BudgetPostStruct struct {
 		Description    string `json:"description"`
 		Private        bool   `json:"private"`
 		PrivateBalance bool   `json:"private_balance"`
+		Currency       string `json:"currency"`
 	} `json:"budget"`
 }
 
@@ -38,22 +40,38 @@ func (r *BudgetResource) PostParams() []*restful.Parameter {
 
 // Post processes an incoming POST (create) request
 func (r *BudgetResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
-	auth, err := context.Authentication(request)
-	if err != nil || auth.(db.User).ID != 1 {
+	ups := data.(*BudgetPostStruct)
+
+	project, err := context.(*db.APIContext).LoadProjectByUUID(ups.Budget.Project)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			http.StatusBadRequest,
+			"No such project",
 			"BudgetResource POST"))
 		return
 	}
 
-	ups := data.(*BudgetPostStruct)
+	// only sub-budgets can be managed on a project level, root budgets get
+	// created along with their project
+	perm := db.PERMISSION_MANAGE_BUDGETS
+	if ups.Budget.ParentID == 0 {
+		perm = db.PERMISSION_MANAGE_PROJECTS
+	} else {
+		parent, err := context.(*db.APIContext).LoadBudgetByID(ups.Budget.ParentID)
+		if err != nil || parent.ProjectID == nil || *parent.ProjectID != project.ID {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusBadRequest,
+				"Parent budget does not belong to this project",
+				"BudgetResource POST"))
+			return
+		}
+	}
 
-	project, err := context.(*db.APIContext).LoadProjectByUUID(ups.Budget.Project)
+	_, err = context.(*db.APIContext).Authorize(request, perm, &project.ID)
 	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-			http.StatusBadRequest,
-			"No such project",
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
 			"BudgetResource POST"))
 		return
 	}
@@ -65,6 +83,7 @@ func (r *BudgetResource) Post(context smolder.APIContext, data interface{}, requ
 		Description:    ups.Budget.Description,
 		Private:        ups.Budget.Private,
 		PrivateBalance: ups.Budget.PrivateBalance,
+		Currency:       strings.ToUpper(ups.Budget.Currency),
 	}
 	err = budget.Save(context.(*db.APIContext))
 	if err != nil {
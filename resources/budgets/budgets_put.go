@@ -40,16 +40,21 @@ func (r *BudgetResource) Put(context smolder.APIContext, data interface{}, reque
 		return
 	}
 
-	auth, err := context.Authentication(request)
-	if err != nil || (auth.(db.User).ID != 1) { // && auth.(db.User).ID != budget.UserID) {
+	// root budgets are managed along with their project
+	perm := db.PERMISSION_MANAGE_BUDGETS
+	if budget.ParentID == 0 {
+		perm = db.PERMISSION_MANAGE_PROJECTS
+	}
+	_, err = context.(*db.APIContext).Authorize(request, perm, budget.ProjectID)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"BudgetResource PUT"))
 		return
 	}
 
-	pps := data.(*BudgetPutStruct)
+	pps := data.(*BudgetPostStruct)
 	project, err := context.(*db.APIContext).LoadProjectByUUID(pps.Budget.Project)
 	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
@@ -58,6 +63,17 @@ func (r *BudgetResource) Put(context smolder.APIContext, data interface{}, reque
 			"BudgetResource PUT"))
 		return
 	}
+	if budget.ProjectID == nil || *budget.ProjectID != project.ID {
+		// moving budgets between projects would detach them from their parent
+		_, err = context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_PROJECTS, nil)
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"BudgetResource PUT"))
+			return
+		}
+	}
 
 	budget.ProjectID = &project.ID
 	budget.Name = pps.Budget.Name
@@ -24,6 +24,7 @@ type BudgetInfoResponse struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Balance     int64  `json:"balance"`
+	Currency    string `json:"currency"`
 	Code        string `json:"code"`
 }
 
@@ -74,6 +75,7 @@ func PrepareBudgetResponse(context smolder.APIContext, budget *db.Budget) Budget
 		Project:     project.UUID,
 		Name:        budget.Name,
 		Description: budget.Description,
+		Currency:    budget.Currency,
 	}
 
 	resp.Balance, _ = budget.Balance(ctx)
@@ -1,6 +1,8 @@
 package codes
 
 import (
+	"errors"
+
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
 )
@@ -13,6 +15,7 @@ type CodeResource struct {
 var (
 	_ smolder.GetIDSupported = &CodeResource{}
 	_ smolder.GetSupported   = &CodeResource{}
+	_ smolder.PostSupported  = &CodeResource{}
 )
 
 // Register this resource with the container to setup all the routes
@@ -28,7 +31,26 @@ func (r *CodeResource) Register(container *restful.Container, config smolder.API
 	r.Init(container, r)
 }
 
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *CodeResource) Reads() interface{} {
+	return &CodePostStruct{}
+}
+
 // Returns returns the model that will be returned
 func (r *CodeResource) Returns() interface{} {
 	return CodeResponse{}
 }
+
+// Validate checks an incoming request for data errors
+func (r *CodeResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	cps := data.(*CodePostStruct)
+
+	if len(cps.Code.BudgetIDs) == 0 {
+		return errors.New("No budgets submitted")
+	}
+	if len(cps.Code.BudgetIDs) != len(cps.Code.Ratios) {
+		return errors.New("Budget & ratio sets have different sizes")
+	}
+
+	return nil
+}
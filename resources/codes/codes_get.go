@@ -72,7 +72,7 @@ func (r *CodeResource) Get(context smolder.APIContext, request *restful.Request,
 		if len(userID) > 0 {
 			uid = userID[0]
 		}
-		code, err := ctx.LoadCodeByBudgetsAndRatios(budgetIDs, ratios, uid)
+		code, err := ctx.FindCodeByBudgetsAndRatios(budgetIDs, ratios, uid)
 		if err != nil {
 			r.NotFound(request, response)
 			return
@@ -0,0 +1,74 @@
+package codes
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// CodePostStruct holds all values of an incoming POST request
+type CodePostStruct struct {
+	Code struct {
+		BudgetIDs []string `json:"budgets"`
+		Ratios    []string `json:"ratios"`
+	} `json:"code"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *CodeResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *CodeResource) PostDoc() string {
+	return "create a new distribution code"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *CodeResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *CodeResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	cps := data.(*CodePostStruct)
+
+	// codes may only distribute to budgets the user manages
+	for _, id := range cps.Code.BudgetIDs {
+		budget, err := ctx.LoadBudgetByUUID(id)
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusBadRequest,
+				"A budget with this ID does not exist",
+				"CodeResource POST"))
+			return
+		}
+
+		_, err = ctx.Authorize(request, db.PERMISSION_MANAGE_CODES, budget.ProjectID)
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"CodeResource POST"))
+			return
+		}
+	}
+
+	code, err := ctx.LoadCodeByBudgetsAndRatios(cps.Code.BudgetIDs, cps.Code.Ratios, "")
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"Can't create code",
+			"CodeResource POST"))
+		return
+	}
+
+	resp := CodeResponse{}
+	resp.Init(context)
+	resp.AddCode(&code)
+	resp.Send(response)
+}
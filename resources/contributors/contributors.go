@@ -0,0 +1,53 @@
+package contributors
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ContributorResource is the resource responsible for /contributors
+type ContributorResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetSupported    = &ContributorResource{}
+	_ smolder.PostSupported   = &ContributorResource{}
+	_ smolder.DeleteSupported = &ContributorResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *ContributorResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "ContributorResource"
+	r.TypeName = "contributor"
+	r.Endpoint = "contributors"
+	r.Doc = "Manage project contributors & their roles"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *ContributorResource) Reads() interface{} {
+	return &ContributorPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *ContributorResource) Returns() interface{} {
+	return ContributorResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *ContributorResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	cps := data.(*ContributorPostStruct)
+
+	if cps.Contributor.Role != "" && !db.ValidProjectRole(db.Role(cps.Contributor.Role)) {
+		return db.ErrInvalidRole
+	}
+
+	return nil
+}
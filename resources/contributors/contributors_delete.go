@@ -0,0 +1,60 @@
+package contributors
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DeleteAuthRequired returns true because all requests need authentication
+func (r *ContributorResource) DeleteAuthRequired() bool {
+	return true
+}
+
+// DeleteDoc returns the description of this API endpoint
+func (r *ContributorResource) DeleteDoc() string {
+	return "remove a contributor from a project"
+}
+
+// DeleteParams returns the parameters supported by this API endpoint
+func (r *ContributorResource) DeleteParams() []*restful.Parameter {
+	return nil
+}
+
+// Delete processes an incoming DELETE request
+func (r *ContributorResource) Delete(context smolder.APIContext, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+
+	id, _ := strconv.ParseInt(request.PathParameter("contributor-id"), 10, 64)
+	contributor, err := ctx.LoadContributorByID(id)
+	if err != nil {
+		r.NotFound(request, response)
+		return
+	}
+
+	_, err = ctx.Authorize(request, db.PERMISSION_MANAGE_CONTRIBUTORS, &contributor.ProjectID)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"ContributorResource DELETE"))
+		return
+	}
+
+	err = contributor.Delete(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't remove contributor",
+			"ContributorResource DELETE"))
+		return
+	}
+
+	resp := ContributorResponse{}
+	resp.Init(context)
+	resp.Send(response)
+}
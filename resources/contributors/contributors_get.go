@@ -0,0 +1,56 @@
+package contributors
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *ContributorResource) GetAuthRequired() bool {
+	return false
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *ContributorResource) GetDoc() string {
+	return "retrieve the contributors of a project"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *ContributorResource) GetParams() []*restful.Parameter {
+	params := []*restful.Parameter{}
+	params = append(params, restful.QueryParameter("project", "ID of a project").DataType("string"))
+
+	return params
+}
+
+// Get sends out items matching the query parameters
+func (r *ContributorResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := ContributorResponse{}
+	resp.Init(context)
+
+	if len(params["project"]) == 0 {
+		r.NotFound(request, response)
+		return
+	}
+
+	project, err := ctx.GetProjectByUUID(params["project"][0])
+	if err != nil || !project.HasAccess(ctx.Auth) {
+		r.NotFound(request, response)
+		return
+	}
+
+	contributors, err := project.LoadContributors(ctx)
+	if err != nil {
+		r.NotFound(request, response)
+		return
+	}
+
+	for _, contributor := range contributors {
+		resp.AddContributor(&contributor)
+	}
+
+	resp.Send(response)
+}
@@ -0,0 +1,97 @@
+package contributors
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// ContributorPostStruct holds all values of an incoming POST request
+type ContributorPostStruct struct {
+	Contributor struct {
+		Project string `json:"project"`
+		User    string `json:"user"`
+		Role    string `json:"role"`
+	} `json:"contributor"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *ContributorResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *ContributorResource) PostDoc() string {
+	return "add a contributor to a project or change their role"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *ContributorResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *ContributorResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	cps := data.(*ContributorPostStruct)
+
+	project, err := ctx.LoadProjectByUUID(cps.Contributor.Project)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"No such project",
+			"ContributorResource POST"))
+		return
+	}
+
+	_, err = ctx.Authorize(request, db.PERMISSION_MANAGE_CONTRIBUTORS, &project.ID)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"ContributorResource POST"))
+		return
+	}
+
+	user, err := ctx.LoadUserByUUID(cps.Contributor.User)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"No such user",
+			"ContributorResource POST"))
+		return
+	}
+
+	contributor := db.Contributor{
+		UserID:    user.ID,
+		ProjectID: project.ID,
+		Role:      db.Role(cps.Contributor.Role),
+	}
+	if contributor.Role == db.ROLE_NONE {
+		contributor.Role = db.ROLE_MAINTAINER
+	}
+	err = contributor.Save(ctx)
+	switch err {
+	case nil:
+	case db.ErrInvalidRole:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"ContributorResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't save contributor",
+			"ContributorResource POST"))
+		return
+	}
+
+	resp := ContributorResponse{}
+	resp.Init(context)
+	resp.AddContributor(&contributor)
+	resp.Send(response)
+}
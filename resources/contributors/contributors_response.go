@@ -0,0 +1,65 @@
+package contributors
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// ContributorResponse is the common response to 'contributor' requests
+type ContributorResponse struct {
+	smolder.Response
+
+	Contributors []contributorInfoResponse `json:"contributors,omitempty"`
+	contributors []db.Contributor
+}
+
+type contributorInfoResponse struct {
+	ID       int64  `json:"id"`
+	Project  string `json:"project"`
+	User     string `json:"user"`
+	Nickname string `json:"nickname"`
+	Role     string `json:"role"`
+}
+
+// Init a new response
+func (r *ContributorResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Contributors = []contributorInfoResponse{}
+}
+
+// AddContributor adds a contributor to the response
+func (r *ContributorResponse) AddContributor(contributor *db.Contributor) {
+	r.contributors = append(r.contributors, *contributor)
+	r.Contributors = append(r.Contributors, prepareContributorResponse(r.Context, contributor))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *ContributorResponse) EmptyResponse() interface{} {
+	if len(r.contributors) == 0 {
+		var out struct {
+			Contributors interface{} `json:"contributors"`
+		}
+		out.Contributors = []contributorInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareContributorResponse(context smolder.APIContext, contributor *db.Contributor) contributorInfoResponse {
+	ctx := context.(*db.APIContext)
+	resp := contributorInfoResponse{
+		ID:   contributor.ID,
+		Role: string(contributor.Role),
+	}
+
+	project, _ := ctx.GetProjectByID(contributor.ProjectID)
+	resp.Project = project.UUID
+	user, _ := ctx.LoadUserByID(contributor.UserID)
+	resp.User = user.UUID
+	resp.Nickname = user.Nickname
+
+	return resp
+}
@@ -0,0 +1,53 @@
+package donationreceipts
+
+import (
+	"errors"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DonationReceiptResource is the resource responsible for /donation_receipts
+type DonationReceiptResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetIDSupported = &DonationReceiptResource{}
+	_ smolder.GetSupported   = &DonationReceiptResource{}
+	_ smolder.PostSupported  = &DonationReceiptResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *DonationReceiptResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "DonationReceiptResource"
+	r.TypeName = "donation_receipt"
+	r.Endpoint = "donation_receipts"
+	r.Doc = "Issue & retrieve annual donation receipts"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *DonationReceiptResource) Reads() interface{} {
+	return &DonationReceiptPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *DonationReceiptResource) Returns() interface{} {
+	return DonationReceiptResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *DonationReceiptResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	dps := data.(*DonationReceiptPostStruct)
+
+	if dps.DonationReceipt.Year < 1 {
+		return errors.New("Missing year")
+	}
+
+	return nil
+}
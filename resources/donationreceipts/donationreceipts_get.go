@@ -0,0 +1,81 @@
+package donationreceipts
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *DonationReceiptResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetByIDsAuthRequired returns true because all requests need authentication
+func (r *DonationReceiptResource) GetByIDsAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *DonationReceiptResource) GetDoc() string {
+	return "retrieve the donation receipts issued to the current user"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *DonationReceiptResource) GetParams() []*restful.Parameter {
+	return nil
+}
+
+// GetByIDs sends out all items matching a set of IDs. Receipts can be
+// retrieved by the donor they've been issued to & users managing payments
+func (r *DonationReceiptResource) GetByIDs(context smolder.APIContext, request *restful.Request, response *restful.Response, ids []string) {
+	ctx := context.(*db.APIContext)
+	resp := DonationReceiptResponse{}
+	resp.Init(context)
+
+	for _, id := range ids {
+		iid, _ := strconv.ParseInt(id, 10, 0)
+		receipt, err := ctx.LoadDonationReceiptByID(iid)
+		if err != nil {
+			r.NotFound(request, response)
+			return
+		}
+		if receipt.UserID != ctx.Auth.ID && !ctx.Auth.Can(db.PERMISSION_MANAGE_PAYMENTS, nil) {
+			smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"DonationReceiptResource GET"))
+			return
+		}
+
+		resp.AddDonationReceipt(receipt)
+	}
+
+	resp.Send(response)
+}
+
+// Get sends out items matching the query parameters
+func (r *DonationReceiptResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := DonationReceiptResponse{}
+	resp.Init(context)
+
+	receipts, err := ctx.Auth.LoadDonationReceipts(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load donation receipts",
+			"DonationReceiptResource GET"))
+		return
+	}
+
+	for _, receipt := range receipts {
+		resp.AddDonationReceipt(receipt)
+	}
+
+	resp.Send(response)
+}
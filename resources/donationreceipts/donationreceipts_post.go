@@ -0,0 +1,83 @@
+package donationreceipts
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/receipts"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DonationReceiptPostStruct holds all values of an incoming POST request
+type DonationReceiptPostStruct struct {
+	DonationReceipt struct {
+		Year   int    `json:"year"`
+		UserID string `json:"user_id"`
+	} `json:"donation_receipt"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *DonationReceiptResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *DonationReceiptResource) PostDoc() string {
+	return "issue the receipt for a year's donations. Issuing receipts for other users requires permission to manage payments"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *DonationReceiptResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *DonationReceiptResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := DonationReceiptResponse{}
+	resp.Init(context)
+
+	dps := data.(*DonationReceiptPostStruct)
+	user := *ctx.Auth
+	if len(dps.DonationReceipt.UserID) > 0 && dps.DonationReceipt.UserID != user.UUID {
+		if !ctx.Auth.Can(db.PERMISSION_MANAGE_PAYMENTS, nil) {
+			smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"DonationReceiptResource POST"))
+			return
+		}
+
+		var err error
+		user, err = ctx.LoadUserByUUID(dps.DonationReceipt.UserID)
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusBadRequest,
+				"Invalid user",
+				"DonationReceiptResource POST"))
+			return
+		}
+	}
+
+	receipt, err := receipts.Issue(ctx, user.ID, dps.DonationReceipt.Year)
+	switch err {
+	case nil:
+	case db.ErrNoDonations, db.ErrAlreadyReceipted, db.ErrIncompleteDonor, db.ErrYearNotOver, receipts.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"DonationReceiptResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't issue donation receipt",
+			"DonationReceiptResource POST"))
+		return
+	}
+
+	resp.AddDonationReceipt(receipt)
+	resp.SendWithHeader(http.StatusCreated, response)
+}
@@ -0,0 +1,78 @@
+package donationreceipts
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// DonationReceiptResponse is the common response to 'donation_receipt' requests
+type DonationReceiptResponse struct {
+	smolder.Response
+
+	DonationReceipts []donationReceiptInfoResponse `json:"donation_receipts,omitempty"`
+	donationReceipts []db.DonationReceipt
+}
+
+type donationReceiptInfoResponse struct {
+	ID       int64             `json:"id"`
+	Number   string            `json:"number"`
+	Year     int               `json:"year"`
+	UserID   string            `json:"user_id"`
+	Amount   int64             `json:"amount"`
+	Currency string            `json:"currency"`
+	IssuedAt time.Time         `json:"issued_at"`
+	State    string            `json:"state"`
+	Content  db.ReceiptContent `json:"content"`
+	Document []byte            `json:"document"`
+}
+
+// Init a new response
+func (r *DonationReceiptResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.DonationReceipts = []donationReceiptInfoResponse{}
+}
+
+// AddDonationReceipt adds a donation receipt to the response
+func (r *DonationReceiptResponse) AddDonationReceipt(receipt db.DonationReceipt) {
+	r.donationReceipts = append(r.donationReceipts, receipt)
+	r.DonationReceipts = append(r.DonationReceipts, prepareDonationReceiptResponse(r.Context, receipt))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *DonationReceiptResponse) EmptyResponse() interface{} {
+	if len(r.donationReceipts) == 0 {
+		var out struct {
+			DonationReceipts interface{} `json:"donation_receipts"`
+		}
+		out.DonationReceipts = []donationReceiptInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+// the PDF document gets encoded as base64
+func prepareDonationReceiptResponse(context smolder.APIContext, receipt db.DonationReceipt) donationReceiptInfoResponse {
+	ctx := context.(*db.APIContext)
+	resp := donationReceiptInfoResponse{
+		ID:       receipt.ID,
+		Number:   receipt.Number,
+		Year:     receipt.Year,
+		Amount:   receipt.Amount,
+		Currency: receipt.Currency,
+		IssuedAt: receipt.IssuedAt,
+		State:    receipt.State,
+		Content:  receipt.Content,
+		Document: receipt.Document,
+	}
+
+	if user, err := ctx.LoadUserByID(receipt.UserID); err == nil {
+		resp.UserID = user.UUID
+	}
+
+	return resp
+}
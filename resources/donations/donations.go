@@ -0,0 +1,33 @@
+package donations
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DonationResource is the resource responsible for /donations
+type DonationResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetSupported = &DonationResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *DonationResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "DonationResource"
+	r.TypeName = "donation"
+	r.Endpoint = "donations"
+	r.Doc = "Show the donations of the current user"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Returns returns the model that will be returned
+func (r *DonationResource) Returns() interface{} {
+	return DonationResponse{}
+}
@@ -0,0 +1,89 @@
+package donations
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *DonationResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *DonationResource) GetDoc() string {
+	return "retrieve the donations of the current user, the projects they supported & their totals"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *DonationResource) GetParams() []*restful.Parameter {
+	params := []*restful.Parameter{}
+	params = append(params, restful.QueryParameter("limit", "returns at most n donations per page").DataType("int"))
+	params = append(params, restful.QueryParameter("cursor", "continues with the page after a previous response's next_cursor").DataType("string"))
+
+	return params
+}
+
+// Get sends out items matching the query parameters
+func (r *DonationResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := DonationResponse{}
+	resp.Init(context)
+
+	// the latest donations come first
+	filter := db.PaymentFilter{Sort: "-" + db.PAYMENT_SORT_CREATED_AT}
+	if len(params["limit"]) > 0 {
+		limit, _ := strconv.Atoi(params["limit"][0])
+		if limit > 0 {
+			filter.Limit = limit
+		}
+	}
+	if len(params["cursor"]) > 0 {
+		filter.Cursor = params["cursor"][0]
+	}
+
+	page, err := ctx.Auth.LoadDonations(ctx, filter)
+	if err == db.ErrInvalidCursor {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"DonationResource GET"))
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load donations",
+			"DonationResource GET"))
+		return
+	}
+
+	projects, err := ctx.Auth.LoadSupportedProjects(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load supported projects",
+			"DonationResource GET"))
+		return
+	}
+	totals, err := ctx.Auth.LoadDonationTotals(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load donation totals",
+			"DonationResource GET"))
+		return
+	}
+
+	for _, payment := range page.Payments {
+		resp.AddDonation(payment)
+	}
+	resp.SetSummary(page.Total, page.NextCursor, projects, totals)
+
+	resp.Send(response)
+}
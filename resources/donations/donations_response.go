@@ -0,0 +1,128 @@
+package donations
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// DonationResponse is the common response to 'donation' requests
+type DonationResponse struct {
+	smolder.Response
+
+	Donations  []donationInfoResponse `json:"donations,omitempty"`
+	Projects   []projectInfoResponse  `json:"projects"`
+	Totals     []totalInfoResponse    `json:"totals"`
+	Total      int64                  `json:"total"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	donations  []db.Payment
+}
+
+type donationInfoResponse struct {
+	ID         int64      `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Amount     int64      `json:"amount"`
+	Currency   string     `json:"currency"`
+	Code       *string    `json:"code"`
+	Purpose    string     `json:"purpose"`
+	Source     string     `json:"source"`
+	State      string     `json:"state"`
+	RefundedAt *time.Time `json:"refunded_at,omitempty"`
+}
+
+type projectInfoResponse struct {
+	ID       string `json:"id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type totalInfoResponse struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Init a new response
+func (r *DonationResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Donations = []donationInfoResponse{}
+	r.Projects = []projectInfoResponse{}
+	r.Totals = []totalInfoResponse{}
+}
+
+// AddDonation adds a donation to the response
+func (r *DonationResponse) AddDonation(payment db.Payment) {
+	r.donations = append(r.donations, payment)
+	r.Donations = append(r.Donations, prepareDonationResponse(payment))
+}
+
+// SetSummary adds the number of donations on all pages, the cursor of the
+// next page, the projects the donations supported and their totals to the
+// response
+func (r *DonationResponse) SetSummary(total int64, nextCursor string, projects []db.ProjectDonation, totals []db.DonationTotal) {
+	ctx := r.Context.(*db.APIContext)
+	r.Total = total
+	r.NextCursor = nextCursor
+
+	for _, p := range projects {
+		project, err := ctx.GetProjectByID(p.ProjectID)
+		if err != nil {
+			continue
+		}
+		r.Projects = append(r.Projects, projectInfoResponse{
+			ID:       project.UUID,
+			Slug:     project.Slug,
+			Name:     project.Name,
+			Amount:   p.Amount,
+			Currency: p.Currency,
+		})
+	}
+	for _, t := range totals {
+		r.Totals = append(r.Totals, totalInfoResponse{
+			Amount:   t.Amount,
+			Currency: t.Currency,
+		})
+	}
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *DonationResponse) EmptyResponse() interface{} {
+	if len(r.donations) == 0 {
+		var out struct {
+			Donations interface{} `json:"donations"`
+			Projects  interface{} `json:"projects"`
+			Totals    interface{} `json:"totals"`
+			Total     int64       `json:"total"`
+		}
+		out.Donations = []donationInfoResponse{}
+		out.Projects = r.Projects
+		out.Totals = r.Totals
+		out.Total = r.Total
+		return out
+	}
+	return nil
+}
+
+func prepareDonationResponse(payment db.Payment) donationInfoResponse {
+	resp := donationInfoResponse{
+		ID:         payment.ID,
+		CreatedAt:  payment.CreatedAt,
+		Amount:     payment.Amount,
+		Currency:   payment.Currency,
+		Purpose:    payment.Purpose,
+		Source:     payment.Source,
+		State:      payment.State,
+		RefundedAt: payment.RefundedAt,
+	}
+
+	if payment.Code != "" {
+		resp.Code = &payment.Code
+	}
+
+	return resp
+}
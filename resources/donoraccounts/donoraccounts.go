@@ -0,0 +1,53 @@
+package donoraccounts
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DonorAccountResource is the resource responsible for /donor_accounts
+type DonorAccountResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetSupported  = &DonorAccountResource{}
+	_ smolder.PostSupported = &DonorAccountResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *DonorAccountResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "DonorAccountResource"
+	r.TypeName = "donor_account"
+	r.Endpoint = "donor_accounts"
+	r.Doc = "Claim the bank accounts the current user donates from"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *DonorAccountResource) Reads() interface{} {
+	return &DonorAccountPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *DonorAccountResource) Returns() interface{} {
+	return DonorAccountResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *DonorAccountResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	dps := data.(*DonorAccountPostStruct)
+
+	if len(strings.TrimSpace(dps.DonorAccount.Account)) == 0 {
+		return errors.New("Missing account")
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package donoraccounts
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *DonorAccountResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *DonorAccountResource) GetDoc() string {
+	return "retrieve the bank accounts the current user claimed"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *DonorAccountResource) GetParams() []*restful.Parameter {
+	return nil
+}
+
+// Get sends out items matching the query parameters
+func (r *DonorAccountResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := DonorAccountResponse{}
+	resp.Init(context)
+
+	accounts, err := ctx.Auth.LoadDonorAccounts(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load donor accounts",
+			"DonorAccountResource GET"))
+		return
+	}
+
+	for _, account := range accounts {
+		resp.AddDonorAccount(account)
+	}
+
+	resp.Send(response)
+}
@@ -0,0 +1,60 @@
+package donoraccounts
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DonorAccountPostStruct holds all values of an incoming POST request
+type DonorAccountPostStruct struct {
+	DonorAccount struct {
+		Account string `json:"account"`
+	} `json:"donor_account"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *DonorAccountResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *DonorAccountResource) PostDoc() string {
+	return "claim a bank account. It gets verified by a donation with the returned token in its purpose"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *DonorAccountResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *DonorAccountResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := DonorAccountResponse{}
+	resp.Init(context)
+
+	dps := data.(*DonorAccountPostStruct)
+	account, err := ctx.Auth.ClaimDonorAccount(ctx, dps.DonorAccount.Account)
+	switch err {
+	case nil:
+	case db.ErrInvalidAccount, db.ErrAccountClaimed:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"DonorAccountResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't claim account",
+			"DonorAccountResource POST"))
+		return
+	}
+
+	resp.AddDonorAccount(account)
+	resp.SendWithHeader(http.StatusCreated, response)
+}
@@ -0,0 +1,67 @@
+package donoraccounts
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// DonorAccountResponse is the common response to 'donor_account' requests
+type DonorAccountResponse struct {
+	smolder.Response
+
+	DonorAccounts []donorAccountInfoResponse `json:"donor_accounts,omitempty"`
+	donorAccounts []db.DonorAccount
+}
+
+type donorAccountInfoResponse struct {
+	ID         int64      `json:"id"`
+	Account    string     `json:"account"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// Init a new response
+func (r *DonorAccountResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.DonorAccounts = []donorAccountInfoResponse{}
+}
+
+// AddDonorAccount adds a donor account to the response
+func (r *DonorAccountResponse) AddDonorAccount(account db.DonorAccount) {
+	r.donorAccounts = append(r.donorAccounts, account)
+	r.DonorAccounts = append(r.DonorAccounts, prepareDonorAccountResponse(account))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *DonorAccountResponse) EmptyResponse() interface{} {
+	if len(r.donorAccounts) == 0 {
+		var out struct {
+			DonorAccounts interface{} `json:"donor_accounts"`
+		}
+		out.DonorAccounts = []donorAccountInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareDonorAccountResponse(account db.DonorAccount) donorAccountInfoResponse {
+	resp := donorAccountInfoResponse{
+		ID:         account.ID,
+		Account:    account.Account,
+		CreatedAt:  account.CreatedAt,
+		VerifiedAt: account.VerifiedAt,
+	}
+
+	// the token is only needed until the account has been verified
+	if account.VerifiedAt == nil {
+		resp.Token = account.Token
+	}
+
+	return resp
+}
@@ -0,0 +1,57 @@
+package invoices
+
+import (
+	"errors"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// InvoiceResource is the resource responsible for /invoices
+type InvoiceResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.PostSupported = &InvoiceResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *InvoiceResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "InvoiceResource"
+	r.TypeName = "invoice"
+	r.Endpoint = "invoices"
+	r.Doc = "Create invoices for donations"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *InvoiceResource) Reads() interface{} {
+	return &InvoicePostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *InvoiceResource) Returns() interface{} {
+	return InvoiceResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *InvoiceResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	ips := data.(*InvoicePostStruct)
+
+	if len(ips.Invoice.Source) == 0 {
+		return errors.New("Missing payment source")
+	}
+	if len(ips.Invoice.Code) == 0 {
+		return errors.New("Missing code")
+	}
+	if ips.Invoice.Amount <= 0 {
+		return errors.New("Invalid amount")
+	}
+
+	return nil
+}
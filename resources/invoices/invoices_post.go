@@ -0,0 +1,107 @@
+package invoices
+
+import (
+	"net/http"
+	"strings"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/providers"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// InvoicePostStruct holds all values of an incoming POST request
+type InvoicePostStruct struct {
+	Invoice struct {
+		Source   string `json:"source"`
+		Code     string `json:"code"`
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+		Email    string `json:"email"`
+	} `json:"invoice"`
+}
+
+// PostAuthRequired returns false because donors create invoices without an
+// account
+func (r *InvoiceResource) PostAuthRequired() bool {
+	return false
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *InvoiceResource) PostDoc() string {
+	return "create an invoice for a donation"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *InvoiceResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request. The payment gets stored
+// once the provider reports the invoice as paid
+func (r *InvoiceResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	ips := data.(*InvoicePostStruct)
+
+	if _, err := ctx.LoadCodeByCode(ips.Invoice.Code); err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"Unknown code",
+			"InvoiceResource POST"))
+		return
+	}
+
+	var ip providers.InvoiceProvider
+	provider, err := providers.Get(ctx.Config, ips.Invoice.Source)
+	if err == nil {
+		var ok bool
+		if ip, ok = provider.(providers.InvoiceProvider); !ok {
+			err = providers.ErrUnknownProvider
+		}
+	}
+
+	invoice := providers.Invoice{}
+	if err == nil {
+		currency := ips.Invoice.Currency
+		if len(currency) == 0 {
+			currency = db.DefaultCurrency
+		}
+
+		invoice, err = ip.CreateInvoice(providers.InvoiceRequest{
+			Code:     ips.Invoice.Code,
+			Amount:   ips.Invoice.Amount,
+			Currency: currency,
+			Email:    ips.Invoice.Email,
+			NotificationURL: strings.TrimSuffix(ctx.Config.API.BaseURL, "/") + "/" +
+				ctx.Config.API.PathPrefix + "webhooks/" + ips.Invoice.Source,
+			RedirectURL: ctx.Config.Web.BaseURL,
+		})
+	}
+	switch err {
+	case nil:
+	case providers.ErrUnknownProvider, db.ErrInvalidCurrency:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"InvoiceResource POST"))
+		return
+	case providers.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			err,
+			"InvoiceResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadGateway,
+			"Can't create invoice",
+			"InvoiceResource POST"))
+		return
+	}
+
+	resp := InvoiceResponse{}
+	resp.Init(context)
+	resp.AddInvoice(ips.Invoice.Source, invoice)
+	resp.SendWithHeader(http.StatusCreated, response)
+}
@@ -0,0 +1,63 @@
+package invoices
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/providers"
+
+	"github.com/muesli/smolder"
+)
+
+// InvoiceResponse is the common response to 'invoice' requests
+type InvoiceResponse struct {
+	smolder.Response
+
+	Invoices []invoiceInfoResponse `json:"invoices,omitempty"`
+	invoices []providers.Invoice
+}
+
+type invoiceInfoResponse struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	URL      string `json:"url"`
+	Status   string `json:"status"`
+	Code     string `json:"code"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Init a new response
+func (r *InvoiceResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Invoices = []invoiceInfoResponse{}
+}
+
+// AddInvoice adds an invoice to the response
+func (r *InvoiceResponse) AddInvoice(source string, invoice providers.Invoice) {
+	r.invoices = append(r.invoices, invoice)
+	r.Invoices = append(r.Invoices, prepareInvoiceResponse(r.Context, source, invoice))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *InvoiceResponse) EmptyResponse() interface{} {
+	if len(r.invoices) == 0 {
+		var out struct {
+			Invoices interface{} `json:"invoices"`
+		}
+		out.Invoices = []invoiceInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareInvoiceResponse(context smolder.APIContext, source string, invoice providers.Invoice) invoiceInfoResponse {
+	return invoiceInfoResponse{
+		ID:       invoice.ID,
+		Source:   source,
+		URL:      invoice.URL,
+		Status:   invoice.Status,
+		Code:     invoice.Code,
+		Amount:   invoice.Amount,
+		Currency: invoice.Currency,
+	}
+}
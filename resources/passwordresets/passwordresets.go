@@ -0,0 +1,59 @@
+package passwordresets
+
+import (
+	"errors"
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/badoux/checkmail"
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// PasswordResetResource is the resource responsible for /password_resets
+type PasswordResetResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.PostSupported = &PasswordResetResource{}
+	_ smolder.PutSupported  = &PasswordResetResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *PasswordResetResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "PasswordResetResource"
+	r.TypeName = "password_reset"
+	r.Endpoint = "password_resets"
+	r.Doc = "Reset forgotten passwords"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *PasswordResetResource) Reads() interface{} {
+	return &PasswordResetPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *PasswordResetResource) Returns() interface{} {
+	return PasswordResetResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *PasswordResetResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	prs := data.(*PasswordResetPostStruct)
+
+	if request.Request.Method == http.MethodPut {
+		return db.ValidPassword(prs.PasswordReset.Password)
+	}
+
+	if err := checkmail.ValidateFormat(prs.PasswordReset.Email); err != nil {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
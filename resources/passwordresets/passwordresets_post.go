@@ -0,0 +1,48 @@
+package passwordresets
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// PasswordResetPostStruct holds all values of an incoming POST request
+type PasswordResetPostStruct struct {
+	PasswordReset struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	} `json:"password_reset"`
+}
+
+// PostAuthRequired returns false because users who forgot their password
+// can't log in
+func (r *PasswordResetResource) PostAuthRequired() bool {
+	return false
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *PasswordResetResource) PostDoc() string {
+	return "send a password reset email"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *PasswordResetResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request. To not reveal which
+// email addresses are registered, it responds the same way for unknown
+// addresses & failed mails
+func (r *PasswordResetResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := PasswordResetResponse{}
+	resp.Init(context)
+
+	prs := data.(*PasswordResetPostStruct)
+	ctx.RequestUserToken(prs.PasswordReset.Email, db.TOKEN_PASSWORD_RESET)
+
+	resp.SendWithHeader(http.StatusAccepted, response)
+}
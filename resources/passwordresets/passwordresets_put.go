@@ -0,0 +1,53 @@
+package passwordresets
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// PutAuthRequired returns false because the password reset token authenticates
+// the request
+func (r *PasswordResetResource) PutAuthRequired() bool {
+	return false
+}
+
+// PutDoc returns the description of this API endpoint
+func (r *PasswordResetResource) PutDoc() string {
+	return "set a new password with a password reset token"
+}
+
+// PutParams returns the parameters supported by this API endpoint
+func (r *PasswordResetResource) PutParams() []*restful.Parameter {
+	return nil
+}
+
+// Put processes an incoming PUT (update) request
+func (r *PasswordResetResource) Put(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := PasswordResetResponse{}
+	resp.Init(context)
+
+	prs := data.(*PasswordResetPostStruct)
+	user, err := ctx.ResetPassword(request.PathParameter("password_reset-id"), prs.PasswordReset.Password)
+	if err == db.ErrInvalidUserToken {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PasswordResetResource PUT"))
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't reset password",
+			"PasswordResetResource PUT"))
+		return
+	}
+
+	resp.AddUser(&user)
+	resp.Send(response)
+}
@@ -0,0 +1,53 @@
+package passwordresets
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// PasswordResetResponse is the common response to 'password_reset' requests
+type PasswordResetResponse struct {
+	smolder.Response
+
+	PasswordResets []passwordResetInfoResponse `json:"password_resets,omitempty"`
+	users          []db.User
+}
+
+type passwordResetInfoResponse struct {
+	UserID string `json:"user_id"`
+	Reset  bool   `json:"reset"`
+}
+
+// Init a new response
+func (r *PasswordResetResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.PasswordResets = []passwordResetInfoResponse{}
+}
+
+// AddUser adds a user whose password has been reset to the response
+func (r *PasswordResetResponse) AddUser(user *db.User) {
+	r.users = append(r.users, *user)
+	r.PasswordResets = append(r.PasswordResets, preparePasswordResetResponse(r.Context, user))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *PasswordResetResponse) EmptyResponse() interface{} {
+	if len(r.users) == 0 {
+		var out struct {
+			PasswordResets interface{} `json:"password_resets"`
+		}
+		out.PasswordResets = []passwordResetInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func preparePasswordResetResponse(context smolder.APIContext, user *db.User) passwordResetInfoResponse {
+	return passwordResetInfoResponse{
+		UserID: user.UUID,
+		Reset:  true,
+	}
+}
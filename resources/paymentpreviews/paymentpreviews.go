@@ -0,0 +1,33 @@
+package paymentpreviews
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// PaymentPreviewResource is the resource responsible for /payment_previews
+type PaymentPreviewResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetIDSupported = &PaymentPreviewResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *PaymentPreviewResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "PaymentPreviewResource"
+	r.TypeName = "payment_preview"
+	r.Endpoint = "payment_previews"
+	r.Doc = "Preview how payments would be distributed"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Returns returns the model that will be returned
+func (r *PaymentPreviewResource) Returns() interface{} {
+	return PaymentPreviewResponse{}
+}
@@ -0,0 +1,62 @@
+package paymentpreviews
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetByIDsAuthRequired returns true because all requests need authentication
+func (r *PaymentPreviewResource) GetByIDsAuthRequired() bool {
+	return true
+}
+
+// GetByIDs sends out what processing the payments with a set of IDs would
+// book, without booking them
+func (r *PaymentPreviewResource) GetByIDs(context smolder.APIContext, request *restful.Request, response *restful.Response, ids []string) {
+	ctx := context.(*db.APIContext)
+	resp := PaymentPreviewResponse{}
+	resp.Init(context)
+
+	_, err := ctx.Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"PaymentPreviewResource GET"))
+		return
+	}
+
+	for _, id := range ids {
+		iid, _ := strconv.ParseInt(id, 10, 0)
+		payment, err := ctx.LoadPaymentByID(iid)
+		if err != nil {
+			r.NotFound(request, response)
+			return
+		}
+
+		entries, err := payment.Preview(ctx, ctx.Config.Processing.DonationCutBudget)
+		if err == db.ErrInvalidTransition {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusBadRequest,
+				"Only matched or approved payments can be previewed",
+				"PaymentPreviewResource GET"))
+			return
+		}
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusInternalServerError,
+				"Can't preview payment",
+				"PaymentPreviewResource GET"))
+			return
+		}
+
+		resp.AddPaymentPreview(payment, entries)
+	}
+
+	resp.Send(response)
+}
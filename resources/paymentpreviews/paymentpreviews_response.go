@@ -0,0 +1,89 @@
+package paymentpreviews
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// PaymentPreviewResponse is the common response to 'payment_preview' requests
+type PaymentPreviewResponse struct {
+	smolder.Response
+
+	PaymentPreviews []paymentPreviewInfoResponse `json:"payment_previews,omitempty"`
+	payments        []db.Payment
+}
+
+type paymentPreviewInfoResponse struct {
+	ID       int64           `json:"id"`
+	Amount   int64           `json:"amount"`
+	Currency string          `json:"currency"`
+	Code     string          `json:"code"`
+	Entries  []entryResponse `json:"entries"`
+}
+
+type entryResponse struct {
+	// Kind is one of payment, transfer, cut & remainder
+	Kind         string  `json:"kind"`
+	BudgetID     string  `json:"budget_id"`
+	FromBudgetID *string `json:"from_budget_id"`
+	Amount       int64   `json:"amount"`
+	Currency     string  `json:"currency"`
+}
+
+// Init a new response
+func (r *PaymentPreviewResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.PaymentPreviews = []paymentPreviewInfoResponse{}
+}
+
+// AddPaymentPreview adds the entries a payment would be booked with to the
+// response
+func (r *PaymentPreviewResponse) AddPaymentPreview(payment db.Payment, entries []db.PreviewEntry) {
+	r.payments = append(r.payments, payment)
+	r.PaymentPreviews = append(r.PaymentPreviews, preparePaymentPreviewResponse(r.Context, payment, entries))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *PaymentPreviewResponse) EmptyResponse() interface{} {
+	if len(r.payments) == 0 {
+		var out struct {
+			PaymentPreviews interface{} `json:"payment_previews"`
+		}
+		out.PaymentPreviews = []paymentPreviewInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func preparePaymentPreviewResponse(context smolder.APIContext, payment db.Payment, entries []db.PreviewEntry) paymentPreviewInfoResponse {
+	ctx := context.(*db.APIContext)
+	resp := paymentPreviewInfoResponse{
+		ID:       payment.ID,
+		Amount:   payment.Amount,
+		Currency: payment.Currency,
+		Code:     payment.Code,
+		Entries:  []entryResponse{},
+	}
+
+	for _, e := range entries {
+		er := entryResponse{
+			Kind:     e.Kind,
+			Amount:   e.Amount,
+			Currency: e.Currency,
+		}
+
+		budget, _ := ctx.LoadBudgetByID(e.BudgetID)
+		er.BudgetID = budget.UUID
+		if e.FromBudgetID != nil {
+			fromBudget, _ := ctx.LoadBudgetByID(*e.FromBudgetID)
+			er.FromBudgetID = &fromBudget.UUID
+		}
+
+		resp.Entries = append(resp.Entries, er)
+	}
+
+	return resp
+}
@@ -2,6 +2,7 @@ package payments
 
 import (
 	"errors"
+	"net/http"
 
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
@@ -45,8 +46,9 @@ func (r *PaymentResource) Returns() interface{} {
 func (r *PaymentResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
 	ups := data.(*PaymentPostStruct)
 
-	if ups.Payment.Amount == 0 {
-		return errors.New("Invalid payment amount")
+	// the amount of a payment gets checked by its provider
+	if request.Request.Method == http.MethodPost && len(ups.Payment.Source) == 0 {
+		return errors.New("Missing payment source")
 	}
 
 	return nil
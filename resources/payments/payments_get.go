@@ -1,9 +1,13 @@
 package payments
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gitlab.techcultivation.org/sangha/sangha/db"
 
@@ -29,9 +33,21 @@ func (r *PaymentResource) GetDoc() string {
 // GetParams returns the parameters supported by this API endpoint
 func (r *PaymentResource) GetParams() []*restful.Parameter {
 	params := []*restful.Parameter{}
-	params = append(params, restful.QueryParameter("limit", "returns at most n payments").DataType("int"))
+	params = append(params, restful.QueryParameter("limit", "returns at most n payments per page").DataType("int"))
+	params = append(params, restful.QueryParameter("cursor", "continues with the page after a previous response's next_cursor").DataType("string"))
+	params = append(params, restful.QueryParameter("sort", "sorts by 'created_at' (default) or 'amount', prefixed with '-' for descending order").DataType("string"))
+	params = append(params, restful.QueryParameter("budget", "returns payments for a specific budget only").DataType("string"))
 	params = append(params, restful.QueryParameter("direction", "returns only 'incoming' or 'outgoing' payments").DataType("string"))
 	params = append(params, restful.QueryParameter("donor", "returns payments for a specific donor only").DataType("string"))
+	params = append(params, restful.QueryParameter("pending", "returns only payments awaiting their approval, or none of them. Defaults to true without budget, donor or state").DataType("bool"))
+	params = append(params, restful.QueryParameter("state", "returns payments in a specific state only").DataType("string"))
+	params = append(params, restful.QueryParameter("source", "returns payments from a specific source only").DataType("string"))
+	params = append(params, restful.QueryParameter("currency", "returns payments in a specific currency only").DataType("string"))
+	params = append(params, restful.QueryParameter("code", "returns payments with a specific code only").DataType("string"))
+	params = append(params, restful.QueryParameter("from", "returns payments created on or after a date").DataType("string"))
+	params = append(params, restful.QueryParameter("to", "returns payments created before a date").DataType("string"))
+	params = append(params, restful.QueryParameter("min_amount", "returns payments of at least this amount").DataType("int"))
+	params = append(params, restful.QueryParameter("max_amount", "returns payments of at most this amount").DataType("int"))
 
 	return params
 }
@@ -41,11 +57,11 @@ func (r *PaymentResource) GetByIDs(context smolder.APIContext, request *restful.
 	resp := PaymentResponse{}
 	resp.Init(context)
 
-	auth, err := context.Authentication(request)
-	if err != nil || auth == nil || auth.(db.User).ID != 1 {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_VIEW_PAYMENTS, nil)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"PaymentResource GET"))
 		return
 	}
@@ -70,73 +86,152 @@ func (r *PaymentResource) Get(context smolder.APIContext, request *restful.Reque
 	resp := PaymentResponse{}
 	resp.Init(context)
 
-	auth, err := context.Authentication(request)
-	if err != nil || auth == nil || auth.(db.User).ID != 1 {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_VIEW_PAYMENTS, nil)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"PaymentResource GET"))
 		return
 	}
 
-	var payments []db.Payment
+	filter, err := paymentFilter(ctx, params)
+	if err == sql.ErrNoRows {
+		r.NotFound(request, response)
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentsResource GET"))
+		return
+	}
 
-	if len(params["budget"]) > 0 {
-		var budget db.Budget
-		budget, err = ctx.LoadBudgetByUUID(params["budget"][0])
-		if err != nil {
-			r.NotFound(request, response)
-			return
+	page, err := ctx.LoadPaymentPage(filter)
+	if err == db.ErrInvalidCursor || err == db.ErrInvalidSort {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentsResource GET"))
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load payments",
+			"PaymentsResource GET"))
+		return
+	}
+
+	// payments awaiting their approval come with the codes their purpose
+	// most likely refers to
+	var matcher *db.CodeMatcher
+	for _, payment := range page.Payments {
+		if payment.State == db.PAYMENT_STATE_RECEIVED {
+			if matcher == nil {
+				if matcher, err = ctx.NewCodeMatcher(); err != nil {
+					smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+						http.StatusInternalServerError,
+						"Can't load payments",
+						"PaymentsResource GET"))
+					return
+				}
+			}
+			resp.AddPaymentWithMatches(payment, matcher.Match(payment.Purpose))
+			continue
 		}
+		resp.AddPayment(payment)
+	}
+	resp.SetPage(page.Total, page.NextCursor)
 
-		payments, err = budget.LoadPayments(ctx)
+	resp.Send(response)
+}
+
+// paymentFilter turns the query parameters into a payment filter. Without a
+// budget, donor or state only pending payments are returned
+func paymentFilter(ctx *db.APIContext, params map[string][]string) (db.PaymentFilter, error) {
+	filter := db.PaymentFilter{}
+	param := func(name string) string {
+		if len(params[name]) > 0 {
+			return params[name][0]
+		}
+		return ""
+	}
+
+	if v := param("budget"); len(v) > 0 {
+		budget, err := ctx.LoadBudgetByUUID(v)
 		if err != nil {
-			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-				http.StatusInternalServerError,
-				"Can't load payments",
-				"PaymentsResource GET"))
-			return
+			return filter, sql.ErrNoRows
 		}
-	} else if len(params["donor"]) > 0 {
-		payments, err = ctx.LoadPaymentsForDonor(params["donor"][0])
+		filter.BudgetID = &budget.ID
+	}
+	filter.Donor = param("donor")
+
+	switch strings.ToLower(param("direction")) {
+	case "incoming":
+		filter.Direction = db.TRANSACTION_INCOMING
+	case "outgoing":
+		filter.Direction = db.TRANSACTION_OUTGOING
+	}
+
+	if v := param("pending"); len(v) > 0 {
+		pending, err := strconv.ParseBool(v)
 		if err != nil {
-			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-				http.StatusInternalServerError,
-				"Can't load payments",
-				"PaymentsResource GET"))
-			return
+			return filter, errors.New("Invalid pending filter")
 		}
-	} else {
-		direction := db.TRANSACTION_ALL
-		if len(params["direction"]) > 0 {
-			if strings.ToLower(params["direction"][0]) == "incoming" {
-				direction = db.TRANSACTION_INCOMING
-			}
-			if strings.ToLower(params["direction"][0]) == "outgoing" {
-				direction = db.TRANSACTION_OUTGOING
+		filter.Pending = &pending
+	}
+	if v := param("state"); len(v) > 0 {
+		if !db.ValidPaymentState(v) {
+			return filter, errors.New("Invalid payment state")
+		}
+		filter.State = v
+	}
+	if filter.BudgetID == nil && len(filter.Donor) == 0 && filter.Pending == nil && len(filter.State) == 0 {
+		pending := true
+		filter.Pending = &pending
+	}
+
+	filter.Source = param("source")
+	filter.Currency = param("currency")
+	filter.Code = param("code")
+
+	for name, t := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := param(name)
+		if len(v) == 0 {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if ts, err = time.Parse("2006-01-02", v); err != nil {
+				return filter, fmt.Errorf("Invalid date: %s", v)
 			}
 		}
-		payments, err = ctx.LoadPendingPayments(direction)
+		ts = ts.UTC()
+		*t = &ts
+	}
+	for name, a := range map[string]**int64{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		v := param(name)
+		if len(v) == 0 {
+			continue
+		}
+		amount, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-				http.StatusInternalServerError,
-				"Can't load payments",
-				"PaymentsResource GET"))
-			return
+			return filter, fmt.Errorf("Invalid amount: %s", v)
 		}
+		*a = &amount
 	}
 
-	n := 0
-	if len(params["limit"]) > 0 {
-		limit, _ := strconv.ParseInt(params["limit"][0], 10, 0)
-		n = len(payments) - int(limit)
-		if n < 0 {
-			n = 0
+	if v := param("limit"); len(v) > 0 {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, errors.New("Invalid limit")
 		}
+		filter.Limit = limit
 	}
-	for _, payment := range payments[n:] {
-		resp.AddPayment(payment)
-	}
+	filter.Sort = param("sort")
+	filter.Cursor = param("cursor")
 
-	resp.Send(response)
+	return filter, nil
 }
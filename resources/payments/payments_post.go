@@ -1,14 +1,10 @@
 package payments
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"time"
 
 	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/providers"
 
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
@@ -20,12 +16,20 @@ type PaymentPostStruct struct {
 		Source   string `json:"source"`
 		SourceID string `json:"source_id"`
 		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
 		Code     string `json:"code"`
-		Pending  bool   `json:"pending"`
+
+		// State moves a payment into a new state when updating it, Refund
+		// reverses it. Override lets admins refund it even if a budget lacks
+		// the funds
+		State    string `json:"state"`
+		Refund   string `json:"refund"`
+		Override bool   `json:"override"`
 	} `json:"payment"`
 }
 
-// PostAuthRequired returns true because all requests need authentication
+// PostAuthRequired returns false because payment gateways report payments
+// without authentication. Manually registered payments require permission
 func (r *PaymentResource) PostAuthRequired() bool {
 	return false
 }
@@ -37,121 +41,115 @@ func (r *PaymentResource) PostDoc() string {
 
 // PostParams returns the parameters supported by this API endpoint
 func (r *PaymentResource) PostParams() []*restful.Parameter {
-	return nil
+	params := []*restful.Parameter{}
+	params = append(params, restful.HeaderParameter("Idempotency-Key", "retrying a request with the same key returns the payment stored by the first request").
+		DataType("string").
+		AllowMultiple(false))
+
+	return params
 }
 
-// Post processes an incoming POST (create) request
+// Post processes an incoming POST (create) request. Payments are stored once
+// per Idempotency-Key and provider transaction ID; repeated requests respond
+// with the stored payment
 func (r *PaymentResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
-	ups := data.(*PaymentPostStruct)
-	log.Printf("Got payment request: %+v\n", ups)
-
 	ctx := context.(*db.APIContext)
-	payments := struct {
-		Payments []struct {
-			UserID              int64     `json:"user_id"`
-			Amount              int64     `json:"amount"`
-			Currency            string    `json:"currency"`
-			Code                string    `json:"code"`
-			Description         string    `json:"description"`
-			Source              string    `json:"source"`
-			SourceID            string    `json:"source_id"`
-			SourcePayerID       string    `json:"source_payer_id"`
-			SourceTransactionID string    `json:"source_transaction_id"`
-			CreatedAt           time.Time `json:"created_at"`
-		} `json:"payments"`
-	}{}
-	payment := db.Payment{}
-
-	switch ups.Payment.Source {
-	case "bank_transfer":
-		payment.Amount = ups.Payment.Amount
-		payment.Code = ups.Payment.Code
-		payment.Source = ups.Payment.Source
-
-	case "paypal":
-		resp, err := http.Get(ctx.Config.Connections.PayPal + "/" + ups.Payment.SourceID)
-		if err != nil {
-			panic(err)
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		fmt.Println(string(body))
-
-		if resp.StatusCode != http.StatusOK {
-			smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
-				http.StatusBadRequest,
-				"Unknown payment ID",
-				"PaymentResource POST"))
-			return
-		}
+	ups := data.(*PaymentPostStruct)
+	resp := PaymentResponse{}
+	resp.Init(context)
 
-		err = json.Unmarshal(body, &payments)
+	provider, err := providers.Get(ctx.Config, ups.Payment.Source)
+	if err == nil && providers.IsManual(provider) {
+		_, err = ctx.Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
 		if err != nil {
 			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-				http.StatusInternalServerError,
-				"Error decoding payment response",
-				"PaymentResource POST"))
-			return
-		}
-		fmt.Printf("Payment: %+v\n", payments.Payments[0])
-		// payment.RemoteAccount = payments.Payments[0].UserID
-		payment.Amount = payments.Payments[0].Amount
-		payment.Currency = payments.Payments[0].Currency
-		payment.Code = payments.Payments[0].Code
-		payment.Purpose = payments.Payments[0].Description
-		payment.Source = payments.Payments[0].Source
-		payment.RemoteBankID = payments.Payments[0].SourceID
-		payment.RemoteAccount = payments.Payments[0].SourcePayerID
-		payment.RemoteTransactionID = payments.Payments[0].SourceTransactionID
-		payment.CreatedAt = payments.Payments[0].CreatedAt
-
-	case "stripe":
-		resp, err := http.Get(ctx.Config.Connections.Stripe + "/" + ups.Payment.SourceID)
-		if err != nil {
-			panic(err)
-		}
-		defer resp.Body.Close()
-		body, err := ioutil.ReadAll(resp.Body)
-		fmt.Println(string(body))
-
-		if resp.StatusCode != http.StatusOK {
-			smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
-				http.StatusBadRequest,
-				"Unknown payment ID",
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
 				"PaymentResource POST"))
 			return
 		}
+	}
 
-		err = json.Unmarshal(body, &payments)
-		if err != nil {
-			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-				http.StatusInternalServerError,
-				"Error decoding payment response",
-				"PaymentResource POST"))
+	key := request.HeaderParameter("Idempotency-Key")
+	if err == nil {
+		if stored, lerr := ctx.LoadPaymentByIdempotencyKey(key); lerr == nil {
+			r.sendStoredPayment(&resp, stored, provider, ups, request, response)
 			return
 		}
-		fmt.Printf("Payment: %+v\n", payments.Payments[0])
-		// payment.RemoteAccount = payments.Payments[0].UserID
-		payment.Amount = payments.Payments[0].Amount
-		payment.Currency = payments.Payments[0].Currency
-		payment.Code = payments.Payments[0].Code
-		payment.Purpose = payments.Payments[0].Description
-		payment.Source = payments.Payments[0].Source
-		payment.RemoteBankID = payments.Payments[0].SourceID
-		payment.RemoteAccount = payments.Payments[0].SourcePayerID
-		payment.RemoteTransactionID = payments.Payments[0].SourceTransactionID
-		payment.CreatedAt = payments.Payments[0].CreatedAt
+	}
 
+	payment := db.Payment{}
+	if err == nil {
+		payment, err = providers.Payment(provider, providers.Request{
+			SourceID: ups.Payment.SourceID,
+			Amount:   ups.Payment.Amount,
+			Currency: ups.Payment.Currency,
+			Code:     ups.Payment.Code,
+		})
+	}
+	switch err {
+	case nil:
+	case providers.ErrUnknownProvider, providers.ErrUnknownPayment, providers.ErrInvalidPayment, db.ErrInvalidCurrency:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentResource POST"))
+		return
+	case providers.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			err,
+			"PaymentResource POST"))
+		return
 	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadGateway,
+			"Can't retrieve payment from payment source",
+			"PaymentResource POST"))
+		return
+	}
+
+	if ctx.Config.Processing.ReceivingBudget == 0 {
 		smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
-			http.StatusBadRequest,
-			"Unknown payment source",
+			http.StatusServiceUnavailable,
+			"No receiving budget configured",
+			"PaymentResource POST"))
+		return
+	}
+	payment.BudgetID = ctx.Config.Processing.ReceivingBudget
+	payment.IdempotencyKey = key
+
+	err = payment.Save(ctx, nil)
+	if err == db.ErrPaymentExists {
+		r.sendStoredPayment(&resp, payment, provider, ups, request, response)
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't store payment",
+			"PaymentResource POST"))
+		return
+	}
+
+	resp.AddPayment(payment)
+	resp.SendWithHeader(http.StatusCreated, response)
+}
+
+// sendStoredPayment responds with a payment that had been stored by an
+// earlier request. Payments only get replayed to callers who could have
+// registered them: manual payments to authorized users, which Post already
+// checked, and provider payments to callers who know the provider's ID
+func (r *PaymentResource) sendStoredPayment(resp *PaymentResponse, payment db.Payment, provider providers.PaymentProvider, ups *PaymentPostStruct, request *restful.Request, response *restful.Response) {
+	if payment.Source != ups.Payment.Source ||
+		(!providers.IsManual(provider) && (len(ups.Payment.SourceID) == 0 || payment.RemoteBankID != ups.Payment.SourceID)) {
+		smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
+			http.StatusUnprocessableEntity,
+			"Idempotency-Key has already been used for another payment",
 			"PaymentResource POST"))
 		return
 	}
 
-	resp := PaymentResponse{}
-	resp.Init(context)
 	resp.AddPayment(payment)
 	resp.Send(response)
 }
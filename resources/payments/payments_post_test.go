@@ -0,0 +1,39 @@
+package payments
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+)
+
+func TestPostRefusesUnauthenticatedReplay(t *testing.T) {
+	// the context has no database: looking up the Idempotency-Key before
+	// authorizing the request would panic instead of refusing it
+	ctx := &db.APIContext{}
+	ctx.Config.Processing.ReceivingBudget = 1
+
+	ups := &PaymentPostStruct{}
+	ups.Payment.Source = "bank_transfer"
+	ups.Payment.Amount = 100
+
+	hr := httptest.NewRequest(http.MethodPost, "/v1/payments", strings.NewReader("{}"))
+	hr.Header.Set("Idempotency-Key", "known-key")
+	rec := httptest.NewRecorder()
+	response := restful.NewResponse(rec)
+	response.SetRequestAccepts(restful.MIME_JSON)
+
+	r := &PaymentResource{}
+	r.Post(ctx, ups, restful.NewRequest(hr), response)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnauthorized, rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "payments") {
+		t.Errorf("expected no payment in the response, got %s", rec.Body.String())
+	}
+}
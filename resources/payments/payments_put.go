@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/resources/approvalrequests"
 
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
@@ -22,7 +23,7 @@ func (r *PaymentResource) PutAuthRequired() bool {
 
 // PutDoc returns the description of this API endpoint
 func (r *PaymentResource) PutDoc() string {
-	return "update an existing payment"
+	return "update or refund an existing payment"
 }
 
 // PutParams returns the parameters supported by this API endpoint
@@ -32,11 +33,11 @@ func (r *PaymentResource) PutParams() []*restful.Parameter {
 
 // Put processes an incoming PUT (update) request
 func (r *PaymentResource) Put(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
-	auth, err := context.Authentication(request)
-	if err != nil || (auth.(db.User).ID != 1) { // && auth.(db.User).ID != project.UserID) {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"PaymentResource PUT"))
 		return
 	}
@@ -54,11 +55,27 @@ func (r *PaymentResource) Put(context smolder.APIContext, data interface{}, requ
 	}
 
 	pps := data.(*PaymentPostStruct)
+	if len(pps.Payment.Refund) > 0 {
+		r.refund(ctx, &payment, pps, request, response)
+		return
+	}
+
 	payment.Code = pps.Payment.Code
-	payment.Pending = pps.Payment.Pending
+	payment.State = pps.Payment.State
 
 	err = payment.Update(ctx)
-	if err != nil {
+	switch err {
+	case nil:
+	case db.ErrApprovalRequired:
+		r.requestApproval(ctx, &payment, request, response)
+		return
+	case db.ErrInvalidTransition, db.ErrPaymentLocked, db.ErrPaymentRefunded:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentResource PUT"))
+		return
+	default:
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusInternalServerError,
 			"Can't update payment",
@@ -69,3 +86,62 @@ func (r *PaymentResource) Put(context smolder.APIContext, data interface{}, requ
 	resp.AddPayment(payment)
 	resp.Send(response)
 }
+
+// refund reverses a payment. Only admins may override missing funds
+func (r *PaymentResource) refund(ctx *db.APIContext, payment *db.Payment, pps *PaymentPostStruct, request *restful.Request, response *restful.Response) {
+	if pps.Payment.Override && !ctx.Auth.IsAdmin() {
+		smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Only admins can override missing funds",
+			"PaymentResource PUT"))
+		return
+	}
+
+	err := payment.Refund(ctx, pps.Payment.Refund, pps.Payment.Override)
+	switch err {
+	case nil:
+	case db.ErrInvalidRefundKind, db.ErrPaymentRefunded, db.ErrInsufficientFunds:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentResource PUT"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't refund payment",
+			"PaymentResource PUT"))
+		return
+	}
+
+	resp := PaymentResponse{}
+	resp.Init(ctx)
+	resp.AddPayment(*payment)
+	resp.Send(response)
+}
+
+// requestApproval records the user's approval of an outgoing payment, which
+// gets queued for processing once enough users have approved it
+func (r *PaymentResource) requestApproval(ctx *db.APIContext, payment *db.Payment, request *restful.Request, response *restful.Response) {
+	ar, err := ctx.RequestPaymentApproval(payment)
+	switch err {
+	case nil:
+	case db.ErrInvalidTransition, db.ErrApprovalDecided, db.ErrAlreadyApproved:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"PaymentResource PUT"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't request approval for payment",
+			"PaymentResource PUT"))
+		return
+	}
+
+	resp := approvalrequests.ApprovalRequestResponse{}
+	resp.Init(ctx)
+	resp.AddApprovalRequest(ar)
+	resp.SendWithHeader(http.StatusAccepted, response)
+}
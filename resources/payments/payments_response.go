@@ -13,24 +13,47 @@ import (
 type PaymentResponse struct {
 	smolder.Response
 
-	Payments []paymentInfoResponse `json:"payments,omitempty"`
-	payments []db.Payment
+	Payments   []paymentInfoResponse `json:"payments,omitempty"`
+	Total      *int64                `json:"total,omitempty"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+	payments   []db.Payment
 }
 
 type paymentInfoResponse struct {
-	ID                  int64     `json:"id"`
-	BudgetID            string    `json:"budget_id"`
-	CreatedAt           time.Time `json:"created_at"`
-	Amount              int64     `json:"amount"`
-	Currency            string    `json:"currency"`
-	Code                *string   `json:"code"`
-	Purpose             string    `json:"purpose"`
-	RemoteAccount       string    `json:"remote_account"`
-	RemoteBankID        string    `json:"remote_bank_id"`
-	RemoteTransactionID string    `json:"remote_transaction_id"`
-	RemoteName          string    `json:"remote_name"`
-	Source              string    `json:"source"`
-	Pending             bool      `json:"pending"`
+	ID                  int64                           `json:"id"`
+	BudgetID            string                          `json:"budget_id"`
+	CreatedAt           time.Time                       `json:"created_at"`
+	Amount              int64                           `json:"amount"`
+	Currency            string                          `json:"currency"`
+	Code                *string                         `json:"code"`
+	Purpose             string                          `json:"purpose"`
+	RemoteAccount       string                          `json:"remote_account"`
+	RemoteBankID        string                          `json:"remote_bank_id"`
+	RemoteTransactionID string                          `json:"remote_transaction_id"`
+	RemoteName          string                          `json:"remote_name"`
+	RemoteEmail         string                          `json:"remote_email"`
+	RemoteAmount        string                          `json:"remote_amount,omitempty"`
+	RemoteCurrency      string                          `json:"remote_currency,omitempty"`
+	Source              string                          `json:"source"`
+	State               string                          `json:"state"`
+	Transitions         []paymentTransitionInfoResponse `json:"transitions"`
+	RefundedAt          *time.Time                      `json:"refunded_at,omitempty"`
+	RefundKind          string                          `json:"refund_kind,omitempty"`
+	SepaExportID        *int64                          `json:"sepa_export_id,omitempty"`
+	UserID              string                          `json:"user_id,omitempty"`
+	Matches             []codeMatchInfoResponse         `json:"matches,omitempty"`
+}
+
+type codeMatchInfoResponse struct {
+	Code       string  `json:"code"`
+	Confidence float64 `json:"confidence"`
+}
+
+type paymentTransitionInfoResponse struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	UserID    string    `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Init a new response
@@ -47,13 +70,37 @@ func (r *PaymentResponse) AddPayment(payment db.Payment) {
 	r.Payments = append(r.Payments, preparePaymentResponse(r.Context, payment))
 }
 
+// AddPaymentWithMatches adds a payment to the response, together with the
+// codes proposed for it
+func (r *PaymentResponse) AddPaymentWithMatches(payment db.Payment, matches []db.CodeMatch) {
+	r.AddPayment(payment)
+
+	resp := &r.Payments[len(r.Payments)-1]
+	resp.Matches = []codeMatchInfoResponse{}
+	for _, m := range matches {
+		resp.Matches = append(resp.Matches, codeMatchInfoResponse{
+			Code:       m.Code,
+			Confidence: m.Confidence,
+		})
+	}
+}
+
+// SetPage adds the number of payments on all pages and the cursor of the
+// next page to the response
+func (r *PaymentResponse) SetPage(total int64, nextCursor string) {
+	r.Total = &total
+	r.NextCursor = nextCursor
+}
+
 // EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
 func (r *PaymentResponse) EmptyResponse() interface{} {
 	if len(r.payments) == 0 {
 		var out struct {
 			Payments interface{} `json:"payments"`
+			Total    *int64      `json:"total,omitempty"`
 		}
 		out.Payments = []paymentInfoResponse{}
+		out.Total = r.Total
 		return out
 	}
 	return nil
@@ -70,14 +117,46 @@ func preparePaymentResponse(context smolder.APIContext, payment db.Payment) paym
 		RemoteBankID:        payment.RemoteBankID,
 		RemoteTransactionID: payment.RemoteTransactionID,
 		RemoteName:          payment.RemoteName,
+		RemoteEmail:         payment.RemoteEmail,
+		RemoteAmount:        payment.RemoteAmount,
+		RemoteCurrency:      payment.RemoteCurrency,
 		Source:              payment.Source,
-		Pending:             payment.Pending,
+		State:               payment.State,
+		Transitions:         []paymentTransitionInfoResponse{},
+		RefundedAt:          payment.RefundedAt,
+		RefundKind:          payment.RefundKind,
+		SepaExportID:        payment.SepaExportID,
 	}
 
 	if payment.Code != "" {
 		resp.Code = &payment.Code
 	}
 
+	ctx := context.(*db.APIContext)
+	if payment.UserID != nil {
+		if user, err := ctx.LoadUserByID(*payment.UserID); err == nil {
+			resp.UserID = user.UUID
+		}
+	}
+
+	transitions, err := payment.LoadTransitions(ctx)
+	if err != nil {
+		panic(err)
+	}
+	for _, t := range transitions {
+		tr := paymentTransitionInfoResponse{
+			From:      t.From,
+			To:        t.To,
+			CreatedAt: t.CreatedAt,
+		}
+		if t.UserID != nil {
+			if user, err := ctx.LoadUserByID(*t.UserID); err == nil {
+				tr.UserID = user.UUID
+			}
+		}
+		resp.Transitions = append(resp.Transitions, tr)
+	}
+
 	c, err := context.(*db.APIContext).LoadCodeByCode(payment.Code)
 	if err == nil {
 		bid, err := strconv.ParseInt(c.BudgetIDs[0], 10, 64)
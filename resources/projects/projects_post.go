@@ -44,11 +44,11 @@ func (r *ProjectResource) PostParams() []*restful.Parameter {
 
 // Post processes an incoming POST (create) request
 func (r *ProjectResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
-	auth, err := context.Authentication(request)
-	if err != nil || auth.(db.User).ID != 1 {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_PROJECTS, nil)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"ProjectResource POST"))
 		return
 	}
@@ -40,11 +40,11 @@ func (r *ProjectResource) Put(context smolder.APIContext, data interface{}, requ
 		return
 	}
 
-	auth, err := context.Authentication(request)
-	if err != nil || (auth.(db.User).ID != 1) { // && auth.(db.User).ID != project.UserID) {
+	_, err = context.(*db.APIContext).Authorize(request, db.PERMISSION_EDIT_PROJECT, &project.ID)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"ProjectResource PUT"))
 		return
 	}
@@ -0,0 +1,65 @@
+package rates
+
+import (
+	"errors"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// RateResource is the resource responsible for /rates
+type RateResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetSupported  = &RateResource{}
+	_ smolder.PostSupported = &RateResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *RateResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "RateResource"
+	r.TypeName = "rate"
+	r.Endpoint = "rates"
+	r.Doc = "Manage exchange rates"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *RateResource) Reads() interface{} {
+	return &RatePostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *RateResource) Returns() interface{} {
+	return RateResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *RateResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	rps := data.(*RatePostStruct)
+
+	if len(rps.Rates) == 0 {
+		return errors.New("No exchange rates submitted")
+	}
+	// reject the entire batch if any of its rates is invalid
+	for _, r := range rps.Rates {
+		rate := db.ExchangeRate{
+			FromCurrency: r.From,
+			ToCurrency:   r.To,
+			Rate:         r.Rate,
+		}
+		if err := rate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
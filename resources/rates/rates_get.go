@@ -0,0 +1,69 @@
+package rates
+
+import (
+	"net/http"
+	"strings"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *RateResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *RateResource) GetDoc() string {
+	return "retrieve exchange rates"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *RateResource) GetParams() []*restful.Parameter {
+	params := []*restful.Parameter{}
+	params = append(params, restful.QueryParameter("from", "returns rates for a source currency only").DataType("string"))
+	params = append(params, restful.QueryParameter("to", "returns rates for a target currency only").DataType("string"))
+
+	return params
+}
+
+// Get sends out items matching the query parameters
+func (r *RateResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := RateResponse{}
+	resp.Init(context)
+
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_VIEW_PAYMENTS, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"RateResource GET"))
+		return
+	}
+
+	var from, to string
+	if len(params["from"]) > 0 {
+		from = strings.ToUpper(params["from"][0])
+	}
+	if len(params["to"]) > 0 {
+		to = strings.ToUpper(params["to"][0])
+	}
+
+	rates, err := ctx.LoadExchangeRates(from, to)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load exchange rates",
+			"RateResource GET"))
+		return
+	}
+
+	for _, rate := range rates {
+		resp.AddRate(rate)
+	}
+
+	resp.Send(response)
+}
@@ -0,0 +1,97 @@
+package rates
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// RatePostStruct holds all values of an incoming POST request
+type RatePostStruct struct {
+	Rates []struct {
+		From      string    `json:"from"`
+		To        string    `json:"to"`
+		Rate      string    `json:"rate"`
+		ValidFrom time.Time `json:"valid_from"`
+	} `json:"rates"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *RateResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *RateResource) PostDoc() string {
+	return "load new exchange rates"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *RateResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *RateResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_RATES, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"RateResource POST"))
+		return
+	}
+
+	ctx := context.(*db.APIContext)
+	rps := data.(*RatePostStruct)
+
+	resp := RateResponse{}
+	resp.Init(context)
+
+	rates := []db.ExchangeRate{}
+	err = ctx.Transact(func(tx *db.APIContextTx) error {
+		for _, r := range rps.Rates {
+			rate := db.ExchangeRate{
+				FromCurrency: r.From,
+				ToCurrency:   r.To,
+				Rate:         r.Rate,
+				ValidFrom:    r.ValidFrom,
+			}
+			if rate.ValidFrom.IsZero() {
+				rate.ValidFrom = time.Now().UTC()
+			}
+
+			if err := rate.Save(tx); err != nil {
+				return err
+			}
+			rates = append(rates, rate)
+		}
+
+		return nil
+	})
+	switch err {
+	case nil:
+	case db.ErrInvalidCurrency, db.ErrInvalidExchangeRate, db.ErrSameCurrency, db.ErrExchangeRateExists:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"RateResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't store exchange rates",
+			"RateResource POST"))
+		return
+	}
+
+	for _, rate := range rates {
+		resp.AddRate(rate)
+	}
+
+	resp.Send(response)
+}
@@ -0,0 +1,65 @@
+package rates
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// RateResponse is the common response to 'rate' requests
+type RateResponse struct {
+	smolder.Response
+
+	Rates []rateInfoResponse `json:"rates,omitempty"`
+	rates []db.ExchangeRate
+}
+
+type rateInfoResponse struct {
+	ID        int64     `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      string    `json:"rate"`
+	ValidFrom time.Time `json:"valid_from"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Init a new response
+func (r *RateResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Rates = []rateInfoResponse{}
+}
+
+// AddRate adds an exchange rate to the response
+func (r *RateResponse) AddRate(rate db.ExchangeRate) {
+	r.rates = append(r.rates, rate)
+	r.Rates = append(r.Rates, prepareRateResponse(r.Context, rate))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *RateResponse) EmptyResponse() interface{} {
+	if len(r.rates) == 0 {
+		var out struct {
+			Rates interface{} `json:"rates"`
+		}
+		out.Rates = []rateInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareRateResponse(context smolder.APIContext, rate db.ExchangeRate) rateInfoResponse {
+	resp := rateInfoResponse{
+		ID:        rate.ID,
+		From:      rate.FromCurrency,
+		To:        rate.ToCurrency,
+		Rate:      rate.Rate,
+		ValidFrom: rate.ValidFrom,
+		CreatedAt: rate.CreatedAt,
+	}
+
+	return resp
+}
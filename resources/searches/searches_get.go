@@ -51,11 +51,11 @@ func (r *SearchesResource) GetByIDs(context smolder.APIContext, request *restful
 
 // Get sends out items matching the query parameters
 func (r *SearchesResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
-	auth, err := context.Authentication(request)
-	if err != nil || auth.(db.User).ID != 1 {
+	_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_VIEW_PAYMENTS, nil)
+	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusUnauthorized,
-			"Admin permission required for this operation",
+			"Insufficient permissions for this operation",
 			"SearchesResource GET"))
 		return
 	}
@@ -0,0 +1,58 @@
+package sepaexports
+
+import (
+	"errors"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// SepaExportResource is the resource responsible for /sepa_exports
+type SepaExportResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.GetIDSupported = &SepaExportResource{}
+	_ smolder.PostSupported  = &SepaExportResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *SepaExportResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "SepaExportResource"
+	r.TypeName = "sepa_export"
+	r.Endpoint = "sepa_exports"
+	r.Doc = "Export outgoing payments as SEPA credit transfers"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *SepaExportResource) Reads() interface{} {
+	return &SepaExportPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *SepaExportResource) Returns() interface{} {
+	return SepaExportResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *SepaExportResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	sps := data.(*SepaExportPostStruct)
+
+	if len(sps.SepaExport.Payments) == 0 {
+		return errors.New("Missing payments")
+	}
+	if len(sps.SepaExport.ExecutionDate) > 0 {
+		if _, err := time.Parse("2006-01-02", sps.SepaExport.ExecutionDate); err != nil {
+			return errors.New("Invalid execution date")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package sepaexports
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetByIDsAuthRequired returns true because all requests need authentication
+func (r *SepaExportResource) GetByIDsAuthRequired() bool {
+	return true
+}
+
+// GetByIDs sends out all items matching a set of IDs
+func (r *SepaExportResource) GetByIDs(context smolder.APIContext, request *restful.Request, response *restful.Response, ids []string) {
+	ctx := context.(*db.APIContext)
+	resp := SepaExportResponse{}
+	resp.Init(context)
+
+	_, err := ctx.Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"SepaExportResource GET"))
+		return
+	}
+
+	for _, id := range ids {
+		iid, _ := strconv.ParseInt(id, 10, 0)
+		export, err := ctx.LoadSepaExportByID(iid)
+		if err != nil {
+			r.NotFound(request, response)
+			return
+		}
+
+		resp.AddSepaExport(export)
+	}
+
+	resp.Send(response)
+}
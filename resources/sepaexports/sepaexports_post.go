@@ -0,0 +1,86 @@
+package sepaexports
+
+import (
+	"net/http"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/sepa"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// SepaExportPostStruct holds all values of an incoming POST request
+type SepaExportPostStruct struct {
+	SepaExport struct {
+		Payments      []int64 `json:"payments"`
+		ExecutionDate string  `json:"execution_date"`
+	} `json:"sepa_export"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *SepaExportResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *SepaExportResource) PostDoc() string {
+	return "export processed outgoing payments as pain.001 credit transfer batch"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *SepaExportResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *SepaExportResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	_, err := ctx.Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"SepaExportResource POST"))
+		return
+	}
+
+	sps := data.(*SepaExportPostStruct)
+	execution, _ := time.Parse("2006-01-02", sps.SepaExport.ExecutionDate)
+
+	export, err := sepa.Export(ctx, sps.SepaExport.Payments, execution)
+	if _, ok := err.(*sepa.PaymentError); ok {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"SepaExportResource POST"))
+		return
+	}
+	switch err {
+	case nil:
+	case db.ErrInvalidID, db.ErrPaymentExported, db.ErrPaymentNotExportable, db.ErrApprovalRequired, db.ErrNoPayments:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"SepaExportResource POST"))
+		return
+	case sepa.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			err,
+			"SepaExportResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't export payments",
+			"SepaExportResource POST"))
+		return
+	}
+
+	resp := SepaExportResponse{}
+	resp.Init(context)
+	resp.AddSepaExport(export)
+	resp.Send(response)
+}
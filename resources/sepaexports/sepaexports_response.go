@@ -0,0 +1,70 @@
+package sepaexports
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// SepaExportResponse is the common response to 'sepa_export' requests
+type SepaExportResponse struct {
+	smolder.Response
+
+	SepaExports []sepaExportInfoResponse `json:"sepa_exports,omitempty"`
+	sepaExports []db.SepaExport
+}
+
+type sepaExportInfoResponse struct {
+	ID        int64     `json:"id"`
+	MessageID string    `json:"message_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `json:"user_id,omitempty"`
+	Payments  []int64   `json:"payments"`
+	Document  string    `json:"document"`
+}
+
+// Init a new response
+func (r *SepaExportResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.SepaExports = []sepaExportInfoResponse{}
+}
+
+// AddSepaExport adds an export to the response
+func (r *SepaExportResponse) AddSepaExport(export db.SepaExport) {
+	r.sepaExports = append(r.sepaExports, export)
+	r.SepaExports = append(r.SepaExports, prepareSepaExportResponse(r.Context, export))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *SepaExportResponse) EmptyResponse() interface{} {
+	if len(r.sepaExports) == 0 {
+		var out struct {
+			SepaExports interface{} `json:"sepa_exports"`
+		}
+		out.SepaExports = []sepaExportInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareSepaExportResponse(context smolder.APIContext, export db.SepaExport) sepaExportInfoResponse {
+	resp := sepaExportInfoResponse{
+		ID:        export.ID,
+		MessageID: export.MessageID,
+		CreatedAt: export.CreatedAt,
+		Payments:  export.PaymentIDs,
+		Document:  export.Document,
+	}
+
+	if export.UserID != nil {
+		if user, err := context.(*db.APIContext).LoadUserByID(*export.UserID); err == nil {
+			resp.UserID = user.UUID
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,60 @@
+package sessions
+
+import (
+	"net/http"
+	"strconv"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// DeleteAuthRequired returns true because all requests need authentication
+func (r *SessionResource) DeleteAuthRequired() bool {
+	return true
+}
+
+// DeleteDoc returns the description of this API endpoint
+func (r *SessionResource) DeleteDoc() string {
+	return "end a session"
+}
+
+// DeleteParams returns the parameters supported by this API endpoint
+func (r *SessionResource) DeleteParams() []*restful.Parameter {
+	return nil
+}
+
+// Delete processes an incoming DELETE request
+func (r *SessionResource) Delete(context smolder.APIContext, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+
+	id, _ := strconv.ParseInt(request.PathParameter("session-id"), 10, 64)
+	session, err := ctx.LoadSessionByID(id)
+	if err != nil {
+		r.NotFound(request, response)
+		return
+	}
+
+	// users may end their own sessions, admins everybody's
+	if session.UserID != ctx.Auth.ID {
+		_, err = ctx.Authorize(request, db.PERMISSION_MANAGE_USERS, nil)
+		if err != nil {
+			r.NotFound(request, response)
+			return
+		}
+	}
+
+	err = session.Revoke(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't end session",
+			"SessionResource DELETE"))
+		return
+	}
+
+	resp := SessionResponse{}
+	resp.Init(context)
+	resp.Send(response)
+}
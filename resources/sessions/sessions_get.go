@@ -0,0 +1,47 @@
+package sessions
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// GetAuthRequired returns true because all requests need authentication
+func (r *SessionResource) GetAuthRequired() bool {
+	return true
+}
+
+// GetDoc returns the description of this API endpoint
+func (r *SessionResource) GetDoc() string {
+	return "retrieve the active sessions of the current user"
+}
+
+// GetParams returns the parameters supported by this API endpoint
+func (r *SessionResource) GetParams() []*restful.Parameter {
+	return nil
+}
+
+// Get sends out items matching the query parameters
+func (r *SessionResource) Get(context smolder.APIContext, request *restful.Request, response *restful.Response, params map[string][]string) {
+	ctx := context.(*db.APIContext)
+	resp := SessionResponse{}
+	resp.Init(context)
+
+	sessions, err := ctx.Auth.LoadSessions(ctx)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't load sessions",
+			"SessionResource GET"))
+		return
+	}
+
+	for _, session := range sessions {
+		resp.AddSession(&session)
+	}
+
+	resp.Send(response)
+}
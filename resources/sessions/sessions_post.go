@@ -0,0 +1,78 @@
+package sessions
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// SessionPostStruct holds all values of an incoming POST request
+type SessionPostStruct struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Scopes   []string `json:"scopes"`
+}
+
+// PostAuthRequired returns false because we don't want requests to be filtered
+// by authentication - we are the ones creating the auth
+func (r *SessionResource) PostAuthRequired() bool {
+	return false
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *SessionResource) PostDoc() string {
+	return "create a new user session"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *SessionResource) PostParams() []*restful.Parameter {
+	params := []*restful.Parameter{}
+	params = append(params, restful.FormParameter("username", "username").
+		DataType("string").
+		Required(true).
+		AllowMultiple(false))
+	params = append(params, restful.QueryParameter("password", "password").
+		DataType("string").
+		Required(true).
+		AllowMultiple(false))
+	params = append(params, restful.QueryParameter("scopes", "limits the session to these scopes (read, write)").
+		DataType("string").
+		AllowMultiple(true))
+
+	return params
+}
+
+// Post processes an incoming POST (create) request
+func (r *SessionResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	resp := SessionResponse{}
+	resp.Init(context)
+
+	sps := data.(*SessionPostStruct)
+
+	user, err := ctx.GetUserByNameAndPassword(sps.Username, sps.Password)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			err,
+			"SessionResource POST"))
+		return
+	}
+
+	session, err := ctx.NewSession(&user, request.HeaderParameter("User-Agent"), sps.Scopes)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't create user session",
+			"SessionResource POST"))
+		return
+	}
+
+	resp.IDToken = session.Token
+	resp.UserID = user.UUID
+	resp.AddSession(&session)
+	response.WriteHeaderAndEntity(http.StatusOK, resp)
+}
@@ -0,0 +1,73 @@
+package sessions
+
+import (
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+
+	"github.com/muesli/smolder"
+)
+
+// SessionResponse is the common response to 'session' requests
+type SessionResponse struct {
+	smolder.Response
+
+	IDToken  string                `json:"id_token,omitempty"`
+	UserID   string                `json:"user_id,omitempty"`
+	Sessions []sessionInfoResponse `json:"sessions,omitempty"`
+	sessions []db.Session
+}
+
+type sessionInfoResponse struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	Scopes     []string  `json:"scopes"`
+	Current    bool      `json:"current"`
+}
+
+// Init a new response
+func (r *SessionResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Sessions = []sessionInfoResponse{}
+}
+
+// AddSession adds a session to the response
+func (r *SessionResponse) AddSession(session *db.Session) {
+	r.sessions = append(r.sessions, *session)
+	r.Sessions = append(r.Sessions, prepareSessionResponse(r.Context, session))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *SessionResponse) EmptyResponse() interface{} {
+	if len(r.sessions) == 0 {
+		var out struct {
+			Sessions interface{} `json:"sessions"`
+		}
+		out.Sessions = []sessionInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareSessionResponse(context smolder.APIContext, session *db.Session) sessionInfoResponse {
+	ctx := context.(*db.APIContext)
+	resp := sessionInfoResponse{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		ExpiresAt:  session.ExpiresAt,
+		LastUsedAt: session.LastUsedAt,
+		UserAgent:  session.UserAgent,
+		Scopes:     session.Scopes,
+	}
+
+	if ctx.Auth != nil && ctx.Auth.Session != nil {
+		resp.Current = ctx.Auth.Session.ID == session.ID
+	}
+
+	return resp
+}
@@ -0,0 +1,51 @@
+package statements
+
+import (
+	"errors"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// StatementResource is the resource responsible for /statements
+type StatementResource struct {
+	smolder.Resource
+}
+
+var (
+	_ smolder.PostSupported = &StatementResource{}
+)
+
+// Register this resource with the container to setup all the routes
+func (r *StatementResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "StatementResource"
+	r.TypeName = "statement"
+	r.Endpoint = "statements"
+	r.Doc = "Import bank statements"
+
+	r.Config = config
+	r.Context = context
+
+	r.Init(container, r)
+}
+
+// Reads returns the model that will be read by POST, PUT & PATCH operations
+func (r *StatementResource) Reads() interface{} {
+	return &StatementPostStruct{}
+}
+
+// Returns returns the model that will be returned
+func (r *StatementResource) Returns() interface{} {
+	return StatementResponse{}
+}
+
+// Validate checks an incoming request for data errors
+func (r *StatementResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
+	sps := data.(*StatementPostStruct)
+
+	if len(sps.Statement.Data) == 0 {
+		return errors.New("Missing bank statement")
+	}
+
+	return nil
+}
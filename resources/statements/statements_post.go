@@ -0,0 +1,71 @@
+package statements
+
+import (
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/statements"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+)
+
+// StatementPostStruct holds all values of an incoming POST request
+type StatementPostStruct struct {
+	Statement struct {
+		Format string `json:"format"`
+		// Data is the base64 encoded statement file
+		Data []byte `json:"data"`
+	} `json:"statement"`
+}
+
+// PostAuthRequired returns true because all requests need authentication
+func (r *StatementResource) PostAuthRequired() bool {
+	return true
+}
+
+// PostDoc returns the description of this API endpoint
+func (r *StatementResource) PostDoc() string {
+	return "import the bookings of a CAMT.053 or MT940 bank statement as payments"
+}
+
+// PostParams returns the parameters supported by this API endpoint
+func (r *StatementResource) PostParams() []*restful.Parameter {
+	return nil
+}
+
+// Post processes an incoming POST (create) request
+func (r *StatementResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
+	ctx := context.(*db.APIContext)
+	_, err := ctx.Authorize(request, db.PERMISSION_MANAGE_PAYMENTS, nil)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusUnauthorized,
+			"Insufficient permissions for this operation",
+			"StatementResource POST"))
+		return
+	}
+
+	sps := data.(*StatementPostStruct)
+	res, err := statements.Import(ctx, sps.Statement.Format, sps.Statement.Data)
+	switch err {
+	case nil:
+	case statements.ErrUnknownFormat, statements.ErrInvalidStatement, db.ErrInvalidCurrency:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"StatementResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't import bank statement",
+			"StatementResource POST"))
+		return
+	}
+
+	resp := StatementResponse{}
+	resp.Init(context)
+	resp.AddResult(&res)
+	resp.Send(response)
+}
@@ -0,0 +1,64 @@
+package statements
+
+import (
+	"gitlab.techcultivation.org/sangha/sangha/statements"
+
+	"github.com/muesli/smolder"
+)
+
+// StatementResponse is the common response to 'statement' requests
+type StatementResponse struct {
+	smolder.Response
+
+	Statements []statementInfoResponse `json:"statements,omitempty"`
+	results    []statements.Result
+}
+
+type statementInfoResponse struct {
+	Format   string  `json:"format"`
+	Entries  int     `json:"entries"`
+	Imported int     `json:"imported"`
+	Skipped  int     `json:"skipped"`
+	Payments []int64 `json:"payments"`
+}
+
+// Init a new response
+func (r *StatementResponse) Init(context smolder.APIContext) {
+	r.Parent = r
+	r.Context = context
+
+	r.Statements = []statementInfoResponse{}
+}
+
+// AddResult adds the result of a statement import to the response
+func (r *StatementResponse) AddResult(res *statements.Result) {
+	r.results = append(r.results, *res)
+	r.Statements = append(r.Statements, prepareStatementResponse(r.Context, res))
+}
+
+// EmptyResponse returns an empty API response for this endpoint if there's no data to respond with
+func (r *StatementResponse) EmptyResponse() interface{} {
+	if len(r.results) == 0 {
+		var out struct {
+			Statements interface{} `json:"statements"`
+		}
+		out.Statements = []statementInfoResponse{}
+		return out
+	}
+	return nil
+}
+
+func prepareStatementResponse(context smolder.APIContext, res *statements.Result) statementInfoResponse {
+	resp := statementInfoResponse{
+		Format:   res.Format,
+		Entries:  res.Entries,
+		Imported: len(res.Imported),
+		Skipped:  res.Skipped,
+		Payments: []int64{},
+	}
+	for _, p := range res.Imported {
+		resp.Payments = append(resp.Payments, p.ID)
+	}
+
+	return resp
+}
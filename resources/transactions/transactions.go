@@ -1,6 +1,8 @@
 package transactions
 
 import (
+	"errors"
+
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
 )
@@ -43,8 +45,8 @@ func (r *TransactionResource) Returns() interface{} {
 func (r *TransactionResource) Validate(context smolder.APIContext, data interface{}, request *restful.Request) error {
 	ups := data.(*TransactionPostStruct)
 
-	if ups.Transaction.Amount == 0 {
-		// return errors.New("Invalid transaction amount")
+	if ups.Transaction.Amount <= 0 {
+		return errors.New("Invalid transaction amount")
 	}
 
 	return nil
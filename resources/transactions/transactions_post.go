@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/resources/approvalrequests"
 
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
@@ -38,15 +39,6 @@ func (r *TransactionResource) PostParams() []*restful.Parameter {
 
 // Post processes an incoming POST (create) request
 func (r *TransactionResource) Post(context smolder.APIContext, data interface{}, request *restful.Request, response *restful.Response) {
-	auth, err := context.Authentication(request)
-	if err != nil || auth.(db.User).ID != 1 {
-		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
-			http.StatusUnauthorized,
-			"Admin permission required for this operation",
-			"TransactionResource POST"))
-		return
-	}
-
 	ctx := context.(*db.APIContext)
 	ups := data.(*TransactionPostStruct)
 	log.Printf("Got transaction request: %+v\n", ups)
@@ -68,19 +60,30 @@ func (r *TransactionResource) Post(context smolder.APIContext, data interface{},
 		return
 	}
 
-	bal, err := from.Balance(ctx)
-	if err != nil {
-		panic(err)
+	// transfers are only permitted between budgets the user may manage
+	for _, b := range []db.Budget{from, to} {
+		_, err = ctx.Authorize(request, db.PERMISSION_TRANSFER, b.ProjectID)
+		if err != nil {
+			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
+				"Insufficient permissions for this operation",
+				"TransactionResource POST"))
+			return
+		}
 	}
-	if bal < ups.Transaction.Amount {
-		smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
+
+	t, err := ctx.TransferFunds(from.ID, to.ID, ups.Transaction.Amount, ups.Transaction.Purpose, time.Now().UTC())
+	if err == db.ErrApprovalRequired {
+		r.requestApproval(ctx, from, to, ups, request, response)
+		return
+	}
+	if err == db.ErrInsufficientFunds {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusBadRequest,
 			"This budget does not have the necessary funds",
 			"TransactionResource POST"))
 		return
 	}
-
-	t, err := ctx.Transfer(from.ID, to.ID, ups.Transaction.Amount, ups.Transaction.Purpose, 0, time.Now().UTC())
 	if err != nil {
 		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 			http.StatusInternalServerError,
@@ -94,3 +97,28 @@ func (r *TransactionResource) Post(context smolder.APIContext, data interface{},
 	resp.AddTransaction(t)
 	resp.Send(response)
 }
+
+// requestApproval records a transfer that needs to be approved by other users
+// before it gets executed
+func (r *TransactionResource) requestApproval(ctx *db.APIContext, from, to db.Budget, ups *TransactionPostStruct, request *restful.Request, response *restful.Response) {
+	ar, err := ctx.RequestTransferApproval(from.ID, to.ID, ups.Transaction.Amount, ups.Transaction.Purpose)
+	if err == db.ErrInsufficientFunds {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"This budget does not have the necessary funds",
+			"TransactionResource POST"))
+		return
+	}
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Could not request approval for transaction",
+			"TransactionResource POST"))
+		return
+	}
+
+	resp := approvalrequests.ApprovalRequestResponse{}
+	resp.Init(ctx)
+	resp.AddApprovalRequest(ar)
+	resp.SendWithHeader(http.StatusAccepted, response)
+}
@@ -22,6 +22,7 @@ type transactionInfoResponse struct {
 	FromBudgetID *string   `json:"from_budget_id"`
 	ToBudgetID   *string   `json:"to_budget_id"`
 	Amount       int64     `json:"amount"`
+	Currency     string    `json:"currency"`
 	CreatedAt    time.Time `json:"created_at"`
 	Purpose      string    `json:"purpose"`
 	PaymentID    *int64    `json:"payment_id"`
@@ -58,11 +59,12 @@ func prepareTransactionResponse(context smolder.APIContext, transaction db.Trans
 	resp := transactionInfoResponse{
 		ID:        transaction.ID,
 		Amount:    transaction.Amount,
+		Currency:  transaction.Currency,
 		CreatedAt: transaction.CreatedAt,
 		Purpose:   transaction.Purpose,
 	}
 
-	if ctx.Auth != nil && ctx.Auth.ID == 1 {
+	if ctx.Auth.Can(db.PERMISSION_VIEW_PAYMENTS, nil) {
 		resp.PaymentID = transaction.PaymentID
 	}
 
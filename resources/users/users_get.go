@@ -37,9 +37,10 @@ func (r *UserResource) GetByIDs(context smolder.APIContext, request *restful.Req
 	resp := UserResponse{}
 	resp.Init(context)
 
-	auth, _ := context.Authentication(request)
+	// users may always look themselves up
+	auth, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_USERS, nil)
 	for _, id := range ids {
-		if auth == nil || (auth.(db.User).ID != 1 && auth.(db.User).UUID != id) {
+		if err != nil && (auth.ID == 0 || auth.UUID != id) {
 			smolder.ErrorResponseHandler(request, response, nil, smolder.NewErrorResponse(
 				http.StatusUnauthorized,
 				"Auth permission required for this operation",
@@ -75,11 +76,11 @@ func (r *UserResource) Get(context smolder.APIContext, request *restful.Request,
 
 		resp.AddUser(&user)
 	} else {
-		auth, err := context.Authentication(request)
-		if err != nil || auth == nil || auth.(db.User).ID != 1 {
+		_, err := context.(*db.APIContext).Authorize(request, db.PERMISSION_MANAGE_USERS, nil)
+		if err != nil {
 			smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
 				http.StatusUnauthorized,
-				"Admin permission required for this operation",
+				"Insufficient permissions for this operation",
 				"UserResource GET"))
 			return
 		}
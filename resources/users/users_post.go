@@ -7,6 +7,7 @@ import (
 
 	"github.com/emicklei/go-restful"
 	"github.com/muesli/smolder"
+	log "github.com/sirupsen/logrus"
 )
 
 // UserPostStruct holds all values of an incoming POST request
@@ -14,6 +15,7 @@ type UserPostStruct struct {
 	User struct {
 		Email    string   `json:"email"`
 		Nickname string   `json:"nickname"`
+		Name     string   `json:"name"`
 		About    string   `json:"about"`
 		Address  []string `json:"address"`
 		ZIP      string   `json:"zip"`
@@ -22,7 +24,8 @@ type UserPostStruct struct {
 	} `json:"user"`
 }
 
-// PostAuthRequired returns true because all requests need authentication
+// PostAuthRequired returns false because new users sign up without an
+// account. Changing an existing user requires authentication
 func (r *UserResource) PostAuthRequired() bool {
 	return false
 }
@@ -49,23 +52,28 @@ func (r *UserResource) Post(context smolder.APIContext, data interface{}, reques
 			return
 		} */
 
+	ctx := context.(*db.APIContext)
 	ups := data.(*UserPostStruct)
-	user, err := context.(*db.APIContext).GetUserByEmail(ups.User.Email)
+	user, err := ctx.GetUserByEmail(ups.User.Email)
 	if err == nil {
-		/*
-			smolder.ErrorResponseHandler(request, response, smolder.NewErrorResponse(
-				http.StatusBadRequest,
-				false,
+		// receipts get issued to an account's name & address, only the user
+		// and admins may change them
+		auth, aerr := ctx.Authorize(request, db.PERMISSION_MANAGE_USERS, nil)
+		if aerr != nil && (auth.ID == 0 || auth.ID != user.ID) {
+			smolder.ErrorResponseHandler(request, response, db.ErrPermissionDenied, smolder.NewErrorResponse(
+				http.StatusUnauthorized,
 				"A user with this email address already exists",
 				"UserResource POST"))
-		*/
+			return
+		}
 
+		user.Name = ups.User.Name
 		user.Address = ups.User.Address
 		user.ZIP = ups.User.ZIP
 		user.City = ups.User.City
 		user.Country = ups.User.Country
 
-		err = user.Update(context.(*db.APIContext))
+		err = user.Update(ctx)
 	} else {
 		if ups.User.About == "" {
 			ups.User.About = ups.User.Email
@@ -76,6 +84,7 @@ func (r *UserResource) Post(context smolder.APIContext, data interface{}, reques
 
 		user = db.User{
 			Nickname: ups.User.Nickname,
+			Name:     ups.User.Name,
 			Email:    ups.User.Email,
 			About:    ups.User.About,
 			Address:  ups.User.Address,
@@ -83,7 +92,16 @@ func (r *UserResource) Post(context smolder.APIContext, data interface{}, reques
 			City:     ups.User.City,
 			Country:  ups.User.Country,
 		}
-		err = user.Save(context.(*db.APIContext))
+		err = user.Save(ctx)
+		if err == nil {
+			// the account exists either way, activation mails can be re-sent
+			if merr := ctx.SendActivation(&user); merr != nil {
+				log.WithFields(log.Fields{
+					"User":  user.UUID,
+					"Error": merr,
+				}).Error("Can't send activation email")
+			}
+		}
 	}
 
 	if err != nil {
@@ -94,8 +112,6 @@ func (r *UserResource) Post(context smolder.APIContext, data interface{}, reques
 		return
 	}
 
-	//	utils.SendInvitation(&user)
-
 	resp := UserResponse{}
 	resp.Init(context)
 	resp.AddUser(&user)
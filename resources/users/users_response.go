@@ -18,11 +18,13 @@ type userInfoResponse struct {
 	ID        string   `json:"id"`
 	Email     string   `json:"email"`
 	Nickname  string   `json:"nickname"`
+	Name      string   `json:"name"`
 	About     string   `json:"about"`
 	Address   []string `json:"address"`
 	ZIP       string   `json:"zip"`
 	City      string   `json:"city"`
 	Country   string   `json:"country"`
+	Role      string   `json:"role"`
 	Admin     bool     `json:"admin"`
 	Activated bool     `json:"activated"`
 }
@@ -58,12 +60,14 @@ func prepareUserResponse(context smolder.APIContext, user *db.User) userInfoResp
 		ID:        user.UUID,
 		Email:     user.Email,
 		Nickname:  user.Nickname,
+		Name:      user.Name,
 		About:     user.About,
 		Address:   user.Address,
 		ZIP:       user.ZIP,
 		City:      user.City,
 		Country:   user.Country,
-		Admin:     user.ID == 1,
+		Role:      string(user.Role),
+		Admin:     user.IsAdmin(),
 		Activated: user.Activated,
 	}
 
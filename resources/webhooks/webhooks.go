@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+	log "github.com/sirupsen/logrus"
+)
+
+// WebhookResource is the resource responsible for /webhooks. Signatures
+// cover the raw request body, so unlike other resources it reads requests
+// itself instead of letting smolder decode them
+type WebhookResource struct {
+	smolder.Resource
+}
+
+// WebhookResponse is the response to a webhook notification
+type WebhookResponse struct {
+	Event   string `json:"event"`
+	Kind    string `json:"kind,omitempty"`
+	Payment int64  `json:"payment,omitempty"`
+	Replay  bool   `json:"replay,omitempty"`
+}
+
+// Register this resource with the container to setup all the routes
+func (r *WebhookResource) Register(container *restful.Container, config smolder.APIConfig, context smolder.APIContextFactory) {
+	r.Name = "WebhookResource"
+	r.TypeName = "webhook"
+	r.Endpoint = "webhooks"
+	r.Doc = "Receive payment notifications from payment providers"
+
+	r.Config = config
+	r.Context = context
+
+	log.WithField("Resource", r.Name).Info("Registering Resource")
+	ws := new(restful.WebService)
+	ws.Path("/" + config.PathPrefix + r.Endpoint).
+		Doc(r.Doc).
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	ws.Route(ws.POST("/{source}").To(r.Post).
+		Doc(r.PostDoc()).
+		Param(ws.PathParameter("source", "payment source sending the notification, e.g. stripe or paypal").
+			DataType("string").
+			Required(true).
+			AllowMultiple(false)).
+		Returns(http.StatusOK, "OK", WebhookResponse{}).
+		Returns(http.StatusBadRequest, "Invalid notification", smolder.ErrorResponse{}))
+
+	container.Add(ws)
+}
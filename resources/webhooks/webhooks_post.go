@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/providers"
+
+	"github.com/emicklei/go-restful"
+	"github.com/muesli/smolder"
+	log "github.com/sirupsen/logrus"
+)
+
+// MAX_WEBHOOK_SIZE limits the size of notifications we accept
+const MAX_WEBHOOK_SIZE = 1 << 20
+
+// PostDoc returns the description of this API endpoint
+func (r *WebhookResource) PostDoc() string {
+	return "receive a signed payment notification"
+}
+
+// Post handles a notification. Completed payments get stored as pending
+// payments, full refunds get booked back and disputes are recorded for the
+// payment they affect.
+// Replayed notifications are acknowledged without being handled again
+func (r *WebhookResource) Post(request *restful.Request, response *restful.Response) {
+	ctx := r.Context.NewAPIContext().(*db.APIContext)
+	source := request.PathParameter("source")
+
+	provider, err := providers.GetWebhook(ctx.Config, source)
+	switch err {
+	case nil:
+	case providers.ErrUnknownProvider:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusNotFound,
+			err,
+			"WebhookResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			err,
+			"WebhookResource POST"))
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(response, request.Request.Body, MAX_WEBHOOK_SIZE))
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			"Can't read notification",
+			"WebhookResource POST"))
+		return
+	}
+
+	event, err := provider.Webhook(request.Request.Header, body)
+	switch err {
+	case nil:
+	case providers.ErrInvalidSignature, providers.ErrInvalidEvent, db.ErrInvalidCurrency:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"WebhookResource POST"))
+		return
+	case providers.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			err,
+			"WebhookResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadGateway,
+			"Can't verify notification",
+			"WebhookResource POST"))
+		return
+	}
+
+	resp := WebhookResponse{Event: event.ID, Kind: event.Kind}
+	if len(event.Kind) == 0 {
+		// acknowledge events we're not interested in, so they don't get retried
+		response.WriteHeaderAndJson(http.StatusOK, resp, restful.MIME_JSON)
+		return
+	}
+
+	exists, err := ctx.WebhookEventExists(source, event.ID)
+	if err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't look up notification",
+			"WebhookResource POST"))
+		return
+	}
+	if exists {
+		resp.Replay = true
+		response.WriteHeaderAndJson(http.StatusOK, resp, restful.MIME_JSON)
+		return
+	}
+
+	var payment db.Payment
+	switch event.Kind {
+	case db.WEBHOOK_EVENT_COMPLETED:
+		payment, err = r.storePayment(ctx, provider, event)
+	default:
+		payment, err = ctx.LoadPaymentBySourceTransactionID(source, event.Payment.SourceTransactionID)
+		if err == sql.ErrNoRows {
+			log.WithFields(log.Fields{
+				"Source":        source,
+				"Event":         event.ID,
+				"Kind":          event.Kind,
+				"TransactionID": event.Payment.SourceTransactionID,
+			}).Warn("Notification refers to an unknown payment")
+			err = nil
+		} else if err == nil {
+			r.reversePayment(ctx, &payment, event)
+		}
+	}
+	switch err {
+	case nil:
+	case providers.ErrInvalidPayment, db.ErrInvalidCurrency:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusBadRequest,
+			err,
+			"WebhookResource POST"))
+		return
+	case providers.ErrNotConfigured:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusServiceUnavailable,
+			"No receiving budget configured",
+			"WebhookResource POST"))
+		return
+	default:
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't handle notification",
+			"WebhookResource POST"))
+		return
+	}
+
+	we := db.WebhookEvent{
+		Source:  source,
+		EventID: event.ID,
+		Kind:    event.Kind,
+	}
+	if payment.ID > 0 {
+		we.PaymentID = &payment.ID
+		resp.Payment = payment.ID
+	}
+	if _, err = we.Save(ctx); err != nil {
+		smolder.ErrorResponseHandler(request, response, err, smolder.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Can't store notification",
+			"WebhookResource POST"))
+		return
+	}
+
+	response.WriteHeaderAndJson(http.StatusOK, resp, restful.MIME_JSON)
+}
+
+// storePayment verifies & normalises a completed payment and stores it as a
+// pending payment. A payment that has already been stored is returned as is
+func (r *WebhookResource) storePayment(ctx *db.APIContext, provider providers.WebhookProvider, event providers.Event) (db.Payment, error) {
+	if err := provider.Verify(event.Payment); err != nil {
+		return db.Payment{}, err
+	}
+	payment, err := provider.Normalize(event.Payment)
+	if err != nil {
+		return db.Payment{}, err
+	}
+
+	if ctx.Config.Processing.ReceivingBudget == 0 {
+		return db.Payment{}, providers.ErrNotConfigured
+	}
+	payment.BudgetID = ctx.Config.Processing.ReceivingBudget
+
+	err = payment.Save(ctx, nil)
+	if err == db.ErrPaymentExists {
+		err = nil
+	}
+	return payment, err
+}
+
+// reversePayment books a payment back that its source fully refunded.
+// Partial refunds & disputes, which may still be won, as well as refunds
+// the budgets can't cover anymore are left to be handled manually
+func (r *WebhookResource) reversePayment(ctx *db.APIContext, payment *db.Payment, event providers.Event) {
+	fields := log.Fields{
+		"Payment": payment.ID,
+		"Kind":    event.Kind,
+		"Amount":  event.Payment.Amount,
+	}
+
+	if event.Kind != db.WEBHOOK_EVENT_REFUNDED || event.Payment.Amount < payment.Amount {
+		log.WithFields(fields).Warn("Payment has been reversed by its payment source")
+		return
+	}
+
+	err := payment.Refund(ctx, db.PAYMENT_REFUND_REFUND, false)
+	switch err {
+	case nil:
+		log.WithFields(fields).Info("Refunded payment")
+	case db.ErrPaymentRefunded:
+	default:
+		fields["Error"] = err
+		log.WithFields(fields).Warn("Can't refund payment reversed by its payment source")
+	}
+}
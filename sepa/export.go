@@ -0,0 +1,86 @@
+package sepa
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+var (
+	// ErrNotConfigured is the error returned when the account outgoing
+	// payments get paid from hasn't been configured
+	ErrNotConfigured = errors.New("SEPA debtor account not configured")
+	// ErrInvalidCurrency is the error returned for payments that can't be
+	// paid with SEPA credit transfers
+	ErrInvalidCurrency = errors.New("SEPA credit transfers only support EUR")
+)
+
+// PaymentError is the error returned for a payment that can't be exported
+type PaymentError struct {
+	PaymentID int64
+	Err       error
+}
+
+func (e *PaymentError) Error() string {
+	return fmt.Sprintf("Payment %d: %s", e.PaymentID, e.Err)
+}
+
+// Export turns a set of processed outgoing payments into a pain.001.001.03
+// batch, to be executed on the given day, and marks them as exported.
+// Payments that have already been exported are refused
+func Export(context *db.APIContext, ids []int64, execution time.Time) (db.SepaExport, error) {
+	cfg := context.Config.Sepa
+	if len(cfg.Name) == 0 || len(cfg.IBAN) == 0 {
+		return db.SepaExport{}, ErrNotConfigured
+	}
+
+	return context.ExportPayments(ids, func(export *db.SepaExport, payments []db.Payment) error {
+		b := Batch{
+			MessageID: "SANGHA-" + export.CreatedAt.Format("20060102150405") + fmt.Sprintf("-%06d", export.CreatedAt.Nanosecond()/1000),
+			CreatedAt: export.CreatedAt,
+			Execution: execution,
+			Debtor: Party{
+				Name: cfg.Name,
+				IBAN: cfg.IBAN,
+				BIC:  cfg.BIC,
+			},
+		}
+		if b.Execution.IsZero() || b.Execution.Before(export.CreatedAt.Truncate(24*time.Hour)) {
+			b.Execution = export.CreatedAt
+		}
+
+		paymentIDs := make(map[string]int64)
+		for _, p := range payments {
+			if p.Currency != "EUR" {
+				return &PaymentError{PaymentID: p.ID, Err: ErrInvalidCurrency}
+			}
+
+			id := fmt.Sprintf("SANGHA-PAYMENT-%d", p.ID)
+			paymentIDs[id] = p.ID
+			b.Transfers = append(b.Transfers, Transfer{
+				EndToEndID: id,
+				Amount:     -p.Amount,
+				Creditor: Party{
+					Name: p.RemoteName,
+					IBAN: p.RemoteAccount,
+					BIC:  p.RemoteBankID,
+				},
+				Purpose: p.Purpose,
+			})
+		}
+
+		doc, err := b.Marshal()
+		if err != nil {
+			if te, ok := err.(*TransferError); ok {
+				return &PaymentError{PaymentID: paymentIDs[te.EndToEndID], Err: te.Err}
+			}
+			return err
+		}
+
+		export.MessageID = b.MessageID
+		export.Document = string(doc)
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package sepa
+
+import (
+	"errors"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidIBAN is the error returned for malformed IBANs or IBANs with
+	// a wrong check sum
+	ErrInvalidIBAN = errors.New("Invalid IBAN")
+	// ErrInvalidBIC is the error returned for malformed BICs
+	ErrInvalidBIC = errors.New("Invalid BIC")
+
+	ibanPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	bicPattern  = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+	// ibanLengths lists the IBAN lengths of the SEPA countries
+	ibanLengths = map[string]int{
+		"AD": 24, "AT": 20, "BE": 16, "BG": 22, "CH": 21, "CY": 28, "CZ": 24,
+		"DE": 22, "DK": 18, "EE": 20, "ES": 24, "FI": 18, "FR": 27, "GB": 22,
+		"GI": 23, "GR": 27, "HR": 21, "HU": 28, "IE": 22, "IS": 26, "IT": 27,
+		"LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MT": 31, "NL": 18,
+		"NO": 15, "PL": 28, "PT": 25, "RO": 24, "SE": 24, "SI": 19, "SK": 24,
+		"SM": 27, "VA": 22,
+	}
+)
+
+// NormalizeIBAN removes the spaces IBANs are often printed with and turns
+// them into upper case
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// ValidateIBAN checks that an IBAN belongs to a SEPA country, has the
+// country's length and a valid check sum. It returns the normalized IBAN
+func ValidateIBAN(iban string) (string, error) {
+	iban = NormalizeIBAN(iban)
+	if !ibanPattern.MatchString(iban) {
+		return iban, ErrInvalidIBAN
+	}
+	if l, ok := ibanLengths[iban[:2]]; !ok || l != len(iban) {
+		return iban, ErrInvalidIBAN
+	}
+
+	// move the country code & check digits to the end and turn letters into
+	// numbers, A being 10. The result has to be 1 modulo 97
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return iban, ErrInvalidIBAN
+	}
+
+	return iban, nil
+}
+
+// ValidateBIC checks that a BIC is well-formed and belongs to the country of
+// iban, if one is given. It returns the normalized BIC
+func ValidateBIC(bic, iban string) (string, error) {
+	bic = strings.ToUpper(strings.TrimSpace(bic))
+	if !bicPattern.MatchString(bic) {
+		return bic, ErrInvalidBIC
+	}
+	if len(iban) >= 2 && bic[4:6] != iban[:2] {
+		// a few territories use their parent country's BICs
+		switch iban[:2] + bic[4:6] {
+		case "MCFR", "SMIT", "VAIT", "GIGB":
+		default:
+			return bic, ErrInvalidBIC
+		}
+	}
+
+	return bic, nil
+}
@@ -0,0 +1,266 @@
+package sepa
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PAIN001_NAMESPACE is the namespace of pain.001.001.03 documents
+const PAIN001_NAMESPACE = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+var (
+	// ErrInvalidAmount is the error returned for transfers without a
+	// positive amount
+	ErrInvalidAmount = errors.New("Invalid transfer amount")
+	// ErrMissingName is the error returned for parties without a name
+	ErrMissingName = errors.New("Missing account holder name")
+	// ErrNoTransfers is the error returned for batches without transfers
+	ErrNoTransfers = errors.New("Batch contains no transfers")
+
+	textReplacer = strings.NewReplacer(
+		"Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+		"&", "+", "\r\n", " ", "\n", " ", "\t", " ")
+)
+
+// Party is an account holder
+type Party struct {
+	Name string
+	IBAN string
+	// BIC may be empty, SEPA transfers only require an IBAN
+	BIC string
+}
+
+// Transfer is a single credit transfer. Amount is given in euro cents
+type Transfer struct {
+	EndToEndID string
+	Amount     int64
+	Creditor   Party
+	Purpose    string
+}
+
+// Batch is a set of credit transfers from a single debtor account, executed
+// on the same day
+type Batch struct {
+	MessageID string
+	CreatedAt time.Time
+	Execution time.Time
+	Debtor    Party
+	Transfers []Transfer
+}
+
+// Validate checks the parties' account details and the transfers' amounts.
+// IBANs and BICs get normalized
+func (b *Batch) Validate() error {
+	if len(b.Transfers) == 0 {
+		return ErrNoTransfers
+	}
+	if err := b.Debtor.validate(); err != nil {
+		return err
+	}
+
+	for i := range b.Transfers {
+		t := &b.Transfers[i]
+		if t.Amount <= 0 {
+			return &TransferError{EndToEndID: t.EndToEndID, Err: ErrInvalidAmount}
+		}
+		if err := t.Creditor.validate(); err != nil {
+			return &TransferError{EndToEndID: t.EndToEndID, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// TransferError is the error returned for a transfer that can't be exported
+type TransferError struct {
+	EndToEndID string
+	Err        error
+}
+
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("Transfer %s: %s", e.EndToEndID, e.Err)
+}
+
+func (p *Party) validate() error {
+	if len(strings.TrimSpace(p.Name)) == 0 {
+		return ErrMissingName
+	}
+
+	var err error
+	if p.IBAN, err = ValidateIBAN(p.IBAN); err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(p.BIC)) > 0 {
+		if p.BIC, err = ValidateBIC(p.BIC, p.IBAN); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sum returns the total amount of all transfers
+func (b *Batch) Sum() int64 {
+	var sum int64
+	for _, t := range b.Transfers {
+		sum += t.Amount
+	}
+	return sum
+}
+
+// Marshal validates a batch and encodes it as pain.001.001.03 document
+func (b *Batch) Marshal() ([]byte, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	nb := fmt.Sprint(len(b.Transfers))
+	sum := formatAmount(b.Sum())
+
+	doc := painDocument{Namespace: PAIN001_NAMESPACE}
+	doc.Initiation.Header = painHeader{
+		MessageID:    text(b.MessageID, 35),
+		CreatedAt:    b.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+		Transactions: nb,
+		Sum:          sum,
+		Initiator:    painParty{Name: text(b.Debtor.Name, 70)},
+	}
+
+	pi := painPaymentInfo{
+		ID:           text(b.MessageID, 35),
+		Method:       "TRF",
+		BatchBooking: true,
+		Transactions: nb,
+		Sum:          sum,
+		Execution:    b.Execution.Format("2006-01-02"),
+		Debtor:       painParty{Name: text(b.Debtor.Name, 70)},
+		DebtorAcct:   painAccount{IBAN: b.Debtor.IBAN},
+		DebtorAgent:  agent(b.Debtor.BIC),
+		ChargeBearer: "SLEV",
+	}
+	pi.TypeInfo.ServiceLevel = "SEPA"
+
+	for _, t := range b.Transfers {
+		ti := painTransaction{
+			EndToEndID:   text(t.EndToEndID, 35),
+			Amount:       painAmount{Currency: "EUR", Value: formatAmount(t.Amount)},
+			Creditor:     painParty{Name: text(t.Creditor.Name, 70)},
+			CreditorAcct: painAccount{IBAN: t.Creditor.IBAN},
+			Remittance:   text(t.Purpose, 140),
+		}
+		if len(t.Creditor.BIC) > 0 {
+			a := agent(t.Creditor.BIC)
+			ti.CreditorAgent = &a
+		}
+		pi.Transfers = append(pi.Transfers, ti)
+	}
+	doc.Initiation.PaymentInfo = pi
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// pain.001.001.03 document structure, limited to what SEPA credit transfers
+// need
+type painDocument struct {
+	XMLName    xml.Name `xml:"Document"`
+	Namespace  string   `xml:"xmlns,attr"`
+	Initiation struct {
+		Header      painHeader      `xml:"GrpHdr"`
+		PaymentInfo painPaymentInfo `xml:"PmtInf"`
+	} `xml:"CstmrCdtTrfInitn"`
+}
+
+type painHeader struct {
+	MessageID    string    `xml:"MsgId"`
+	CreatedAt    string    `xml:"CreDtTm"`
+	Transactions string    `xml:"NbOfTxs"`
+	Sum          string    `xml:"CtrlSum"`
+	Initiator    painParty `xml:"InitgPty"`
+}
+
+type painPaymentInfo struct {
+	ID           string `xml:"PmtInfId"`
+	Method       string `xml:"PmtMtd"`
+	BatchBooking bool   `xml:"BtchBookg"`
+	Transactions string `xml:"NbOfTxs"`
+	Sum          string `xml:"CtrlSum"`
+	TypeInfo     struct {
+		ServiceLevel string `xml:"SvcLvl>Cd"`
+	} `xml:"PmtTpInf"`
+	Execution    string            `xml:"ReqdExctnDt"`
+	Debtor       painParty         `xml:"Dbtr"`
+	DebtorAcct   painAccount       `xml:"DbtrAcct"`
+	DebtorAgent  painAgent         `xml:"DbtrAgt"`
+	ChargeBearer string            `xml:"ChrgBr"`
+	Transfers    []painTransaction `xml:"CdtTrfTxInf"`
+}
+
+type painTransaction struct {
+	EndToEndID    string      `xml:"PmtId>EndToEndId"`
+	Amount        painAmount  `xml:"Amt>InstdAmt"`
+	CreditorAgent *painAgent  `xml:"CdtrAgt,omitempty"`
+	Creditor      painParty   `xml:"Cdtr"`
+	CreditorAcct  painAccount `xml:"CdtrAcct"`
+	Remittance    string      `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+type painParty struct {
+	Name string `xml:"Nm"`
+}
+
+type painAccount struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+type painAgent struct {
+	BIC   string `xml:"FinInstnId>BIC,omitempty"`
+	Other string `xml:"FinInstnId>Othr>Id,omitempty"`
+}
+
+type painAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// agent identifies a bank by its BIC. Without a BIC it is marked as not
+// provided, since the debtor's agent is mandatory
+func agent(bic string) painAgent {
+	if len(bic) == 0 {
+		return painAgent{Other: "NOTPROVIDED"}
+	}
+	return painAgent{BIC: bic}
+}
+
+// formatAmount formats euro cents as decimal amount
+func formatAmount(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// text restricts s to the SEPA character set and truncates it to max
+// characters
+func text(s string, max int) string {
+	s = textReplacer.Replace(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("/-?:().,'+ ", r):
+		default:
+			r = '.'
+		}
+		b.WriteRune(r)
+	}
+
+	s = strings.Join(strings.Fields(b.String()), " ")
+	if len(s) > max {
+		s = strings.TrimSpace(s[:max])
+	}
+	return s
+}
@@ -0,0 +1,119 @@
+package sepa
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateIBAN(t *testing.T) {
+	tests := []struct {
+		iban  string
+		valid bool
+	}{
+		{"DE89370400440532013000", true},
+		{"de89 3704 0044 0532 0130 00", true},
+		{"GB82WEST12345698765432", true},
+		{"NL91ABNA0417164300", true},
+		// wrong check digits
+		{"DE88370400440532013000", false},
+		// wrong length for the country
+		{"DE8937040044053201300", false},
+		// not a SEPA country
+		{"US64SVBKUS6S3300958879", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		iban, err := ValidateIBAN(test.iban)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %v, got %v", test.iban, test.valid, err)
+		}
+		if err == nil && strings.ContainsAny(iban, " abcdefghijklmnopqrstuvwxyz") {
+			t.Errorf("%q: not normalized: %q", test.iban, iban)
+		}
+	}
+}
+
+func TestValidateBIC(t *testing.T) {
+	tests := []struct {
+		bic, iban string
+		valid     bool
+	}{
+		{"COBADEFFXXX", "DE89370400440532013000", true},
+		{"cobadeff", "DE89370400440532013000", true},
+		{"COBADEFFXXX", "", true},
+		// the country doesn't match the IBAN's
+		{"ABNANL2A", "DE89370400440532013000", false},
+		{"COBADE", "", false},
+		{"COBADEFFXX", "", false},
+	}
+
+	for _, test := range tests {
+		if _, err := ValidateBIC(test.bic, test.iban); (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %v, got %v", test.bic, test.valid, err)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	b := Batch{
+		MessageID: "SANGHA-1",
+		CreatedAt: ts,
+		Execution: ts.AddDate(0, 0, 1),
+		Debtor:    Party{Name: "Sangha e.V.", IBAN: "DE89 3704 0044 0532 0130 00"},
+		Transfers: []Transfer{
+			{EndToEndID: "SANGHA-PAYMENT-1", Amount: 1250, Creditor: Party{Name: "Jörg Müller", IBAN: "NL91ABNA0417164300", BIC: "ABNANL2A"}, Purpose: "Invoice #42\nThanks"},
+			{EndToEndID: "SANGHA-PAYMENT-2", Amount: 100005, Creditor: Party{Name: "Jane Doe", IBAN: "GB82WEST12345698765432"}},
+		},
+	}
+
+	data, err := b.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc painDocument
+	if err = xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Namespace != PAIN001_NAMESPACE {
+		t.Errorf("unexpected namespace %q", doc.Namespace)
+	}
+
+	hdr := doc.Initiation.Header
+	if hdr.Transactions != "2" || hdr.Sum != "1012.55" || hdr.CreatedAt != "2026-10-18T12:00:00" {
+		t.Errorf("unexpected header %+v", hdr)
+	}
+
+	pi := doc.Initiation.PaymentInfo
+	if pi.Execution != "2026-10-19" || pi.DebtorAcct.IBAN != "DE89370400440532013000" || pi.DebtorAgent.Other != "NOTPROVIDED" {
+		t.Errorf("unexpected payment info %+v", pi)
+	}
+	if len(pi.Transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %d", len(pi.Transfers))
+	}
+
+	tx := pi.Transfers[0]
+	if tx.Amount.Value != "12.50" || tx.Amount.Currency != "EUR" {
+		t.Errorf("unexpected amount %+v", tx.Amount)
+	}
+	if tx.Creditor.Name != "Joerg Mueller" || tx.Remittance != "Invoice .42 Thanks" {
+		t.Errorf("text not restricted to the SEPA character set: %q %q", tx.Creditor.Name, tx.Remittance)
+	}
+	if tx.CreditorAgent == nil || tx.CreditorAgent.BIC != "ABNANL2A" {
+		t.Errorf("unexpected creditor agent %+v", tx.CreditorAgent)
+	}
+	if pi.Transfers[1].CreditorAgent != nil {
+		t.Error("expected no creditor agent without a BIC")
+	}
+
+	// invalid account details are refused
+	b.Transfers[1].Creditor.IBAN = "GB82WEST12345698765433"
+	_, err = b.Marshal()
+	if te, ok := err.(*TransferError); !ok || te.EndToEndID != "SANGHA-PAYMENT-2" || te.Err != ErrInvalidIBAN {
+		t.Errorf("expected invalid IBAN of the second transfer, got %v", err)
+	}
+}
@@ -13,15 +13,28 @@ import (
 
 	"gitlab.techcultivation.org/sangha/sangha/config"
 	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/resources/activations"
+	"gitlab.techcultivation.org/sangha/sangha/resources/approvalrequests"
 	"gitlab.techcultivation.org/sangha/sangha/resources/budgets"
 	"gitlab.techcultivation.org/sangha/sangha/resources/codes"
+	"gitlab.techcultivation.org/sangha/sangha/resources/contributors"
+	"gitlab.techcultivation.org/sangha/sangha/resources/donationreceipts"
+	"gitlab.techcultivation.org/sangha/sangha/resources/donations"
+	"gitlab.techcultivation.org/sangha/sangha/resources/donoraccounts"
+	"gitlab.techcultivation.org/sangha/sangha/resources/invoices"
+	"gitlab.techcultivation.org/sangha/sangha/resources/passwordresets"
+	"gitlab.techcultivation.org/sangha/sangha/resources/paymentpreviews"
 	"gitlab.techcultivation.org/sangha/sangha/resources/payments"
 	"gitlab.techcultivation.org/sangha/sangha/resources/projects"
+	"gitlab.techcultivation.org/sangha/sangha/resources/rates"
 	"gitlab.techcultivation.org/sangha/sangha/resources/searches"
+	"gitlab.techcultivation.org/sangha/sangha/resources/sepaexports"
 	"gitlab.techcultivation.org/sangha/sangha/resources/sessions"
+	"gitlab.techcultivation.org/sangha/sangha/resources/statements"
 	"gitlab.techcultivation.org/sangha/sangha/resources/statistics"
 	"gitlab.techcultivation.org/sangha/sangha/resources/transactions"
 	"gitlab.techcultivation.org/sangha/sangha/resources/users"
+	"gitlab.techcultivation.org/sangha/sangha/resources/webhooks"
 )
 
 var (
@@ -56,11 +69,24 @@ func executeServe() error {
 	}(
 		&sessions.SessionResource{},
 		&users.UserResource{},
+		&activations.ActivationResource{},
+		&passwordresets.PasswordResetResource{},
 		&projects.ProjectResource{},
+		&contributors.ContributorResource{},
 		&budgets.BudgetResource{},
 		&codes.CodeResource{},
 		&transactions.TransactionResource{},
 		&payments.PaymentResource{},
+		&paymentpreviews.PaymentPreviewResource{},
+		&donations.DonationResource{},
+		&donoraccounts.DonorAccountResource{},
+		&donationreceipts.DonationReceiptResource{},
+		&statements.StatementResource{},
+		&invoices.InvoiceResource{},
+		&sepaexports.SepaExportResource{},
+		&approvalrequests.ApprovalRequestResource{},
+		&webhooks.WebhookResource{},
+		&rates.RateResource{},
 		&statistics.StatisticsResource{},
 		&searches.SearchesResource{},
 	)
@@ -76,6 +102,8 @@ func executeServe() error {
 		swagger.RegisterSwaggerService(wsConfig, wsContainer)
 	}
 
+	syncHbciPeriodically(context)
+
 	// GlobalLog("Starting web-api...")
 	server := &http.Server{Addr: config.Settings.API.Bind, Handler: wsContainer}
 	log.Fatal(server.ListenAndServe())
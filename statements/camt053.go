@@ -0,0 +1,203 @@
+package statements
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// camtAmount is an amount with its currency attribute
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtDate is either a date or a date & time
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtParty struct {
+	Name string `xml:"Nm"`
+}
+
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+type camtAgent struct {
+	BIC     string `xml:"FinInstnId>BIC"`
+	BICFI   string `xml:"FinInstnId>BICFI"`
+	OtherID string `xml:"FinInstnId>Othr>Id"`
+}
+
+type camtTransaction struct {
+	AcctSvcrRef   string      `xml:"Refs>AcctSvcrRef"`
+	Amount        *camtAmount `xml:"Amt"`
+	TxAmount      *camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	Debtor        camtParty   `xml:"RltdPties>Dbtr"`
+	DebtorAcct    camtAccount `xml:"RltdPties>DbtrAcct"`
+	Creditor      camtParty   `xml:"RltdPties>Cdtr"`
+	CreditorAcct  camtAccount `xml:"RltdPties>CdtrAcct"`
+	DebtorAgent   camtAgent   `xml:"RltdAgts>DbtrAgt"`
+	CreditorAgent camtAgent   `xml:"RltdAgts>CdtrAgt"`
+	Unstructured  []string    `xml:"RmtInf>Ustrd"`
+}
+
+// camtStatus is either a plain status or, since camt.053.001.08, a status code
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type camtEntry struct {
+	Amount       camtAmount        `xml:"Amt"`
+	CreditDebit  string            `xml:"CdtDbtInd"`
+	Status       camtStatus        `xml:"Sts"`
+	BookingDate  camtDate          `xml:"BookgDt"`
+	ValueDate    camtDate          `xml:"ValDt"`
+	AcctSvcrRef  string            `xml:"AcctSvcrRef"`
+	Transactions []camtTransaction `xml:"NtryDtls>TxDtls"`
+}
+
+type camtDocument struct {
+	Statements []struct {
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+// ParseCAMT053 parses the booked entries of an ISO 20022 camt.053 statement.
+// Batch bookings result in one entry per transaction
+func ParseCAMT053(r io.Reader) ([]Entry, error) {
+	doc := camtDocument{}
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "iso-8859-1", "iso8859-1", "latin1":
+			b, err := ioutil.ReadAll(input)
+			return strings.NewReader(latin1ToUTF8(string(b))), err
+		}
+		return nil, ErrInvalidStatement
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, ErrInvalidStatement
+	}
+
+	entries := []Entry{}
+	for _, stmt := range doc.Statements {
+		for _, ne := range stmt.Entries {
+			status := firstOf(ne.Status.Code, ne.Status.Value)
+			if status != "" && status != "BOOK" {
+				// pending & informational entries show up again once booked
+				continue
+			}
+
+			es, err := ne.entries()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, es...)
+		}
+	}
+
+	return entries, nil
+}
+
+func (ne camtEntry) entries() ([]Entry, error) {
+	date, err := ne.BookingDate.time()
+	if err != nil {
+		if date, err = ne.ValueDate.time(); err != nil {
+			return nil, ErrInvalidStatement
+		}
+	}
+
+	var sign int64
+	switch ne.CreditDebit {
+	case "CRDT":
+		sign = 1
+	case "DBIT":
+		sign = -1
+	default:
+		return nil, ErrInvalidStatement
+	}
+
+	txs := ne.Transactions
+	if len(txs) == 0 {
+		txs = []camtTransaction{{}}
+	}
+
+	entries := []Entry{}
+	for _, tx := range txs {
+		amt := ne.Amount
+		if len(ne.Transactions) > 1 {
+			// batch bookings carry each transaction's own amount
+			switch {
+			case tx.Amount != nil:
+				amt = *tx.Amount
+			case tx.TxAmount != nil:
+				amt = *tx.TxAmount
+			default:
+				return nil, ErrInvalidStatement
+			}
+		}
+
+		amount, err := parseAmount(amt.Value, amt.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		// the remote party is the debtor of credits and the creditor of debits
+		party, acct, agent := tx.Debtor, tx.DebtorAcct, tx.DebtorAgent
+		if sign < 0 {
+			party, acct, agent = tx.Creditor, tx.CreditorAcct, tx.CreditorAgent
+		}
+
+		ref := tx.AcctSvcrRef
+		if len(ref) == 0 && len(ne.Transactions) <= 1 {
+			ref = ne.AcctSvcrRef
+		}
+		if ref == "NONREF" {
+			ref = ""
+		}
+
+		entries = append(entries, Entry{
+			BookingDate: date,
+			Amount:      sign * amount,
+			Currency:    strings.ToUpper(amt.Currency),
+			Name:        strings.TrimSpace(party.Name),
+			Account:     firstOf(acct.IBAN, acct.Other),
+			BankID:      firstOf(agent.BIC, agent.BICFI, agent.OtherID),
+			Purpose:     strings.TrimSpace(strings.Join(tx.Unstructured, " ")),
+			Reference:   strings.TrimSpace(ref),
+		})
+	}
+
+	return entries, nil
+}
+
+func (d camtDate) time() (time.Time, error) {
+	if len(d.Date) > 0 {
+		return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+	}
+	if len(d.DateTime) > 0 {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(d.DateTime))
+		if err != nil {
+			t, err = time.Parse("2006-01-02T15:04:05", strings.TrimSpace(d.DateTime))
+		}
+		return t.UTC(), err
+	}
+	return time.Time{}, ErrInvalidStatement
+}
+
+func firstOf(s ...string) string {
+	for _, v := range s {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package statements
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// :61: valuedate [entrydate] mark [funds code] amount type [customer ref] [//bank ref]
+	mt940Booking = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)[NFS][A-Z0-9]{3}(.*?)(?://(.*))?$`)
+	// :60F: / :60M: mark date currency amount
+	mt940Balance = regexp.MustCompile(`^[CD]\d{6}([A-Z]{3})`)
+	// SEPA purpose keywords in structured :86: fields
+	mt940Keyword = regexp.MustCompile(`(EREF|KREF|MREF|CRED|DEBT|SVWZ|ABWA|ABWE|IBAN|BIC)\+`)
+)
+
+// mt940Field is a tag and its value, including continuation lines
+type mt940Field struct {
+	Tag   string
+	Value string
+}
+
+// ParseMT940 parses the bookings of a SWIFT MT940 statement. Structured :86:
+// fields as used by German banks are supported
+func ParseMT940(r io.Reader) ([]Entry, error) {
+	fields, err := mt940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	var currency string
+	var current *Entry
+	for _, f := range fields {
+		switch f.Tag {
+		case "60F", "60M":
+			m := mt940Balance.FindStringSubmatch(f.Value)
+			if m == nil {
+				return nil, ErrInvalidStatement
+			}
+			currency = m[1]
+
+		case "61":
+			if len(currency) == 0 {
+				return nil, ErrInvalidStatement
+			}
+			e, err := parseMT940Booking(f.Value, currency)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+			current = &entries[len(entries)-1]
+
+		case "86":
+			if current == nil {
+				continue
+			}
+			current.Name, current.Account, current.BankID, current.Purpose = parseMT940Details(f.Value)
+			current = nil
+
+		case "62F", "62M":
+			current = nil
+		}
+	}
+
+	return entries, nil
+}
+
+// mt940Fields splits a statement into its fields
+func mt940Fields(r io.Reader) ([]mt940Field, error) {
+	fields := []mt940Field{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if !utf8.ValidString(line) {
+			line = latin1ToUTF8(line)
+		}
+
+		switch {
+		case len(line) == 0, line == "-", strings.HasPrefix(line, "{"), strings.HasPrefix(line, "-}"):
+			continue
+		case strings.HasPrefix(line, ":"):
+			idx := strings.Index(line[1:], ":")
+			if idx < 1 {
+				return nil, ErrInvalidStatement
+			}
+			fields = append(fields, mt940Field{Tag: line[1 : idx+1], Value: line[idx+2:]})
+		case len(fields) > 0:
+			// continuation of the previous field
+			last := &fields[len(fields)-1]
+			if last.Tag == "61" {
+				// supplementary details of a booking carry no data we need
+				continue
+			}
+			last.Value += line
+		}
+	}
+
+	return fields, scanner.Err()
+}
+
+func parseMT940Booking(s, currency string) (Entry, error) {
+	m := mt940Booking.FindStringSubmatch(s)
+	if m == nil {
+		return Entry{}, ErrInvalidStatement
+	}
+
+	date, err := time.Parse("060102", m[1])
+	if err != nil {
+		return Entry{}, ErrInvalidStatement
+	}
+	if len(m[2]) > 0 {
+		// the booking date lacks a year, it may fall into the next or previous one
+		bd, err := time.Parse("0102", m[2])
+		if err != nil {
+			return Entry{}, ErrInvalidStatement
+		}
+		booking := time.Date(date.Year(), bd.Month(), bd.Day(), 0, 0, 0, 0, time.UTC)
+		if booking.Sub(date) > 180*24*time.Hour {
+			booking = booking.AddDate(-1, 0, 0)
+		} else if date.Sub(booking) > 180*24*time.Hour {
+			booking = booking.AddDate(1, 0, 0)
+		}
+		date = booking
+	}
+
+	amount, err := parseAmount(m[5], currency)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// reversals flip the sign of a booking
+	switch m[3] {
+	case "D", "RC":
+		amount = -amount
+	}
+
+	ref := strings.TrimSpace(m[7])
+	if ref == "NONREF" {
+		ref = ""
+	}
+
+	return Entry{
+		BookingDate: date,
+		Amount:      amount,
+		Currency:    currency,
+		Reference:   ref,
+	}, nil
+}
+
+// parseMT940Details parses a :86: field. Structured fields consist of a
+// transaction code followed by ?NN subfields
+func parseMT940Details(s string) (name, account, bankID, purpose string) {
+	idx := strings.Index(s, "?")
+	if idx < 0 {
+		return "", "", "", strings.TrimSpace(s)
+	}
+
+	sep := s[idx : idx+1]
+	var purposes []string
+	for _, sub := range strings.Split(s[idx+1:], sep) {
+		if len(sub) < 2 {
+			continue
+		}
+
+		v := sub[2:]
+		switch code := sub[:2]; {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			purposes = append(purposes, v)
+		case code == "30":
+			bankID = strings.TrimSpace(v)
+		case code == "31":
+			account = strings.TrimSpace(v)
+		case code == "32", code == "33":
+			name += v
+		}
+	}
+
+	purpose = strings.Join(purposes, "")
+	if loc := mt940Keyword.FindAllStringSubmatchIndex(purpose, -1); len(loc) > 0 {
+		// prefer the SEPA remittance information over references & mandates
+		for i, l := range loc {
+			if purpose[l[2]:l[3]] != "SVWZ" {
+				continue
+			}
+			end := len(purpose)
+			if i+1 < len(loc) {
+				end = loc[i+1][0]
+			}
+			purpose = purpose[l[1]:end]
+			break
+		}
+	}
+
+	return strings.TrimSpace(name), account, bankID, strings.TrimSpace(purpose)
+}
+
+// latin1ToUTF8 converts an ISO 8859-1 encoded string, which most banks use
+// for their statements
+func latin1ToUTF8(s string) string {
+	b := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		b[i] = rune(s[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,226 @@
+package statements
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	money "github.com/Rhymond/go-money"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+// Formats of supported bank statements
+const (
+	FORMAT_CAMT053 = "camt053"
+	FORMAT_MT940   = "mt940"
+)
+
+// SOURCE is the payment source imported statement entries get stored with
+const SOURCE = "bank_transfer"
+
+var (
+	// ErrUnknownFormat is the error returned for unsupported statement formats
+	ErrUnknownFormat = errors.New("Unknown bank statement format")
+	// ErrInvalidStatement is the error returned for malformed statements
+	ErrInvalidStatement = errors.New("Invalid bank statement")
+	// ErrNoReceivingBudget is the error returned when no budget has been configured to book statements to
+	ErrNoReceivingBudget = errors.New("No receiving budget configured")
+)
+
+// Entry is a single booking on a bank statement. Credits have a positive,
+// debits a negative amount
+type Entry struct {
+	BookingDate time.Time
+	Amount      int64
+	Currency    string
+	Name        string
+	Account     string
+	BankID      string
+	Purpose     string
+	// Reference identifies the booking at the bank. Entries without a bank
+	// reference get one derived from their contents
+	Reference string
+}
+
+// Result summarises a statement import
+type Result struct {
+	Format   string
+	Entries  int
+	Imported []db.Payment
+	Skipped  int
+}
+
+// DetectFormat guesses the format of a bank statement
+func DetectFormat(data []byte) (string, error) {
+	d := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(d, []byte("<")):
+		if bytes.Contains(d, []byte("BkToCstmrStmt")) {
+			return FORMAT_CAMT053, nil
+		}
+	case bytes.Contains(d, []byte(":20:")) && bytes.Contains(d, []byte(":61:")):
+		return FORMAT_MT940, nil
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// Parse parses a bank statement. An empty format gets detected
+func Parse(format string, data []byte) ([]Entry, string, error) {
+	var err error
+	if len(format) == 0 {
+		format, err = DetectFormat(data)
+		if err != nil {
+			return nil, format, err
+		}
+	}
+
+	var entries []Entry
+	switch format {
+	case FORMAT_CAMT053:
+		entries, err = ParseCAMT053(bytes.NewReader(data))
+	case FORMAT_MT940:
+		entries, err = ParseMT940(bytes.NewReader(data))
+	default:
+		return nil, format, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, format, err
+	}
+
+	AssignReferences(entries)
+	return entries, format, nil
+}
+
+// Payment turns an entry into a payment from source booked to budgetID
+func (e Entry) Payment(source string, budgetID int64) db.Payment {
+	return db.Payment{
+		BudgetID:            budgetID,
+		CreatedAt:           e.BookingDate,
+		Amount:              e.Amount,
+		Currency:            e.Currency,
+		Purpose:             e.Purpose,
+		RemoteAccount:       e.Account,
+		RemoteName:          e.Name,
+		RemoteTransactionID: e.Reference,
+		RemoteBankID:        e.BankID,
+		Source:              source,
+	}
+}
+
+// Import parses a bank statement and stores its entries as bank_transfer
+// payments
+func Import(context *db.APIContext, format string, data []byte) (Result, error) {
+	entries, format, err := Parse(format, data)
+	if err != nil {
+		return Result{Format: format}, err
+	}
+
+	res, err := Store(context, SOURCE, entries)
+	res.Format = format
+	return res, err
+}
+
+// Store saves entries as pending payments from source. Entries booked before
+// the latest payment from source get skipped, as do entries that have
+// already been stored. All entries need a reference
+func Store(context *db.APIContext, source string, entries []Entry) (Result, error) {
+	res := Result{Entries: len(entries)}
+
+	budgetID := context.Config.Processing.ReceivingBudget
+	if budgetID == 0 {
+		return res, ErrNoReceivingBudget
+	}
+
+	var cutoff time.Time
+	latest, err := context.LatestPaymentFromSource(source)
+	if err == nil {
+		y, m, d := latest.CreatedAt.Date()
+		cutoff = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	} else if err != sql.ErrNoRows {
+		return res, err
+	}
+
+	matcher, err := context.NewCodeMatcher()
+	if err != nil {
+		return res, err
+	}
+
+	for _, e := range entries {
+		if e.BookingDate.Before(cutoff) {
+			res.Skipped++
+			continue
+		}
+
+		payment := e.Payment(source, budgetID)
+		err = payment.Save(context, matcher)
+		if err == db.ErrPaymentExists {
+			res.Skipped++
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+
+		res.Imported = append(res.Imported, payment)
+	}
+
+	return res, nil
+}
+
+// AssignReferences derives references for entries the bank didn't provide
+// one for. Identical entries within a statement get numbered, so they don't
+// collapse into one payment
+func AssignReferences(entries []Entry) {
+	seen := make(map[string]int)
+	for i, e := range entries {
+		if len(e.Reference) > 0 {
+			continue
+		}
+
+		h := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s|%s|%s|%s",
+			e.BookingDate.Format("2006-01-02"), e.Amount, e.Currency, e.Account, e.Name, e.Purpose)))
+		ref := hex.EncodeToString(h[:16])
+		seen[ref]++
+		entries[i].Reference = fmt.Sprintf("%s-%d", ref, seen[ref])
+	}
+}
+
+// parseAmount converts a decimal amount with either a dot or a comma as
+// decimal separator into the currency's minor units
+func parseAmount(s, currency string) (int64, error) {
+	c := money.GetCurrency(currency)
+	if c == nil {
+		return 0, db.ErrInvalidCurrency
+	}
+
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	parts := strings.SplitN(s, ".", 2)
+	whole, frac := parts[0], ""
+	if len(parts) == 2 {
+		frac = parts[1]
+	}
+	if len(whole) == 0 {
+		whole = "0"
+	}
+	if len(frac) > c.Fraction {
+		// only trailing zeros may exceed the currency's minor units
+		if strings.Trim(frac[c.Fraction:], "0") != "" {
+			return 0, ErrInvalidStatement
+		}
+		frac = frac[:c.Fraction]
+	}
+	frac += strings.Repeat("0", c.Fraction-len(frac))
+
+	v, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || v < 0 {
+		return 0, ErrInvalidStatement
+	}
+	return v, nil
+}
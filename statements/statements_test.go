@@ -0,0 +1,164 @@
+package statements
+
+import (
+	"testing"
+	"time"
+)
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Ntry>
+        <Amt Ccy="EUR">25.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2019-08-01</Dt></BookgDt>
+        <AcctSvcrRef>2019080100001</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties>
+            <Dbtr><Nm>Jane Doe</Nm></Dbtr>
+            <DbtrAcct><Id><IBAN>DE89370400440532013000</IBAN></Id></DbtrAcct>
+          </RltdPties>
+          <RltdAgts><DbtrAgt><FinInstnId><BIC>COBADEFFXXX</BIC></FinInstnId></DbtrAgt></RltdAgts>
+          <RmtInf><Ustrd>Donation</Ustrd><Ustrd>abcd1234</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2019-08-02</Dt></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Nm>Hosting Ltd</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Invoice 42</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">99.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2019-08-03</Dt></BookgDt>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">3.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2019-08-04</Dt></BookgDt>
+        <NtryDtls>
+          <TxDtls><Refs><AcctSvcrRef>B1</AcctSvcrRef></Refs><Amt Ccy="EUR">1.00</Amt></TxDtls>
+          <TxDtls><Refs><AcctSvcrRef>B2</AcctSvcrRef></Refs><Amt Ccy="EUR">2.00</Amt></TxDtls>
+        </NtryDtls>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+const mt940 = `:20:STARTUMS
+:25:37040044/0532013000
+:28C:00001/001
+:60F:C190731EUR1000,00
+:61:1908010801CR25,00NTRFNONREF//REF0001
+:86:166?00GUTSCHRIFT?20EREF+123?21SVWZ+Donation abcd?221234?30COBADEFFXXX?31DE8937040044053
+2013000?32Jane Doe
+:61:1908020802DR10,50NDDTNONREF
+:86:Invoice 42
+:62F:C190802EUR1014,50
+-`
+
+func TestParseCAMT053(t *testing.T) {
+	entries, format, err := Parse("", []byte(camt053))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FORMAT_CAMT053 {
+		t.Errorf("expected format %s, got %s", FORMAT_CAMT053, format)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Amount != 2500 || e.Currency != "EUR" || e.Name != "Jane Doe" || e.Account != "DE89370400440532013000" ||
+		e.BankID != "COBADEFFXXX" || e.Purpose != "Donation abcd1234" || e.Reference != "2019080100001" ||
+		!e.BookingDate.Equal(time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected credit entry: %+v", e)
+	}
+
+	e = entries[1]
+	if e.Amount != -1050 || e.Name != "Hosting Ltd" || e.Purpose != "Invoice 42" || len(e.Reference) == 0 {
+		t.Errorf("unexpected debit entry: %+v", e)
+	}
+
+	if entries[2].Amount != 100 || entries[2].Reference != "B1" || entries[3].Amount != 200 || entries[3].Reference != "B2" {
+		t.Errorf("unexpected batch entries: %+v %+v", entries[2], entries[3])
+	}
+}
+
+func TestParseMT940(t *testing.T) {
+	entries, format, err := Parse("", []byte(mt940))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FORMAT_MT940 {
+		t.Errorf("expected format %s, got %s", FORMAT_MT940, format)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Amount != 2500 || e.Currency != "EUR" || e.Name != "Jane Doe" || e.Account != "DE89370400440532013000" ||
+		e.BankID != "COBADEFFXXX" || e.Purpose != "Donation abcd1234" || e.Reference != "REF0001" ||
+		!e.BookingDate.Equal(time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected credit entry: %+v", e)
+	}
+
+	e = entries[1]
+	if e.Amount != -1050 || e.Purpose != "Invoice 42" || len(e.Reference) == 0 {
+		t.Errorf("unexpected debit entry: %+v", e)
+	}
+}
+
+func TestDerivedReferences(t *testing.T) {
+	date := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{BookingDate: date, Amount: 500, Currency: "EUR", Purpose: "Coffee"},
+		{BookingDate: date, Amount: 500, Currency: "EUR", Purpose: "Coffee"},
+	}
+	AssignReferences(entries)
+
+	if entries[0].Reference == entries[1].Reference {
+		t.Errorf("identical entries got the same reference %s", entries[0].Reference)
+	}
+
+	again := []Entry{{BookingDate: date, Amount: 500, Currency: "EUR", Purpose: "Coffee"}}
+	AssignReferences(again)
+	if again[0].Reference != entries[0].Reference {
+		t.Errorf("references are not stable: %s != %s", again[0].Reference, entries[0].Reference)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s        string
+		currency string
+		exp      int64
+		fail     bool
+	}{
+		{"12,34", "EUR", 1234, false},
+		{"12.3", "EUR", 1230, false},
+		{"12,", "EUR", 1200, false},
+		{"100", "JPY", 100, false},
+		{"1.500", "EUR", 150, false},
+		{"1.505", "EUR", 0, true},
+		{"1.5", "XXX", 0, true},
+	}
+
+	for _, test := range tests {
+		v, err := parseAmount(test.s, test.currency)
+		if (err != nil) != test.fail || v != test.exp {
+			t.Errorf("%s %s: expected %d (fail: %v), got %d (%v)", test.s, test.currency, test.exp, test.fail, v, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+	"gitlab.techcultivation.org/sangha/sangha/hbci"
+)
+
+var (
+	syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "sync payments with remote accounts",
+		Long:  `The sync command is used to fetch payments from remote accounts`,
+		RunE:  nil,
+	}
+	syncHbciCmd = &cobra.Command{
+		Use:   "hbci",
+		Short: "sync the HBCI bank account",
+		Long: `The hbci command fetches all bookings since the last sync from the configured
+FinTS/HBCI bank account and stores them as pending payments`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db.GetDatabase()
+			context := &db.APIContext{
+				Config: *config.Settings,
+			}
+			return executeSyncHbci(context.NewAPIContext().(*db.APIContext))
+		},
+	}
+)
+
+func init() {
+	syncCmd.AddCommand(syncHbciCmd)
+	RootCmd.AddCommand(syncCmd)
+}
+
+func executeSyncHbci(ctx *db.APIContext) error {
+	client, err := hbci.NewFinTS(ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	res, err := hbci.Sync(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Synced %d of %d bookings from HBCI account, skipped %d",
+		len(res.Imported), res.Entries, res.Skipped)
+	return nil
+}
+
+// syncHbciPeriodically syncs the HBCI account in the background, if a sync
+// interval has been configured
+func syncHbciPeriodically(context *db.APIContext) {
+	if len(context.Config.PaymentProviders.Hbci.SyncInterval) == 0 {
+		return
+	}
+	interval, err := time.ParseDuration(context.Config.PaymentProviders.Hbci.SyncInterval)
+	if err != nil || interval <= 0 {
+		log.WithField("SyncInterval", context.Config.PaymentProviders.Hbci.SyncInterval).
+			Error("Invalid HBCI sync interval, not syncing")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			ctx := context.NewAPIContext().(*db.APIContext)
+			if err := executeSyncHbci(ctx); err != nil {
+				log.WithField("Error", err).Error("Can't sync HBCI account")
+			}
+			<-ticker.C
+		}
+	}()
+}
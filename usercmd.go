@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.techcultivation.org/sangha/sangha/config"
+	"gitlab.techcultivation.org/sangha/sangha/db"
+)
+
+var (
+	userCmd = &cobra.Command{
+		Use:   "user",
+		Short: "manage users",
+		Long:  `The user command is used to manage users`,
+		RunE:  nil,
+	}
+	userRoleCmd = &cobra.Command{
+		Use:   "role [email] [role]",
+		Short: "assign a global role",
+		Long: `The role command assigns a global role to a user. Valid roles are admin,
+treasurer & viewer. Pass an empty role to revoke it. Per-project roles are
+managed via the contributors endpoint`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeUserRole(args[0], db.Role(args[1]))
+		},
+	}
+)
+
+func init() {
+	userCmd.AddCommand(userRoleCmd)
+	RootCmd.AddCommand(userCmd)
+}
+
+func executeUserRole(email string, role db.Role) error {
+	if !db.ValidGlobalRole(role) {
+		return fmt.Errorf("Invalid role: %s", role)
+	}
+
+	db.GetDatabase()
+	context := &db.APIContext{
+		Config: *config.Settings,
+	}
+	ctx := context.NewAPIContext().(*db.APIContext)
+
+	user, err := ctx.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	err = user.UpdateRole(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Assigned role '%s' to %s", role, user.Nickname)
+	return nil
+}